@@ -0,0 +1,233 @@
+// Command mockidp is a minimal OpenID Connect provider for developing and
+// testing OIDC login locally. It signs in anyone as whoever they claim to be,
+// so it must never be exposed publicly.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	subject       string
+	username      string
+	email         string
+	expiresAt     time.Time
+}
+
+var (
+	issuer     string
+	signingKey jwk.Key
+	publicKeys jwk.Set
+
+	codesMu sync.Mutex
+	codes   = map[string]authorization{}
+)
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!doctype html>
+<title>Mock IdP</title>
+<h1>Mock IdP</h1>
+<p>Signing in to <b>{{.ClientID}}</b>. Enter any identity.</p>
+<form method="post">
+  {{range $k, $v := .Query}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+  <p><label>Subject <input name="sub" value="alice" required></label></p>
+  <p><label>Username <input name="preferred_username" value="alice"></label></p>
+  <p><label>Email <input name="email" value="alice@example.com"></label></p>
+  <p><button>Sign in</button></p>
+</form>
+`))
+
+func randomString() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+	})
+}
+
+func handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, publicKeys)
+}
+
+func handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if r.URL.Query().Get("code_challenge_method") != "S256" {
+			http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+			return
+		}
+
+		authorizeTemplate.Execute(w, map[string]any{
+			"ClientID": r.URL.Query().Get("client_id"),
+			"Query":    r.URL.Query(),
+		})
+		return
+	}
+
+	code := randomString()
+
+	codesMu.Lock()
+	codes[code] = authorization{
+		clientID:      r.FormValue("client_id"),
+		redirectURI:   r.FormValue("redirect_uri"),
+		nonce:         r.FormValue("nonce"),
+		codeChallenge: r.FormValue("code_challenge"),
+		subject:       r.FormValue("sub"),
+		username:      r.FormValue("preferred_username"),
+		email:         r.FormValue("email"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	codesMu.Unlock()
+
+	redirect, err := url.Parse(r.FormValue("redirect_uri"))
+
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", r.FormValue("state"))
+	redirect.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	codesMu.Lock()
+	auth, ok := codes[r.FormValue("code")]
+	delete(codes, r.FormValue("code"))
+	codesMu.Unlock()
+
+	clientID := r.FormValue("client_id")
+
+	if basicID, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(basicID)
+	}
+
+	if !ok || time.Now().After(auth.expiresAt) || r.FormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge ||
+		r.FormValue("redirect_uri") != auth.redirectURI || clientID != auth.clientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token, err := jwt.NewBuilder().
+		Issuer(issuer).
+		Subject(auth.subject).
+		Audience([]string{auth.clientID}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Minute*5)).
+		Claim("nonce", auth.nonce).
+		Claim("preferred_username", auth.username).
+		Claim("email", auth.email).
+		Claim("email_verified", auth.email != "").
+		Build()
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, signingKey))
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     string(signed),
+	})
+}
+
+func main() {
+	addr := os.Getenv("MOCK_IDP_ADDR")
+
+	if addr == "" {
+		addr = ":9000"
+	}
+
+	issuer = os.Getenv("MOCK_IDP_ISSUER")
+
+	if issuer == "" {
+		issuer = "http://localhost:9000"
+	}
+
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	signingKey, err = jwk.FromRaw(raw)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	signingKey.Set(jwk.KeyIDKey, randomString())
+	signingKey.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	keys := jwk.NewSet()
+	keys.AddKey(signingKey)
+
+	publicKeys, err = jwk.PublicSetOf(keys)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", handleDiscovery)
+	http.HandleFunc("/jwks", handleJWKS)
+	http.HandleFunc("/authorize", handleAuthorize)
+	http.HandleFunc("/token", handleToken)
+
+	log.Printf("Mock IdP listening on %s with issuer %s\n", addr, issuer)
+	log.Fatalln(http.ListenAndServe(addr, nil))
+}
@@ -1,22 +1,150 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/mail"
+	"github.com/themintchoco/cvwo/internal/oidc"
+	"github.com/themintchoco/cvwo/internal/ranking"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
 	"github.com/themintchoco/cvwo/internal/router"
 )
 
 func main() {
-	log.Println("Starting server...")
-
 	err := db.Connect()
 
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	r := router.Setup()
+	err = mail.Configure()
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	oidc.Configure()
+
+	err = ratelimit.Configure()
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = ranking.Configure()
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(os.Args[2:])
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	if os.Getenv("DB_AUTO_MIGRATE") == "false" {
+		pending, err := db.CheckMigrations()
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if pending > 0 {
+			log.Fatalf("%d pending migration(s), run `server migrate up` first\n", pending)
+		}
+	} else {
+		count, err := db.Migrate()
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if count > 0 {
+			log.Printf("Applied %d migration(s)\n", count)
+		}
+	}
+
+	log.Println("Starting server...")
+
+	s := db.NewStore()
+	go ranking.Run(context.Background(), s.Posts)
+
+	r := router.Setup(s)
 	log.Fatalln(http.ListenAndServe(":3000", r))
 }
+
+func runMigrate(args []string) (err error) {
+	command := "up"
+
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		count, err := db.Migrate()
+
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		count, err := db.MigrateDown(steps)
+
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Reverted %d migration(s)\n", count)
+	case "status":
+		statuses, err := db.GetMigrationStatus()
+
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	case "reconcile":
+		err = db.ReconcileCounters()
+
+		if err != nil {
+			return err
+		}
+
+		log.Println("Reconciled counters")
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down, status or reconcile", command)
+	}
+
+	return
+}
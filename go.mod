@@ -9,6 +9,7 @@ require (
 	github.com/google/uuid v1.5.0
 	github.com/h2non/bimg v1.1.9
 	github.com/lestrrat-go/jwx/v2 v2.0.17
+	github.com/lib/pq v1.10.9
 	github.com/microcosm-cc/bluemonday v1.0.26
 	github.com/stephenafamo/bob v0.23.2
 	golang.org/x/crypto v0.16.0
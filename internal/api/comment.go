@@ -6,26 +6,28 @@ import (
 )
 
 type baseComment struct {
-	ID      uint `json:"id"`
-	PostID  uint `json:"postId"`
-	Deleted bool `json:"deleted"`
+	ID       uint      `json:"id"`
+	PostID   uint      `json:"postId"`
+	ParentID *uint     `json:"parentId"`
+	Depth    uint      `json:"depth"`
+	Deleted  bool      `json:"deleted"`
+	Replies  []Comment `json:"replies,omitempty"`
 }
 
 type Comment struct {
 	baseComment
-	Body      string    `json:"body"`
-	Author    User      `json:"author"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	Body          string     `json:"body"`
+	Author        User       `json:"author"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	Edited        bool       `json:"edited"`
+	EditedAt      *time.Time `json:"editedAt"`
+	RevisionCount uint       `json:"revisionCount"`
 }
 
 func (c Comment) MarshalJSON() ([]byte, error) {
 	if c.Deleted {
-		return json.Marshal(baseComment{
-			ID:      c.ID,
-			PostID:  c.PostID,
-			Deleted: c.Deleted,
-		})
+		return json.Marshal(c.baseComment)
 	}
 
 	type alias Comment
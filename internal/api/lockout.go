@@ -0,0 +1,10 @@
+package api
+
+import "time"
+
+type Lockout struct {
+	Kind        string    `json:"kind"`
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
@@ -1,6 +1,48 @@
 package api
 
+import "time"
+
 type Me struct {
-	ID    uint `json:"id"`
-	Prefs any  `json:"prefs"`
+	ID                         uint        `json:"id"`
+	Prefs                      any         `json:"prefs"`
+	Email                      *string     `json:"email,omitempty"`
+	EmailVerified              bool        `json:"emailVerified"`
+	TwoFactorEnabled           bool        `json:"twoFactorEnabled"`
+	TwoFactorEnrolmentRequired bool        `json:"twoFactorEnrolmentRequired,omitempty"`
+	Capabilities               []string    `json:"capabilities,omitempty"`
+	Suspension                 *Suspension `json:"suspension,omitempty"`
+}
+
+type Session struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type Identity struct {
+	ID          uint       `json:"id"`
+	Issuer      string     `json:"issuer"`
+	Email       *string    `json:"email"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+}
+
+type APIToken struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	LastUsedIP *string    `json:"lastUsedIp"`
+	Token      string     `json:"token,omitempty"`
 }
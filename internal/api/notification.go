@@ -0,0 +1,16 @@
+package api
+
+import "time"
+
+type Notification struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	PostID     uint      `json:"postId"`
+	CommentID  *uint     `json:"commentId"`
+	Actor      User      `json:"actor"`
+	ActorCount uint      `json:"actorCount"`
+	Message    string    `json:"message"`
+	Read       bool      `json:"read"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,9 @@
+package api
+
+// Page is a page of a list. NextCursor is passed back to get the page after
+// it, and is null on the last page.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+	HasMore    bool    `json:"hasMore"`
+}
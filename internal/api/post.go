@@ -12,13 +12,18 @@ type basePost struct {
 
 type Post struct {
 	basePost
-	Title        string    `json:"title"`
-	Body         string    `json:"body"`
-	Author       User      `json:"author"`
-	CommentCount uint      `json:"commentCount"`
-	Tags         Tags      `json:"tags"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	Title         string     `json:"title"`
+	Body          string     `json:"body"`
+	Author        User       `json:"author"`
+	CommentCount  uint       `json:"commentCount"`
+	ReactionCount uint       `json:"reactionCount"`
+	HotScore      float64    `json:"hotScore"`
+	Tags          Tags       `json:"tags"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	Edited        bool       `json:"edited"`
+	EditedAt      *time.Time `json:"editedAt"`
+	RevisionCount uint       `json:"revisionCount"`
 }
 
 func (p Post) MarshalJSON() ([]byte, error) {
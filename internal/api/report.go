@@ -0,0 +1,42 @@
+package api
+
+import "time"
+
+type Report struct {
+	ID             uint       `json:"id"`
+	ReporterID     uint       `json:"reporterId"`
+	TargetType     string     `json:"targetType"`
+	TargetID       uint       `json:"targetId"`
+	Reason         string     `json:"reason"`
+	Note           string     `json:"note"`
+	Status         string     `json:"status"`
+	AssigneeID     *uint      `json:"assigneeId"`
+	Resolution     *string    `json:"resolution"`
+	ResolutionNote *string    `json:"resolutionNote"`
+	ResolvedBy     *uint      `json:"resolvedBy"`
+	ResolvedAt     *time.Time `json:"resolvedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+type Warning struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"userId"`
+	ReportID  *uint     `json:"reportId"`
+	Reason    string    `json:"reason"`
+	CreatedBy uint      `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Suspension struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"userId"`
+	ReportID  *uint      `json:"reportId"`
+	Reason    string     `json:"reason"`
+	EndsAt    *time.Time `json:"endsAt"`
+	LiftedAt  *time.Time `json:"liftedAt"`
+	LiftedBy  *uint      `json:"liftedBy"`
+	CreatedBy uint       `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Active    bool       `json:"active"`
+}
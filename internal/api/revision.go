@@ -0,0 +1,24 @@
+package api
+
+import "time"
+
+type Revision struct {
+	Number    uint      `json:"number"`
+	Title     *string   `json:"title,omitempty"`
+	Body      string    `json:"body"`
+	Editor    User      `json:"editor"`
+	CreatedAt time.Time `json:"createdAt"`
+	Current   bool      `json:"current"`
+}
+
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type RevisionDiff struct {
+	From  uint     `json:"from"`
+	To    uint     `json:"to"`
+	Title []DiffOp `json:"title,omitempty"`
+	Body  []DiffOp `json:"body"`
+}
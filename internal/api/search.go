@@ -0,0 +1,10 @@
+package api
+
+type SearchResult struct {
+	Type    string   `json:"type"`
+	Score   float64  `json:"score"`
+	Title   string   `json:"title,omitempty"`
+	Snippet string   `json:"snippet"`
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
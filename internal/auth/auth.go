@@ -2,23 +2,49 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
 type userIDContextKey struct{}
+type sessionIDContextKey struct{}
+
+const (
+	accessTokenLifetime  = time.Minute * 15
+	refreshTokenLifetime = time.Hour * 24 * 30
+
+	// Concurrent requests may present the same refresh token before the
+	// rotated one reaches the client, so reuse is tolerated briefly.
+	refreshGracePeriod = time.Minute
+)
 
 var tokenAuth *jwtauth.JWTAuth = jwtauth.New("HS256", []byte(os.Getenv("JWT_SECRET")), nil)
 
-func Sign(userId uint) (string, error) {
-	claims := map[string]any{"user_id": userId}
+// Configure reads the key used to sign tokens from JWT_SECRET again, for when
+// it is set after the program has started.
+func Configure() {
+	tokenAuth = jwtauth.New("HS256", []byte(os.Getenv("JWT_SECRET")), nil)
+}
+
+func stores(r *http.Request) store.Store {
+	return store.FromContext(r.Context())
+}
+
+func Sign(userId, sessionID uint) (string, error) {
+	claims := map[string]any{"user_id": userId, "session_id": sessionID}
 	jwtauth.SetIssuedNow(claims)
-	jwtauth.SetExpiryIn(claims, time.Hour*24*7)
+	jwtauth.SetExpiryIn(claims, accessTokenLifetime)
 	_, tokenString, err := tokenAuth.Encode(claims)
 	return tokenString, err
 }
@@ -30,48 +56,130 @@ func Verifier() func(http.Handler) http.Handler {
 func Authenticator() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiToken, ok := getBearerAPIToken(r); ok {
+				authenticateAPIToken(w, r, next, apiToken)
+				return
+			}
+
 			token, _, err := jwtauth.FromContext(r.Context())
 
-			if err == nil {
-				if token == nil || jwt.Validate(token, tokenAuth.ValidateOptions()...) != nil {
-					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			if err != nil {
+				userID, sessionID, err := RefreshSession(w, r)
+
+				if err != nil {
+					next.ServeHTTP(w, r)
 					return
 				}
 
-				userID, ok := token.Get("user_id")
+				next.ServeHTTP(w, r.WithContext(withSession(r.Context(), userID, sessionID)))
+				return
+			}
 
-				if !ok {
-					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-					return
-				}
+			if token == nil || jwt.Validate(token, tokenAuth.ValidateOptions()...) != nil {
+				problem.Error(w, r, http.StatusUnauthorized)
+				return
+			}
+
+			userID, ok := token.Get("user_id")
+
+			if !ok {
+				problem.Error(w, r, http.StatusUnauthorized)
+				return
+			}
+
+			sessionID, ok := token.Get("session_id")
+
+			if !ok {
+				clearCookies(w)
+				problem.Error(w, r, http.StatusUnauthorized)
+				return
+			}
+
+			active, err := stores(r).Sessions.GetSessionActive(r.Context(), int64(sessionID.(float64)), int64(userID.(float64)))
 
-				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDContextKey{}, uint(userID.(float64)))))
-			} else {
-				next.ServeHTTP(w, r)
+			if err != nil {
+				problem.Error(w, r, http.StatusInternalServerError)
+				return
 			}
+
+			if !active {
+				clearCookies(w)
+				problem.Error(w, r, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withSession(r.Context(), uint(userID.(float64)), uint(sessionID.(float64)))))
 		})
 	}
 }
 
+func withSession(ctx context.Context, userID, sessionID uint) context.Context {
+	ctx = context.WithValue(ctx, userIDContextKey{}, userID)
+
+	if sessionID == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, sessionIDContextKey{}, sessionID)
+}
+
+func contextWithAPIToken(ctx context.Context, token apiTokenContext) context.Context {
+	return context.WithValue(ctx, apiTokenContextKey{}, token)
+}
+
 func GetUserID(r *http.Request) (uint, bool) {
 	userID, ok := r.Context().Value(userIDContextKey{}).(uint)
 	return userID, ok
 }
 
-func CheckUserID(r *http.Request, targetUserID uint) bool {
-	userID, ok := GetUserID(r)
+func GetSessionID(r *http.Request) (uint, bool) {
+	sessionID, ok := r.Context().Value(sessionIDContextKey{}).(uint)
+	return sessionID, ok
+}
+
+// GenerateToken returns a random URL-safe token along with its hash, which is
+// what gets stored.
+func GenerateToken() (token, tokenHash string, err error) {
+	buf := make([]byte, 32)
+	_, err = rand.Read(buf)
 
-	if ok && userID == targetUserID {
-		return true
+	if err != nil {
+		return
 	}
 
-	user, err := db.GetUser(int64(userID))
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	tokenHash = HashToken(token)
 
-	return err == nil && user.Role == "admin"
+	return
 }
 
-func SignInUser(w http.ResponseWriter, userID uint) (err error) {
-	token, err := Sign(userID)
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func clientUserAgent(r *http.Request) string {
+	userAgent := r.UserAgent()
+
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	return userAgent
+}
+
+func setAccessCookie(w http.ResponseWriter, userID, sessionID uint) (err error) {
+	token, err := Sign(userID, sessionID)
 
 	if err != nil {
 		return
@@ -82,7 +190,7 @@ func SignInUser(w http.ResponseWriter, userID uint) (err error) {
 		Value:    token,
 		Secure:   true,
 		HttpOnly: true,
-		MaxAge:   60 * 60 * 24 * 7,
+		MaxAge:   int(accessTokenLifetime.Seconds()),
 		SameSite: http.SameSiteStrictMode,
 		Path:     "/",
 	})
@@ -90,7 +198,19 @@ func SignInUser(w http.ResponseWriter, userID uint) (err error) {
 	return
 }
 
-func SignOutUser(w http.ResponseWriter) {
+func setRefreshCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh",
+		Value:    token,
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   int(refreshTokenLifetime.Seconds()),
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/api",
+	})
+}
+
+func clearCookies(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "jwt",
 		Value:    "",
@@ -100,4 +220,147 @@ func SignOutUser(w http.ResponseWriter) {
 		SameSite: http.SameSiteStrictMode,
 		Path:     "/",
 	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh",
+		Value:    "",
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   -1,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/api",
+	})
+}
+
+// SetFlowCookie stores claims for a multi-step flow such as a login challenge
+// in a signed cookie named name, which also scopes the claims to that flow.
+func SetFlowCookie(w http.ResponseWriter, name, path string, lifetime time.Duration, claims map[string]any) (err error) {
+	claims["flow"] = name
+	jwtauth.SetIssuedNow(claims)
+	jwtauth.SetExpiryIn(claims, lifetime)
+	_, token, err := tokenAuth.Encode(claims)
+
+	if err != nil {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    token,
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   int(lifetime.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+		Path:     path,
+	})
+
+	return
+}
+
+func GetFlowCookie(r *http.Request, name string) (claims map[string]any, ok bool) {
+	cookie, err := r.Cookie(name)
+
+	if err != nil {
+		return
+	}
+
+	token, err := jwtauth.VerifyToken(tokenAuth, cookie.Value)
+
+	if err != nil || jwt.Validate(token, tokenAuth.ValidateOptions()...) != nil {
+		return
+	}
+
+	claims = token.PrivateClaims()
+
+	if claims["flow"] != name {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func ClearFlowCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   -1,
+		SameSite: http.SameSiteLaxMode,
+		Path:     path,
+	})
+}
+
+// RefreshSession exchanges the refresh token cookie for a new access token and
+// a new refresh token, which invalidates the one presented.
+func RefreshSession(w http.ResponseWriter, r *http.Request) (userID, sessionID uint, err error) {
+	cookie, err := r.Cookie("refresh")
+
+	if err != nil {
+		return
+	}
+
+	token, tokenHash, err := GenerateToken()
+
+	if err != nil {
+		return
+	}
+
+	session, user, err := stores(r).Sessions.RotateSession(r.Context(), HashToken(cookie.Value), tokenHash, clientUserAgent(r), ClientIP(r), time.Now().Add(refreshTokenLifetime), refreshGracePeriod)
+
+	if err == store.ErrSessionReused {
+		err = nil
+	} else if err == nil {
+		setRefreshCookie(w, token)
+	} else {
+		if err == store.ErrNotFound {
+			clearCookies(w)
+		}
+
+		return
+	}
+
+	userID, sessionID = uint(user), uint(session)
+	err = setAccessCookie(w, userID, sessionID)
+
+	return
+}
+
+func SignInUser(w http.ResponseWriter, r *http.Request, userID uint) (err error) {
+	token, tokenHash, err := GenerateToken()
+
+	if err != nil {
+		return
+	}
+
+	sessionID, err := stores(r).Sessions.CreateSession(r.Context(), int64(userID), tokenHash, clientUserAgent(r), ClientIP(r), time.Now().Add(refreshTokenLifetime))
+
+	if err != nil {
+		return
+	}
+
+	err = setAccessCookie(w, userID, uint(sessionID))
+
+	if err != nil {
+		return
+	}
+
+	setRefreshCookie(w, token)
+
+	return
+}
+
+// SignOutUser revokes the session of the current request, if any, and clears
+// the session cookies.
+func SignOutUser(w http.ResponseWriter, r *http.Request) (err error) {
+	clearCookies(w)
+
+	userID, ok := GetUserID(r)
+	sessionID, hasSession := GetSessionID(r)
+
+	if ok && hasSession {
+		err = stores(r).Sessions.RevokeSession(r.Context(), int64(userID), int64(sessionID))
+	}
+
+	return
 }
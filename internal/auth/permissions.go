@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/themintchoco/cvwo/internal/problem"
+)
+
+type Capability string
+
+const (
+	PostEditAny         Capability = "post.edit.any"
+	PostDeleteAny       Capability = "post.delete.any"
+	CommentEditAny      Capability = "comment.edit.any"
+	CommentDeleteAny    Capability = "comment.delete.any"
+	TagEdit             Capability = "tag.edit"
+	TagModeratorsManage Capability = "tag.moderators.manage"
+	UserEditAny         Capability = "user.edit.any"
+	UserDeleteAny       Capability = "user.delete.any"
+	UserRoleAssign      Capability = "user.role.assign"
+	UserSuspend         Capability = "user.suspend"
+	UserWarn            Capability = "user.warn"
+	ReportView          Capability = "report.view"
+	ReportResolve       Capability = "report.resolve"
+	SettingsManage      Capability = "settings.manage"
+	LockoutManage       Capability = "lockout.manage"
+	RateLimitExempt     Capability = "ratelimit.exempt"
+)
+
+var roleCapabilities = map[string][]Capability{
+	"member": {},
+	"moderator": {
+		PostDeleteAny,
+		CommentDeleteAny,
+		UserSuspend,
+		UserWarn,
+		ReportView,
+		ReportResolve,
+	},
+	"admin": {
+		PostEditAny,
+		PostDeleteAny,
+		CommentEditAny,
+		CommentDeleteAny,
+		TagEdit,
+		TagModeratorsManage,
+		UserEditAny,
+		UserDeleteAny,
+		UserRoleAssign,
+		UserSuspend,
+		UserWarn,
+		ReportView,
+		ReportResolve,
+		SettingsManage,
+		LockoutManage,
+		RateLimitExempt,
+	},
+}
+
+// roleRanks orders the roles, so that users can only moderate those below
+// them.
+var roleRanks = map[string]int{
+	"member":    0,
+	"moderator": 1,
+	"admin":     2,
+}
+
+// Capabilities granted to tag moderators, only within the tags they moderate.
+var tagModeratorCapabilities = []Capability{
+	PostDeleteAny,
+	CommentDeleteAny,
+	TagEdit,
+}
+
+func IsRole(role string) bool {
+	_, ok := roleCapabilities[role]
+	return ok
+}
+
+func RoleCapabilities(role string) []Capability {
+	return roleCapabilities[role]
+}
+
+func hasCapability(capabilities []Capability, capability Capability) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+func GetCapabilities(r *http.Request) []Capability {
+	userID, ok := GetUserID(r)
+
+	if !ok {
+		return nil
+	}
+
+	role, err := stores(r).Users.GetUserRole(r.Context(), int64(userID))
+
+	if err != nil {
+		return nil
+	}
+
+	if role != "member" && RoleRequiresTwoFactor(r.Context(), role) {
+		_, enabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+		if err != nil || !enabled {
+			return roleCapabilities["member"]
+		}
+	}
+
+	return roleCapabilities[role]
+}
+
+func HasCapability(r *http.Request, capability Capability) bool {
+	return hasCapability(GetCapabilities(r), capability)
+}
+
+func HasTagCapability(r *http.Request, tagID uint, capability Capability) bool {
+	if HasCapability(r, capability) {
+		return true
+	}
+
+	userID, ok := GetUserID(r)
+
+	if !ok || !hasCapability(tagModeratorCapabilities, capability) {
+		return false
+	}
+
+	moderator, err := stores(r).Tags.GetTagModerator(r.Context(), int64(tagID), int64(userID))
+
+	return err == nil && moderator
+}
+
+func HasPostCapability(r *http.Request, postID uint, capability Capability) bool {
+	if HasCapability(r, capability) {
+		return true
+	}
+
+	userID, ok := GetUserID(r)
+
+	if !ok || !hasCapability(tagModeratorCapabilities, capability) {
+		return false
+	}
+
+	moderator, err := stores(r).Tags.GetPostTagModerator(r.Context(), int64(postID), int64(userID))
+
+	return err == nil && moderator
+}
+
+func IsUser(r *http.Request, targetUserID uint) bool {
+	userID, ok := GetUserID(r)
+	return ok && userID == targetUserID
+}
+
+// Outranks reports whether the signed in user has a higher role than role,
+// as is needed to warn or suspend a user with that role.
+func Outranks(r *http.Request, role string) bool {
+	userID, ok := GetUserID(r)
+
+	if !ok {
+		return false
+	}
+
+	actorRole, err := stores(r).Users.GetUserRole(r.Context(), int64(userID))
+
+	return err == nil && roleRanks[actorRole] > roleRanks[role]
+}
+
+// Deny rejects a request that the user may not make, with 401 Unauthorized if
+// no user is signed in, or 403 Forbidden if the signed in user lacks the
+// permission.
+func Deny(w http.ResponseWriter, r *http.Request) {
+	if _, ok := GetUserID(r); !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	problem.Error(w, r, http.StatusForbidden)
+}
+
+func RequireCapability(capability Capability) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasCapability(r, capability) {
+				Deny(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RejectSuspended rejects requests that modify data from users with an active
+// suspension. Tokens issued before the suspension remain valid for reading.
+func RejectSuspended() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+
+			if !ok || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			suspended, err := stores(r).Moderation.GetUserSuspended(r.Context(), int64(userID))
+
+			if err != nil {
+				problem.Error(w, r, http.StatusInternalServerError)
+				return
+			}
+
+			if suspended {
+				problem.Error(w, r, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	// APITokenPrefix makes personal access tokens recognisable, both to the
+	// Authenticator and to secret scanners.
+	APITokenPrefix = "fpat_"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+type apiTokenContextKey struct{}
+
+type apiTokenContext struct {
+	ID     int64
+	Scopes []string
+}
+
+func GenerateAPIToken() (token, tokenHash string, err error) {
+	token, _, err = GenerateToken()
+
+	if err != nil {
+		return
+	}
+
+	token = APITokenPrefix + token
+	tokenHash = HashToken(token)
+
+	return
+}
+
+func getBearerAPIToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") || !strings.HasPrefix(token, APITokenPrefix) {
+		return "", false
+	}
+
+	return token, true
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticateAPIToken serves a request authenticated by a personal access
+// token, enforcing its scopes.
+func authenticateAPIToken(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	tokenID, userID, scopes, err := stores(r).Sessions.GetAPITokenUser(r.Context(), HashToken(token))
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	if !hasScope(scopes, ScopeWrite) && !isSafeMethod(r.Method) {
+		problem.Error(w, r, http.StatusForbidden)
+		return
+	}
+
+	err = stores(r).Sessions.UpdateAPITokenLastUsed(r.Context(), tokenID, ClientIP(r))
+
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	ctx := withSession(r.Context(), uint(userID), 0)
+	ctx = contextWithAPIToken(ctx, apiTokenContext{ID: tokenID, Scopes: scopes})
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func IsAPIToken(r *http.Request) bool {
+	_, ok := r.Context().Value(apiTokenContextKey{}).(apiTokenContext)
+	return ok
+}
+
+// StillAuthenticated reports whether the session or personal access token that
+// r was authenticated with is still active, for long-lived requests that
+// outlast the check made when they began. Anonymous requests always are.
+func StillAuthenticated(r *http.Request) (bool, error) {
+	userID, ok := GetUserID(r)
+
+	if !ok {
+		return true, nil
+	}
+
+	if apiToken, ok := r.Context().Value(apiTokenContextKey{}).(apiTokenContext); ok {
+		token, _ := getBearerAPIToken(r)
+		tokenID, _, _, err := stores(r).Sessions.GetAPITokenUser(r.Context(), HashToken(token))
+
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+
+		return err == nil && tokenID == apiToken.ID, err
+	}
+
+	sessionID, ok := GetSessionID(r)
+
+	if !ok {
+		return false, nil
+	}
+
+	return stores(r).Sessions.GetSessionActive(r.Context(), int64(sessionID), int64(userID))
+}
+
+// RequireSession rejects requests authenticated by a personal access token,
+// for account management that should only be done interactively.
+func RequireSession() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsAPIToken(r) {
+				problem.Error(w, r, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
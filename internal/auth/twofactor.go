@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	TwoFactorRolesSetting = "twoFactorRoles"
+
+	twoFactorChallengeLifetime = time.Minute * 5
+	recoveryCodeCount          = 10
+)
+
+// StartTwoFactorChallenge records that userID has entered the correct
+// password, pending a second factor, in a short-lived cookie. Each challenge
+// is given a random ID so that failed attempts at it can be counted.
+func StartTwoFactorChallenge(w http.ResponseWriter, userID uint) error {
+	challenge, _, err := GenerateToken()
+
+	if err != nil {
+		return err
+	}
+
+	return SetFlowCookie(w, "two_factor", "/api/auth", twoFactorChallengeLifetime, map[string]any{
+		"user_id":   userID,
+		"challenge": challenge,
+	})
+}
+
+func GetTwoFactorChallenge(r *http.Request) (userID uint, challenge string, ok bool) {
+	claims, ok := GetFlowCookie(r, "two_factor")
+
+	if !ok {
+		return
+	}
+
+	id, ok := claims["user_id"].(float64)
+
+	if !ok {
+		return
+	}
+
+	challenge, ok = claims["challenge"].(string)
+
+	return uint(id), challenge, ok
+}
+
+func ClearTwoFactorChallenge(w http.ResponseWriter) {
+	ClearFlowCookie(w, "two_factor", "/api/auth")
+}
+
+func GetTwoFactorRoles(ctx context.Context) (roles []string, err error) {
+	err = store.FromContext(ctx).Settings.GetSetting(ctx, TwoFactorRolesSetting, &roles)
+
+	if err == store.ErrNotFound {
+		err = nil
+	}
+
+	return
+}
+
+// RoleRequiresTwoFactor reports whether the admin policy requires users with
+// role to enrol in two-factor authentication before using its capabilities.
+func RoleRequiresTwoFactor(ctx context.Context, role string) bool {
+	roles, err := GetTwoFactorRoles(ctx)
+
+	if err != nil {
+		// Fail closed for privileged roles if the policy cannot be read.
+		return role != "member"
+	}
+
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyTwoFactorCode checks a TOTP code or an unused recovery code for
+// userID, consuming it if valid.
+func VerifyTwoFactorCode(ctx context.Context, userID uint, code string) (ok bool, err error) {
+	secret, enabled, _, err := store.FromContext(ctx).Users.GetUserTwoFactor(ctx, int64(userID))
+
+	if err != nil || secret == nil {
+		return
+	}
+
+	if step, valid := ValidateTOTP(*secret, code, time.Now()); valid {
+		err = store.FromContext(ctx).Users.UseUserTwoFactorStep(ctx, int64(userID), step)
+
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	if !enabled {
+		return
+	}
+
+	err = store.FromContext(ctx).Users.UseRecoveryCode(ctx, int64(userID), hashRecoveryCode(code))
+
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return HashToken(code)
+}
+
+// GenerateRecoveryCodes returns a fresh set of recovery codes, formatted for
+// display, along with the hashes to store.
+func GenerateRecoveryCodes() (codes, hashes []string, err error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	buf := make([]byte, 10)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		_, err = rand.Read(buf)
+
+		if err != nil {
+			return
+		}
+
+		var code strings.Builder
+
+		for j, b := range buf {
+			if j == 5 {
+				code.WriteByte('-')
+			}
+
+			code.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashRecoveryCode(code.String()))
+	}
+
+	return
+}
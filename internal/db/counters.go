@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/stephenafamo/bob/dialect/mysql/dialect"
+	"github.com/stephenafamo/bob/dialect/mysql/dm"
+	"github.com/stephenafamo/bob/dialect/mysql/im"
+	"github.com/stephenafamo/bob/dialect/mysql/sm"
+	"github.com/stephenafamo/bob/dialect/mysql/um"
+)
+
+// adjustCount adds delta to a counter column of a row. Counters are kept in
+// the same transaction as the change they count.
+func adjustCount(ctx context.Context, table, column string, id int64, delta int) (err error) {
+	updateArgs := []bob.Mod[*dialect.UpdateQuery]{
+		um.Table(table),
+		um.SetCol(column).To(mysql.Quote(column).OP("+", mysql.Arg(delta))),
+	}
+
+	// A post is not updated when its counters change. PostgreSQL's trigger
+	// ignores them, but MySQL only leaves updated_at alone if it is set.
+	if table == "posts" && driver == DriverMySQL {
+		updateArgs = append(updateArgs, um.SetCol("updated_at").To(mysql.Quote("updated_at")))
+	}
+
+	updateArgs = append(updateArgs, um.Where(mysql.Quote("id").EQ(mysql.Arg(id))))
+
+	_, err = queryExec(ctx, updateQuery(updateArgs...))
+
+	return
+}
+
+// reactionTable describes where the reactions to posts or comments are kept.
+type reactionTable struct {
+	// kind is the type of the reactions that can be given.
+	kind      string
+	table     string
+	reactions string
+	column    string
+	// counts holds the number of each reaction given to a post or comment.
+	counts string
+	// total is the column of table that counts all reactions, if any.
+	total string
+}
+
+var (
+	postReactionTable    = reactionTable{"post", "posts", "post_reactions", "post_id", "post_reaction_counts", "reaction_count"}
+	commentReactionTable = reactionTable{"comment", "comments", "comment_reactions", "comment_id", "comment_reaction_counts", ""}
+)
+
+// setReaction replaces the reaction of a user to a post or comment, removing
+// it if reaction is empty, and updates the counts to match. Nothing happens if
+// there is no such post, comment or reaction.
+func setReaction(ctx context.Context, t reactionTable, userID, targetID int64, reaction string) error {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		// Locking the post or comment orders concurrent changes to its
+		// reactions, so that each is counted against the one before it.
+		err = queryOne(ctx,
+			selectQuery(
+				sm.Columns("id"),
+				sm.From(t.table),
+				sm.Where(mysql.Quote("id").EQ(mysql.Arg(targetID))),
+				sm.ForUpdate()),
+			&targetID,
+		)
+
+		if err == ErrNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return
+		}
+
+		var previous, next *int64
+		mine := mysql.And(
+			mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+			mysql.Quote(t.column).EQ(mysql.Arg(targetID)))
+
+		err = queryOne(ctx,
+			selectQuery(
+				sm.Columns("reaction_id"),
+				sm.From(t.reactions),
+				sm.Where(mine)),
+			&previous,
+		)
+
+		if err != nil && err != ErrNotFound {
+			return
+		}
+
+		if reaction != "" {
+			err = queryOne(ctx,
+				selectQuery(
+					sm.Columns("id"),
+					sm.From("reactions"),
+					sm.Where(mysql.And(
+						mysql.Quote("name").EQ(mysql.Arg(reaction)),
+						mysql.Quote("type").EQ(mysql.Arg(t.kind))))),
+				&next,
+			)
+
+			if err == ErrNotFound {
+				return nil
+			}
+
+			if err != nil {
+				return
+			}
+		}
+
+		switch {
+		case previous == nil && next == nil:
+			return nil
+		case previous == nil:
+			_, err = queryExec(ctx,
+				insertQuery(
+					im.Into(t.reactions, "user_id", t.column, "reaction_id"),
+					im.Values(mysql.Arg(userID, targetID, *next))),
+			)
+		case next == nil:
+			_, err = queryExec(ctx,
+				deleteQuery(
+					dm.From(t.reactions),
+					dm.Where(mine)),
+			)
+		case *previous == *next:
+			return nil
+		default:
+			_, err = queryExec(ctx,
+				updateQuery(
+					um.Table(t.reactions),
+					um.SetCol("reaction_id").ToArg(*next),
+					um.Where(mine)),
+			)
+		}
+
+		if err != nil {
+			return
+		}
+
+		if previous != nil {
+			_, err = queryExec(ctx, increment(t.counts, []string{t.column, "reaction_id"}, "count", -1, targetID, *previous))
+
+			if err != nil {
+				return
+			}
+		}
+
+		if next != nil {
+			_, err = queryExec(ctx, increment(t.counts, []string{t.column, "reaction_id"}, "count", 1, targetID, *next))
+
+			if err != nil {
+				return
+			}
+		}
+
+		if t.total != "" && (previous == nil || next == nil) {
+			delta := 1
+
+			if next == nil {
+				delta = -1
+			}
+
+			err = adjustCount(ctx, t.table, t.total, targetID, delta)
+		}
+
+		return
+	})
+}
+
+// ReconcileCounters recounts every counter from the rows it counts, in case
+// they have drifted.
+func ReconcileCounters() (err error) {
+	return WithTx(migrateCtx, func(ctx context.Context) (err error) {
+		statements := []bob.Query{
+			updateQuery(
+				um.Table("users"),
+				um.SetCol("post_count").To(mysql.Raw("(SELECT COUNT(*) FROM posts p WHERE p.user_id = users.id AND p.deleted_at IS NULL)")),
+				um.SetCol("comment_count").To(mysql.Raw("(SELECT COUNT(*) FROM comments c WHERE c.user_id = users.id AND c.deleted_at IS NULL)"))),
+			updateQuery(
+				um.Table("posts"),
+				um.SetCol("comment_count").To(mysql.Raw("(SELECT COUNT(*) FROM comments c WHERE c.post_id = posts.id AND c.deleted_at IS NULL)")),
+				um.SetCol("reaction_count").To(mysql.Raw("(SELECT COUNT(*) FROM post_reactions pr WHERE pr.post_id = posts.id)")),
+				um.SetCol("updated_at").To(mysql.Quote("updated_at"))),
+			deleteQuery(dm.From("post_reaction_counts")),
+			insertQuery(
+				im.Into("post_reaction_counts", "post_id", "reaction_id", "count"),
+				im.Query(selectQuery(
+					sm.Columns("post_id", "reaction_id", mysql.F("COUNT", 1)),
+					sm.From("post_reactions"),
+					sm.GroupBy("post_id"),
+					sm.GroupBy("reaction_id")))),
+			deleteQuery(dm.From("comment_reaction_counts")),
+			insertQuery(
+				im.Into("comment_reaction_counts", "comment_id", "reaction_id", "count"),
+				im.Query(selectQuery(
+					sm.Columns("comment_id", "reaction_id", mysql.F("COUNT", 1)),
+					sm.From("comment_reactions"),
+					sm.GroupBy("comment_id"),
+					sm.GroupBy("reaction_id")))),
+		}
+
+		// Like migrations, these can take longer than queryTimeout on a large
+		// database.
+		for _, q := range statements {
+			query, args, err := bob.Build(q)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = executor(ctx).ExecContext(ctx, query, args...)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return
+	})
+}
@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/mysql"
 	"github.com/stephenafamo/bob/dialect/mysql/dialect"
@@ -18,24 +23,71 @@ import (
 	"github.com/stephenafamo/bob/dialect/mysql/sm"
 	"github.com/stephenafamo/bob/dialect/mysql/um"
 	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/search"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	db                  bob.DB
-	dbCtx               = context.Background()
-	ErrPasswordMismatch = errors.New("password does not match")
-	ErrNotFound         = errors.New("not found")
+	pool                *sql.DB
+	queryTimeout        = 10 * time.Second
+	ErrPasswordMismatch = store.ErrPasswordMismatch
+	ErrNotFound         = store.ErrNotFound
+	ErrUserSuspended    = store.ErrUserSuspended
+	ErrSessionReused    = store.ErrSessionReused
+	ErrTimeout          = store.ErrTimeout
+	ErrConflict         = store.ErrConflict
 )
 
-func queryMany[T any](q bob.Query, item *T, scan ...any) (items []T, err error) {
+type txContextKey struct{}
+
+// executor returns the transaction started by WithTx for ctx, if any.
+func executor(ctx context.Context) bob.Executor {
+	if tx, ok := ctx.Value(txContextKey{}).(bob.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// withTimeout bounds a single query by queryTimeout, in addition to any
+// deadline or cancellation already on ctx.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
+
+// queryError reports queries that ran out of time as ErrTimeout, and writes
+// rejected for duplicating a unique key as ErrConflict, so that callers can
+// tell them apart from other failures.
+func queryError(ctx context.Context, err error) error {
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	if isDuplicate(err) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func queryMany[T any](ctx context.Context, q bob.Query, item *T, scan ...any) (items []T, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = queryError(ctx, err)
+	}()
+
 	query, args, err := bob.Build(q)
 
 	if err != nil {
 		return
 	}
 
-	rows, err := db.QueryContext(dbCtx, query, args...)
+	rows, err := executor(ctx).QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return
@@ -53,6 +105,12 @@ func queryMany[T any](q bob.Query, item *T, scan ...any) (items []T, err error)
 		items = append(items, *item)
 	}
 
+	err = rows.Err()
+
+	if err != nil {
+		return
+	}
+
 	if len(items) == 0 {
 		items = make([]T, 0)
 	}
@@ -60,14 +118,21 @@ func queryMany[T any](q bob.Query, item *T, scan ...any) (items []T, err error)
 	return
 }
 
-func queryOne(q bob.Query, scan ...any) (err error) {
+func queryOne(ctx context.Context, q bob.Query, scan ...any) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		err = queryError(ctx, err)
+	}()
+
 	query, args, err := bob.Build(q)
 
 	if err != nil {
 		return
 	}
 
-	rows, err := db.QueryContext(dbCtx, query, args...)
+	rows, err := executor(ctx).QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return
@@ -76,7 +141,12 @@ func queryOne(q bob.Query, scan ...any) (err error) {
 	defer rows.Close()
 
 	if !rows.Next() {
-		err = ErrNotFound
+		err = rows.Err()
+
+		if err == nil {
+			err = ErrNotFound
+		}
+
 		return
 	}
 
@@ -85,16 +155,79 @@ func queryOne(q bob.Query, scan ...any) (err error) {
 	return
 }
 
-func queryExec(q bob.Query) (res sql.Result, err error) {
+func queryExec(ctx context.Context, q bob.Query) (res sql.Result, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query, args, err := bob.Build(q)
 
 	if err != nil {
 		return
 	}
 
-	res, err = db.ExecContext(dbCtx, query, args...)
+	res, err = executor(ctx).ExecContext(ctx, query, args...)
 
-	return
+	return res, queryError(ctx, err)
+}
+
+// queryInsert runs an insert and returns the ID of the new row.
+func queryInsert(ctx context.Context, q bob.Query) (id int64, err error) {
+	if driver == DriverPostgres {
+		err = queryOne(ctx, returningID{q}, &id)
+		return
+	}
+
+	res, err := queryExec(ctx, q)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+// WithTx runs fn in a transaction, which is committed if fn succeeds and
+// rolled back otherwise. Queries made with the context passed to fn run in the
+// transaction, and nested calls join the outer one.
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txContextKey{}).(bob.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	err = fn(context.WithValue(ctx, txContextKey{}, tx))
+
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return queryError(ctx, tx.Commit())
+}
+
+type sqlStore struct{}
+
+// NewStore returns the stores backed by the database opened by Connect.
+func NewStore() store.Store {
+	s := sqlStore{}
+
+	return store.Store{
+		Users:         s,
+		Posts:         s,
+		Comments:      s,
+		Tags:          s,
+		Reactions:     s,
+		Sessions:      s,
+		Notifications: s,
+		Moderation:    s,
+		Settings:      s,
+		WithTx:        WithTx,
+	}
 }
 
 func Connect() (err error) {
@@ -103,7 +236,40 @@ func Connect() (err error) {
 	user := os.Getenv("DB_USER")
 	password := os.Getenv("DB_PASSWORD")
 
-	sqlDb, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", user, password, host, database))
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		queryTimeout, err = time.ParseDuration(value)
+
+		if err != nil || queryTimeout <= 0 {
+			return fmt.Errorf("DB_QUERY_TIMEOUT: invalid duration %q", value)
+		}
+	}
+
+	var dsn string
+
+	switch value := os.Getenv("DB_DRIVER"); value {
+	case "", DriverMySQL:
+		driver = DriverMySQL
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", user, password, host, database)
+	case DriverPostgres:
+		sslMode := os.Getenv("DB_SSLMODE")
+
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+
+		driver = DriverPostgres
+		dsn = (&url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(user, password),
+			Host:     host,
+			Path:     database,
+			RawQuery: url.Values{"sslmode": {sslMode}, "timezone": {"UTC"}}.Encode(),
+		}).String()
+	default:
+		return fmt.Errorf("DB_DRIVER: unknown driver %q, expected mysql or postgres", value)
+	}
+
+	sqlDb, err := sql.Open(driver, dsn)
 
 	if err != nil {
 		return
@@ -120,63 +286,51 @@ func Connect() (err error) {
 	sqlDb.SetMaxIdleConns(10)
 
 	db = bob.NewDB(sqlDb)
+	pool = sqlDb
 
 	return
 }
 
-func CreateUser(username, password, role string) (userID int64, err error) {
+func (s sqlStore) CreateUser(ctx context.Context, username, email, password, role string) (userID int64, err error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
 	if err != nil {
 		return
 	}
 
-	res, err := queryExec(
-		mysql.Insert(
-			im.Into("users", "username", "password", "role"),
-			im.Values(mysql.Arg(username, string(hashed), role)),
+	userID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("users", "username", "email", "password", "role"),
+			im.Values(mysql.Arg(username, email, string(hashed), role)),
 		),
 	)
 
-	if err != nil {
-		return
-	}
-
-	userID, err = res.LastInsertId()
-
 	return
 }
 
-func GetUser(userID int64) (user api.User, err error) {
-	err = queryOne(
-		mysql.Select(
+func (s sqlStore) GetUser(ctx context.Context, userID int64) (user api.User, err error) {
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("u", "id"),
 				mysql.Quote("u", "username"),
 				mysql.Quote("u", "role"),
 				mysql.Quote("u", "bio"),
 				mysql.Quote("u", "avatar"),
-				mysql.F("COUNT", "DISTINCT p.id"),
-				mysql.F("COUNT", "DISTINCT c.id"),
+				mysql.Quote("u", "post_count"),
+				mysql.Quote("u", "comment_count"),
 				mysql.Quote("u", "created_at"),
 				mysql.Quote("u", "deleted_at").IsNotNull()),
 			sm.From("users").As("u"),
-			sm.LeftJoin("posts").As("p").On(mysql.And(
-				mysql.Quote("p", "user_id").EQ(mysql.Quote("u", "id")),
-				mysql.Quote("p", "deleted_at").IsNull())),
-			sm.LeftJoin("comments").As("c").On(mysql.And(
-				mysql.Quote("c", "user_id").EQ(mysql.Quote("u", "id")),
-				mysql.Quote("c", "deleted_at").IsNull())),
 			sm.Where(
-				mysql.Quote("u", "id").EQ(mysql.Arg(userID))),
-			sm.GroupBy(mysql.Quote("u", "id"))),
+				mysql.Quote("u", "id").EQ(mysql.Arg(userID)))),
 		&user.ID, &user.Username, &user.Role, &user.Bio, &user.Avatar, &user.PostCount, &user.CommentCount, &user.CreatedAt, &user.Deleted,
 	)
 
 	return
 }
 
-func UpdateUser(userID int64, username, password, role, bio *string) (err error) {
+func (s sqlStore) UpdateUser(ctx context.Context, userID int64, username, password, role, bio *string) (err error) {
 	updateArgs := []bob.Mod[*dialect.UpdateQuery]{um.Table("users")}
 
 	if username != nil {
@@ -203,16 +357,16 @@ func UpdateUser(userID int64, username, password, role, bio *string) (err error)
 
 	updateArgs = append(updateArgs, um.Where(mysql.Quote("id").EQ(mysql.Arg(userID))))
 
-	_, err = queryExec(
-		mysql.Update(updateArgs...),
+	_, err = queryExec(ctx,
+		updateQuery(updateArgs...),
 	)
 
 	return
 }
 
-func UpdateUserAvatar(userID int64, avatar *string) (err error) {
-	_, err = queryExec(
-		mysql.Update(
+func (s sqlStore) UpdateUserAvatar(ctx context.Context, userID int64, avatar *string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
 			um.Table("users"),
 			um.SetCol("avatar").ToArg(avatar),
 			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
@@ -221,9 +375,9 @@ func UpdateUserAvatar(userID int64, avatar *string) (err error) {
 	return
 }
 
-func DeleteUser(userID int64) (err error) {
-	_, err = queryExec(
-		mysql.Update(
+func (s sqlStore) DeleteUser(ctx context.Context, userID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
 			um.Table("users"),
 			um.SetCol("deleted_at").To(mysql.F("NOW")),
 			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
@@ -232,11 +386,11 @@ func DeleteUser(userID int64) (err error) {
 	return
 }
 
-func AuthenticateUser(username, password string) (userID int64, err error) {
+func (s sqlStore) AuthenticateUser(ctx context.Context, username, password string) (userID int64, err error) {
 	var passwordHash string
 
-	err = queryOne(
-		mysql.Select(
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns("id", "password"),
 			sm.From("users"),
 			sm.Where(mysql.And(
@@ -254,20 +408,36 @@ func AuthenticateUser(username, password string) (userID int64, err error) {
 		return
 	}
 
+	// Accounts provisioned through an identity provider have no password.
+	if passwordHash == "" {
+		err = ErrPasswordMismatch
+		return
+	}
+
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
 
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		err = ErrPasswordMismatch
 	}
 
+	if err != nil {
+		return
+	}
+
+	suspended, err := s.GetUserSuspended(ctx, userID)
+
+	if err == nil && suspended {
+		err = ErrUserSuspended
+	}
+
 	return
 }
 
-func GetUsernameAvailability(username string) (available bool, err error) {
+func (s sqlStore) GetUsernameAvailability(ctx context.Context, username string) (available bool, err error) {
 	var count int
 
-	err = queryOne(
-		mysql.Select(
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(mysql.F("COUNT", 1)),
 			sm.From("users"),
 			sm.Where(mysql.Quote("username").EQ(mysql.Arg(username)))),
@@ -283,9 +453,9 @@ func GetUsernameAvailability(username string) (available bool, err error) {
 	return
 }
 
-func GetUserPreferences(userID int64) (preferences any, err error) {
-	err = queryOne(
-		mysql.Select(
+func (s sqlStore) GetUserPreferences(ctx context.Context, userID int64) (preferences any, err error) {
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(mysql.Quote("prefs")),
 			sm.From("users"),
 			sm.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
@@ -301,39 +471,152 @@ func GetUserPreferences(userID int64) (preferences any, err error) {
 	return
 }
 
-func UpdateUserPreferences(userID int64, key string, value any) (err error) {
-	_, err = queryExec(
-		mysql.Update(
-			um.Table("users"),
-			um.SetCol("prefs").To(mysql.F("JSON_SET", mysql.Quote("prefs"), mysql.Arg("$."+key), mysql.Arg(value))),
-			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+func (s sqlStore) GetUsersPreferences(ctx context.Context, userIDs []int64) (preferences map[int64]any, err error) {
+	preferences = make(map[int64]any, len(userIDs))
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	ids := make([]any, len(userIDs))
+
+	for i, userID := range userIDs {
+		ids[i] = userID
+	}
+
+	type userPreferences struct {
+		UserID int64
+		Prefs  []byte
+	}
+
+	var row userPreferences
+
+	rows, err := queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "prefs"),
+			sm.From("users"),
+			sm.Where(mysql.Quote("id").In(mysql.Arg(ids...)))),
+		&row, &row.UserID, &row.Prefs,
 	)
 
+	if err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		var prefs any
+		err = json.Unmarshal(row.Prefs, &prefs)
+
+		if err != nil {
+			return
+		}
+
+		preferences[row.UserID] = prefs
+	}
+
 	return
 }
 
-func CreatePost(userID int64, title, body string) (postID int64, err error) {
-	res, err := queryExec(
-		mysql.Insert(
-			im.Into("posts", "title", "body", "user_id"),
-			im.Values(mysql.Arg(title, body, userID)),
-		),
-	)
+func (s sqlStore) UpdateUserPreferences(ctx context.Context, userID int64, key string, value any) (err error) {
+	prefs, err := jsonSet("prefs", key, value)
 
 	if err != nil {
 		return
 	}
 
-	postID, err = res.LastInsertId()
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("prefs").To(prefs),
+			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+	)
+
+	return
+}
+
+func (s sqlStore) CreatePost(ctx context.Context, userID int64, title, body string) (postID int64, err error) {
+	err = WithTx(ctx, func(ctx context.Context) (err error) {
+		postID, err = queryInsert(ctx,
+			insertQuery(
+				im.Into("posts", "title", "body", "body_text", "user_id"),
+				im.Values(mysql.Arg(title, body, utils.StripTags(body), userID)),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		return adjustCount(ctx, "users", "post_count", userID, 1)
+	})
 
 	return
 }
 
-func GetPosts(limit, offset int64, filters []bob.Expression, sortBy any) (posts []api.Post, err error) {
+// sortedAfter selects the rows that come after a cursor in a list sorted by key,
+// descending, and then by ID.
+func sortedAfter(key, id bob.Expression, cursorKey any, cursorID uint) bob.Expression {
+	return mysql.Or(
+		mysql.Group(key).LT(mysql.Arg(cursorKey)),
+		mysql.And(
+			mysql.Group(key).EQ(mysql.Arg(cursorKey)),
+			mysql.Group(id).GT(mysql.Arg(cursorID))))
+}
+
+func (s sqlStore) GetPosts(ctx context.Context, q store.PostQuery) (posts []api.Post, err error) {
 	var post api.Post
 
-	posts, err = queryMany(
-		mysql.Select(
+	filters := []bob.Expression{mysql.Quote("p", "deleted_at").IsNull()}
+	sortBy := mysql.Quote("p", "created_at")
+
+	if q.Author != "" {
+		filters = append(filters, mysql.Quote("u", "username").EQ(mysql.Arg(q.Author)))
+	}
+
+	if q.TagID != nil {
+		filters = append(filters, mysql.Raw("EXISTS (SELECT 1 FROM post_tags fpt WHERE fpt.post_id = p.id AND fpt.tag_id = ?)", *q.TagID))
+	}
+
+	if q.Search != nil {
+		searchFilters, _ := postSearchFilters(*q.Search)
+		filters = append(filters, searchFilters...)
+	}
+
+	if q.Since != nil {
+		filters = append(filters, mysql.Quote("p", "created_at").GTE(mysql.Arg(*q.Since)))
+	}
+
+	sort := store.PostSort(q.Sort)
+
+	switch sort {
+	case store.PostSortPopular, store.PostSortTop:
+		sortBy = mysql.Quote("p", "reaction_count")
+	case store.PostSortReplies:
+		sortBy = mysql.Quote("p", "comment_count")
+	case store.PostSortHot:
+		sortBy = mysql.Quote("p", "hot_score")
+	}
+
+	if q.After != nil {
+		if q.After.Sort != sort {
+			err = store.ErrInvalidCursor
+			return
+		}
+
+		var after any = q.After.Count
+
+		switch sort {
+		case store.PostSortNew:
+			after = q.After.Time
+		case store.PostSortHot:
+			after = q.After.Score
+		}
+
+		filters = append(filters, sortedAfter(sortBy, mysql.Quote("p", "id"), after, q.After.ID))
+	}
+
+	posts, err = queryMany(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("p", "id"),
 				mysql.Quote("p", "title"),
@@ -345,33 +628,32 @@ func GetPosts(limit, offset int64, filters []bob.Expression, sortBy any) (posts
 				mysql.Quote("u", "avatar"),
 				mysql.Quote("u", "created_at"),
 				mysql.Quote("u", "deleted_at").IsNotNull(),
-				mysql.F("COUNT", "DISTINCT c.id"),
-				mysql.F("COALESCE", mysql.F("GROUP_CONCAT", "DISTINCT t.id"), mysql.S("")),
+				mysql.Quote("p", "comment_count"),
+				mysql.Quote("p", "reaction_count"),
+				mysql.Quote("p", "hot_score"),
+				postTagIDsExpr(mysql.Quote("p", "id")),
 				mysql.Quote("p", "created_at"),
 				mysql.Quote("p", "updated_at"),
+				mysql.Quote("p", "edited_at").IsNotNull(),
+				mysql.Quote("p", "edited_at"),
+				revisionCountExpr("post_revisions", "post_id", mysql.Quote("p", "id")),
 				mysql.Quote("p", "deleted_at").IsNotNull()),
 			sm.From("posts").As("p"),
 			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("p", "user_id")),
-			sm.LeftJoin("comments").As("c").OnEQ(mysql.Quote("c", "post_id"), mysql.Quote("p", "id")),
-			sm.LeftJoin("post_tags").As("pt").OnEQ(mysql.Quote("pt", "post_id"), mysql.Quote("p", "id")),
-			sm.LeftJoin("tags").As("t").OnEQ(mysql.Quote("t", "id"), mysql.Quote("pt", "tag_id")),
-			sm.LeftJoin("post_reactions").As("pr").OnEQ(mysql.Quote("pr", "post_id"), mysql.Quote("p", "id")),
-			sm.Where(mysql.And(
-				append(filters, mysql.Quote("c", "deleted_at").IsNull())...)),
-			sm.GroupBy(mysql.Quote("p", "id")),
+			sm.Where(mysql.And(filters...)),
 			sm.OrderBy(sortBy).Desc(),
 			sm.OrderBy(mysql.Quote("p", "id")).Asc(),
-			sm.Limit(limit),
-			sm.Offset(offset)),
-		&post, &post.ID, &post.Title, &post.Body, &post.Author.ID, &post.Author.Username, &post.Author.Role, &post.Author.Bio, &post.Author.Avatar, &post.Author.CreatedAt, &post.Author.Deleted, &post.CommentCount, &post.Tags, &post.CreatedAt, &post.UpdatedAt, &post.Deleted,
+			sm.Limit(q.Limit),
+			sm.Offset(q.Offset)),
+		&post, &post.ID, &post.Title, &post.Body, &post.Author.ID, &post.Author.Username, &post.Author.Role, &post.Author.Bio, &post.Author.Avatar, &post.Author.CreatedAt, &post.Author.Deleted, &post.CommentCount, &post.ReactionCount, &post.HotScore, &post.Tags, &post.CreatedAt, &post.UpdatedAt, &post.Edited, &post.EditedAt, &post.RevisionCount, &post.Deleted,
 	)
 
 	return
 }
 
-func GetPost(postID int64) (post api.Post, err error) {
-	err = queryOne(
-		mysql.Select(
+func (s sqlStore) GetPost(ctx context.Context, postID int64) (post api.Post, err error) {
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("p", "id"),
 				mysql.Quote("p", "title"),
@@ -383,149 +665,192 @@ func GetPost(postID int64) (post api.Post, err error) {
 				mysql.Quote("u", "avatar"),
 				mysql.Quote("u", "created_at"),
 				mysql.Quote("u", "deleted_at").IsNotNull(),
-				mysql.F("COUNT", "DISTINCT c.id"),
-				mysql.F("COALESCE", mysql.F("GROUP_CONCAT", "DISTINCT t.id"), mysql.S("")),
+				mysql.Quote("p", "comment_count"),
+				mysql.Quote("p", "reaction_count"),
+				mysql.Quote("p", "hot_score"),
+				postTagIDsExpr(mysql.Quote("p", "id")),
 				mysql.Quote("p", "created_at"),
 				mysql.Quote("p", "updated_at"),
+				mysql.Quote("p", "edited_at").IsNotNull(),
+				mysql.Quote("p", "edited_at"),
+				revisionCountExpr("post_revisions", "post_id", mysql.Quote("p", "id")),
 				mysql.Quote("p", "deleted_at").IsNotNull()),
 			sm.From("posts").As("p"),
 			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("p", "user_id")),
-			sm.LeftJoin("comments").As("c").OnEQ(mysql.Quote("c", "post_id"), mysql.Quote("p", "id")),
-			sm.LeftJoin("post_tags").As("pt").OnEQ(mysql.Quote("pt", "post_id"), mysql.Quote("p", "id")),
-			sm.LeftJoin("tags").As("t").OnEQ(mysql.Quote("t", "id"), mysql.Quote("pt", "tag_id")),
-			sm.Where(mysql.Quote("p", "id").EQ(mysql.Arg(postID))),
-			sm.GroupBy(mysql.Quote("p", "id"))),
-		&post.ID, &post.Title, &post.Body, &post.Author.ID, &post.Author.Username, &post.Author.Role, &post.Author.Bio, &post.Author.Avatar, &post.Author.CreatedAt, &post.Author.Deleted, &post.CommentCount, &post.Tags, &post.CreatedAt, &post.UpdatedAt, &post.Deleted,
+			sm.Where(mysql.Quote("p", "id").EQ(mysql.Arg(postID)))),
+		&post.ID, &post.Title, &post.Body, &post.Author.ID, &post.Author.Username, &post.Author.Role, &post.Author.Bio, &post.Author.Avatar, &post.Author.CreatedAt, &post.Author.Deleted, &post.CommentCount, &post.ReactionCount, &post.HotScore, &post.Tags, &post.CreatedAt, &post.UpdatedAt, &post.Edited, &post.EditedAt, &post.RevisionCount, &post.Deleted,
 	)
 
 	return
 }
 
-func UpdatePost(postID int64, title, body string) (err error) {
-	_, err = queryExec(
-		mysql.Update(
-			um.Table("posts"),
-			um.SetCol("title").ToArg(title),
-			um.SetCol("body").ToArg(body),
-			um.Where(mysql.And(
-				mysql.Quote("id").EQ(mysql.Arg(postID)),
-				mysql.Quote("deleted_at").IsNull())),
-		),
-	)
+func (s sqlStore) UpdateHotScores(ctx context.Context, gravity float64, since time.Time) (err error) {
+	score := mysql.Raw("CASE WHEN created_at >= ? THEN (reaction_count + comment_count) / POWER("+hoursSince("created_at")+" + 2, ?) ELSE 0 END", since, gravity)
+
+	updateArgs := []bob.Mod[*dialect.UpdateQuery]{
+		um.Table("posts"),
+		um.SetCol("hot_score").To(score),
+	}
+
+	// As with counters, updated_at is left alone. PostgreSQL's trigger ignores
+	// hot_score.
+	if driver == DriverMySQL {
+		updateArgs = append(updateArgs, um.SetCol("updated_at").To(mysql.Quote("updated_at")))
+	}
+
+	// Posts that have aged out are zeroed once, and then left alone.
+	updateArgs = append(updateArgs, um.Where(mysql.And(
+		mysql.Quote("deleted_at").IsNull(),
+		mysql.Or(
+			mysql.Quote("created_at").GTE(mysql.Arg(since)),
+			mysql.Quote("hot_score").NE(mysql.Arg(0))))))
+
+	_, err = queryExec(ctx, updateQuery(updateArgs...))
 
 	return
 }
 
-func DeletePost(postID int64) (err error) {
-	_, err = queryExec(
-		mysql.Update(
-			um.Table("posts"),
-			um.SetCol("deleted_at").To(mysql.F("NOW")),
-			um.Where(mysql.And(
-				mysql.Quote("id").EQ(mysql.Arg(postID)),
-				mysql.Quote("deleted_at").IsNull())),
-		),
-	)
+// postTagIDsExpr lists the IDs of the tags of a post, comma-separated.
+func postTagIDsExpr(id bob.Expression) bob.Expression {
+	return mysql.Group(selectQuery(
+		sm.Columns(mysql.F("COALESCE", idList("pt.tag_id"), mysql.S(""))),
+		sm.From("post_tags").As("pt"),
+		sm.Where(mysql.Quote("pt", "post_id").EQ(id)),
+	))
+}
 
-	return
+// revisionCountExpr counts the versions of a post or comment: the revisions
+// kept from before each edit, and the current one.
+func revisionCountExpr(table, column string, id bob.Expression) bob.Expression {
+	return mysql.Group(selectQuery(
+		sm.Columns(mysql.F("COUNT", 1)),
+		sm.From(table),
+		sm.Where(mysql.Quote(column).EQ(id)),
+	)).OP("+", mysql.Arg(1))
 }
 
-func CreatePostReaction(userID, postID int64, reaction string) (err error) {
-	_, err = queryExec(
-		mysql.Insert(
-			im.Into("post_reactions", "user_id", "post_id", "reaction_id"),
-			im.Query(mysql.Select(
-				sm.Columns(mysql.Arg(userID), mysql.Arg(postID), "id"),
-				sm.From("reactions"),
-				sm.Where(mysql.And(
-					mysql.Quote("name").EQ(mysql.Arg(reaction)),
-					mysql.Quote("type").EQ(mysql.Arg("post")))),
-			)),
-			im.OnDuplicateKeyUpdate(
-				im.UpdateCol("reaction_id").To("id"),
+// UpdatePost replaces the title and body of a post, keeping the previous
+// version as a revision if either has changed. If tags is not nil, the post's
+// tags are replaced with them, creating any that do not exist yet.
+func (s sqlStore) UpdatePost(ctx context.Context, postID, editorID int64, title, body string, tags []string) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		changed := mysql.And(
+			mysql.Quote("id").EQ(mysql.Arg(postID)),
+			mysql.Quote("deleted_at").IsNull(),
+			mysql.Or(
+				mysql.Quote("title").NE(mysql.Arg(title)),
+				mysql.Quote("body").NE(mysql.Arg(body))))
+
+		_, err = queryExec(ctx,
+			insertQuery(
+				im.Into("post_revisions", "post_id", "editor_id", "title", "body", "created_at"),
+				im.Query(selectQuery(
+					sm.Columns(
+						"id",
+						mysql.F("COALESCE", mysql.Quote("edited_by"), mysql.Quote("user_id")),
+						"title",
+						"body",
+						mysql.F("COALESCE", mysql.Quote("edited_at"), mysql.Quote("created_at"))),
+					sm.From("posts"),
+					sm.Where(changed))),
 			),
-		),
-	)
+		)
 
-	return
-}
+		if err != nil {
+			return
+		}
 
-func GetPostReactions(postID int64) (reactions []api.Reaction, err error) {
-	var reaction api.Reaction
+		_, err = queryExec(ctx,
+			updateQuery(
+				um.Table("posts"),
+				um.SetCol("title").ToArg(title),
+				um.SetCol("body").ToArg(body),
+				um.SetCol("body_text").ToArg(utils.StripTags(body)),
+				um.SetCol("edited_at").To(mysql.F("NOW")),
+				um.SetCol("edited_by").ToArg(editorID),
+				um.Where(changed),
+			),
+		)
 
-	reactions, err = queryMany(
-		mysql.Select(
-			sm.Columns(
-				mysql.Quote("r", "id"),
-				mysql.Quote("r", "name"),
-				mysql.F("COUNT", mysql.Quote("r", "id"))),
-			sm.From("post_reactions").As("pr"),
-			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("pr", "reaction_id")),
-			sm.Where(mysql.Quote("pr", "post_id").EQ(mysql.Arg(postID))),
-			sm.GroupBy(mysql.Quote("r", "id"))),
-		&reaction, &reaction.ID, &reaction.Name, &reaction.Count,
-	)
+		if err != nil || tags == nil {
+			return
+		}
 
-	return
+		return s.SetPostTags(ctx, postID, tags)
+	})
 }
 
-func GetPostReaction(userID, postID int64) (reaction api.Reaction, err error) {
-	err = queryOne(
-		mysql.Select(
-			sm.Columns(
-				mysql.Quote("r", "id"),
-				mysql.Quote("r", "name"),
-				"1"),
-			sm.From("post_reactions").As("pr"),
-			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("pr", "reaction_id")),
-			sm.Where(mysql.And(
-				mysql.Quote("pr", "user_id").EQ(mysql.Arg(userID)),
-				mysql.Quote("pr", "post_id").EQ(mysql.Arg(postID))))),
-		&reaction.ID, &reaction.Name, &reaction.Count,
-	)
+// SetPostTags replaces the tags of a post, creating any that do not exist yet.
+func (s sqlStore) SetPostTags(ctx context.Context, postID int64, tags []string) (err error) {
+	var tagIDs []any
 
-	return
-}
+	if len(tags) > 0 {
+		rows := make([][]bob.Expression, len(tags))
+		names := make([]any, len(tags))
 
-func DeletePostReaction(userID, postID int64) (err error) {
-	_, err = queryExec(
-		mysql.Delete(
-			dm.From("post_reactions"),
-			dm.Where(mysql.And(
-				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
-				mysql.Quote("post_id").EQ(mysql.Arg(postID)))),
-		),
-	)
+		for i, tag := range tags {
+			rows[i] = []bob.Expression{mysql.Arg(tag), mysql.S("gray"), mysql.S("")}
+			names[i] = tag
+		}
 
-	return
-}
+		_, err = queryExec(ctx, insertIgnore("tags", []string{"name", "color", "description"}, rows...))
 
-func CreatePostComment(userID, postID int64, body string) (commentID int64, err error) {
-	res, err := queryExec(
-		mysql.Insert(
-			im.Into("comments", "user_id", "post_id", "body"),
-			im.Values(mysql.Arg(userID, postID, body)),
-		),
+		if err != nil {
+			return
+		}
+
+		var tagID any
+
+		tagIDs, err = queryMany(ctx,
+			selectQuery(
+				sm.Columns("id"),
+				sm.From("tags"),
+				sm.Where(mysql.Quote("name").In(mysql.Arg(names...)))),
+			&tagID, &tagID,
+		)
+
+		if err != nil {
+			return
+		}
+	}
+
+	stale := mysql.Quote("post_id").EQ(mysql.Arg(postID))
+
+	if len(tagIDs) > 0 {
+		stale = mysql.And(stale, mysql.Quote("tag_id").NotIn(mysql.Arg(tagIDs...)))
+	}
+
+	_, err = queryExec(ctx,
+		deleteQuery(
+			dm.From("post_tags"),
+			dm.Where(stale)),
 	)
 
-	if err != nil {
+	if err != nil || len(tagIDs) == 0 {
 		return
 	}
 
-	commentID, err = res.LastInsertId()
+	rows := make([][]bob.Expression, len(tagIDs))
+
+	for i, tagID := range tagIDs {
+		rows[i] = []bob.Expression{mysql.Arg(postID), mysql.Arg(tagID)}
+	}
+
+	_, err = queryExec(ctx, insertIgnore("post_tags", []string{"post_id", "tag_id"}, rows...))
 
 	return
 }
 
-func GetPostComments(limit, offset int64, filters []bob.Expression, sortBy any) (comments []api.Comment, err error) {
-	var comment api.Comment
+// GetPostRevisions returns every version of a post, oldest first, ending with
+// the current one.
+func (s sqlStore) GetPostRevisions(ctx context.Context, postID int64) (revisions []api.Revision, err error) {
+	var revision api.Revision
 
-	comments, err = queryMany(
-		mysql.Select(
+	revisions, err = queryMany(ctx,
+		selectQuery(
 			sm.Columns(
-				mysql.Quote("c", "id"),
-				mysql.Quote("c", "post_id"),
-				mysql.Quote("c", "body"),
+				mysql.Quote("pv", "title"),
+				mysql.Quote("pv", "body"),
 				mysql.Quote("u", "id"),
 				mysql.Quote("u", "username"),
 				mysql.Quote("u", "role"),
@@ -533,29 +858,25 @@ func GetPostComments(limit, offset int64, filters []bob.Expression, sortBy any)
 				mysql.Quote("u", "avatar"),
 				mysql.Quote("u", "created_at"),
 				mysql.Quote("u", "deleted_at").IsNotNull(),
-				mysql.Quote("c", "created_at"),
-				mysql.Quote("c", "updated_at"),
-				mysql.Quote("c", "deleted_at").IsNotNull()),
-			sm.From("comments").As("c"),
-			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
-			sm.Where(mysql.And(filters...)),
-			sm.OrderBy(sortBy).Desc(),
-			sm.OrderBy(mysql.Quote("c", "id")).Asc(),
-			sm.Limit(limit),
-			sm.Offset(offset)),
-		&comment, &comment.ID, &comment.PostID, &comment.Body, &comment.Author.ID, &comment.Author.Username, &comment.Author.Role, &comment.Author.Bio, &comment.Author.Avatar, &comment.Author.CreatedAt, &comment.Author.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Deleted,
+				mysql.Quote("pv", "created_at")),
+			sm.From("post_revisions").As("pv"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("pv", "editor_id")),
+			sm.Where(mysql.Quote("pv", "post_id").EQ(mysql.Arg(postID))),
+			sm.OrderBy(mysql.Quote("pv", "id")).Asc()),
+		&revision, &revision.Title, &revision.Body, &revision.Editor.ID, &revision.Editor.Username, &revision.Editor.Role, &revision.Editor.Bio, &revision.Editor.Avatar, &revision.Editor.CreatedAt, &revision.Editor.Deleted, &revision.CreatedAt,
 	)
 
-	return
-}
+	if err != nil {
+		return
+	}
+
+	var current api.Revision
 
-func GetPostComment(commentID int64) (comment api.Comment, err error) {
-	err = queryOne(
-		mysql.Select(
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(
-				mysql.Quote("c", "id"),
-				mysql.Quote("c", "post_id"),
-				mysql.Quote("c", "body"),
+				mysql.Quote("p", "title"),
+				mysql.Quote("p", "body"),
 				mysql.Quote("u", "id"),
 				mysql.Quote("u", "username"),
 				mysql.Quote("u", "role"),
@@ -563,88 +884,458 @@ func GetPostComment(commentID int64) (comment api.Comment, err error) {
 				mysql.Quote("u", "avatar"),
 				mysql.Quote("u", "created_at"),
 				mysql.Quote("u", "deleted_at").IsNotNull(),
-				mysql.Quote("c", "created_at"),
-				mysql.Quote("c", "updated_at"),
-				mysql.Quote("c", "deleted_at").IsNotNull()),
-			sm.From("comments").As("c"),
-			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
-			sm.Where(mysql.Quote("c", "id").EQ(mysql.Arg(commentID)))),
-		&comment.ID, &comment.PostID, &comment.Body, &comment.Author.ID, &comment.Author.Username, &comment.Author.Role, &comment.Author.Bio, &comment.Author.Avatar, &comment.Author.CreatedAt, &comment.Author.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Deleted,
+				mysql.F("COALESCE", mysql.Quote("p", "edited_at"), mysql.Quote("p", "created_at"))),
+			sm.From("posts").As("p"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.F("COALESCE", mysql.Quote("p", "edited_by"), mysql.Quote("p", "user_id"))),
+			sm.Where(mysql.Quote("p", "id").EQ(mysql.Arg(postID)))),
+		&current.Title, &current.Body, &current.Editor.ID, &current.Editor.Username, &current.Editor.Role, &current.Editor.Bio, &current.Editor.Avatar, &current.Editor.CreatedAt, &current.Editor.Deleted, &current.CreatedAt,
 	)
 
-	return
-}
-
-func UpdatePostComment(commentID int64, body string) (err error) {
-	_, err = queryExec(
-		mysql.Update(
-			um.Table("comments"),
-			um.SetCol("body").ToArg(body),
-			um.Where(mysql.And(
-				mysql.Quote("id").EQ(mysql.Arg(commentID)),
-				mysql.Quote("deleted_at").IsNull())),
-		),
+	if err != nil {
+		return
+	}
+
+	current.Current = true
+	revisions = append(revisions, current)
+
+	for i := range revisions {
+		revisions[i].Number = uint(i + 1)
+	}
+
+	return
+}
+
+func (s sqlStore) DeletePost(ctx context.Context, postID int64) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		var userID int64
+
+		err = queryOne(ctx,
+			selectQuery(
+				sm.Columns("user_id"),
+				sm.From("posts"),
+				sm.Where(mysql.And(
+					mysql.Quote("id").EQ(mysql.Arg(postID)),
+					mysql.Quote("deleted_at").IsNull())),
+				sm.ForUpdate()),
+			&userID,
+		)
+
+		if err == ErrNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return
+		}
+
+		_, err = queryExec(ctx,
+			updateQuery(
+				um.Table("posts"),
+				um.SetCol("deleted_at").To(mysql.F("NOW")),
+				um.Where(mysql.Quote("id").EQ(mysql.Arg(postID))),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		return adjustCount(ctx, "users", "post_count", userID, -1)
+	})
+}
+
+func (s sqlStore) CreatePostReaction(ctx context.Context, userID, postID int64, reaction string) (err error) {
+	return setReaction(ctx, postReactionTable, userID, postID, reaction)
+}
+
+func (s sqlStore) GetPostReactions(ctx context.Context, postID int64) (reactions []api.Reaction, err error) {
+	var reaction api.Reaction
+
+	reactions, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("r", "id"),
+				mysql.Quote("r", "name"),
+				mysql.Quote("prc", "count")),
+			sm.From("post_reaction_counts").As("prc"),
+			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("prc", "reaction_id")),
+			sm.Where(mysql.And(
+				mysql.Quote("prc", "post_id").EQ(mysql.Arg(postID)),
+				mysql.Quote("prc", "count").GT(mysql.Arg(0))))),
+		&reaction, &reaction.ID, &reaction.Name, &reaction.Count,
 	)
 
 	return
 }
 
-func DeletePostComment(commentID int64) (err error) {
-	_, err = queryExec(
-		mysql.Update(
-			um.Table("comments"),
-			um.SetCol("deleted_at").To(mysql.F("NOW")),
-			um.Where(mysql.And(
-				mysql.Quote("id").EQ(mysql.Arg(commentID)),
-				mysql.Quote("deleted_at").IsNull())),
-		),
+func (s sqlStore) GetPostReaction(ctx context.Context, userID, postID int64) (reaction api.Reaction, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("r", "id"),
+				mysql.Quote("r", "name"),
+				"1"),
+			sm.From("post_reactions").As("pr"),
+			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("pr", "reaction_id")),
+			sm.Where(mysql.And(
+				mysql.Quote("pr", "user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("pr", "post_id").EQ(mysql.Arg(postID))))),
+		&reaction.ID, &reaction.Name, &reaction.Count,
 	)
 
 	return
 }
 
-func CreateCommentReaction(userID, commentID int64, reaction string) (err error) {
-	_, err = queryExec(
-		mysql.Insert(
-			im.Into("comment_reactions", "user_id", "comment_id", "reaction_id"),
-			im.Query(mysql.Select(
-				sm.Columns(mysql.Arg(userID), mysql.Arg(commentID), "id"),
-				sm.From("reactions"),
-				sm.Where(mysql.And(
-					mysql.Quote("name").EQ(mysql.Arg(reaction)),
-					mysql.Quote("type").EQ(mysql.Arg("comment")))),
-			)),
-			im.OnDuplicateKeyUpdate(
-				im.UpdateCol("reaction_id").To("id"),
+func (s sqlStore) DeletePostReaction(ctx context.Context, userID, postID int64) (err error) {
+	return setReaction(ctx, postReactionTable, userID, postID, "")
+}
+
+func (s sqlStore) CreatePostComment(ctx context.Context, userID, postID int64, parentID *int64, depth uint, body string) (commentID int64, err error) {
+	err = WithTx(ctx, func(ctx context.Context) (err error) {
+		commentID, err = queryInsert(ctx,
+			insertQuery(
+				im.Into("comments", "user_id", "post_id", "parent_id", "depth", "body", "body_text"),
+				im.Values(mysql.Arg(userID, postID, parentID, depth, body, utils.StripTags(body))),
 			),
-		),
+		)
+
+		if err != nil {
+			return
+		}
+
+		err = adjustCount(ctx, "posts", "comment_count", postID, 1)
+
+		if err != nil {
+			return
+		}
+
+		return adjustCount(ctx, "users", "comment_count", userID, 1)
+	})
+
+	return
+}
+
+func (s sqlStore) GetPostComments(ctx context.Context, q store.CommentQuery) (comments []api.Comment, err error) {
+	var comment api.Comment
+
+	filters := []bob.Expression{mysql.Quote("c", "deleted_at").IsNull()}
+
+	if q.Threaded {
+		filters = []bob.Expression{mysql.Or(
+			mysql.Quote("c", "deleted_at").IsNull(),
+			mysql.Raw("EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)"),
+		)}
+
+		if q.ParentID != nil {
+			filters = append(filters, mysql.Quote("c", "parent_id").EQ(mysql.Arg(*q.ParentID)))
+		} else {
+			filters = append(filters, mysql.Quote("c", "parent_id").IsNull())
+		}
+	}
+
+	if q.PostID != nil {
+		filters = append(filters, mysql.Quote("c", "post_id").EQ(mysql.Arg(*q.PostID)))
+	}
+
+	if q.Author != "" {
+		filters = append(filters, mysql.Quote("u", "username").EQ(mysql.Arg(q.Author)))
+	}
+
+	if q.After != nil {
+		if q.After.Sort != store.PostSortNew {
+			err = store.ErrInvalidCursor
+			return
+		}
+
+		filters = append(filters, sortedAfter(mysql.Quote("c", "created_at"), mysql.Quote("c", "id"), q.After.Time, q.After.ID))
+	}
+
+	comments, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("c", "id"),
+				mysql.Quote("c", "post_id"),
+				mysql.Quote("c", "parent_id"),
+				mysql.Quote("c", "depth"),
+				mysql.Quote("c", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("c", "created_at"),
+				mysql.Quote("c", "updated_at"),
+				mysql.Quote("c", "edited_at").IsNotNull(),
+				mysql.Quote("c", "edited_at"),
+				revisionCountExpr("comment_revisions", "comment_id", mysql.Quote("c", "id")),
+				mysql.Quote("c", "deleted_at").IsNotNull()),
+			sm.From("comments").As("c"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
+			sm.Where(mysql.And(filters...)),
+			sm.OrderBy(mysql.Quote("c", "created_at")).Desc(),
+			sm.OrderBy(mysql.Quote("c", "id")).Asc(),
+			sm.Limit(q.Limit),
+			sm.Offset(q.Offset)),
+		&comment, &comment.ID, &comment.PostID, &comment.ParentID, &comment.Depth, &comment.Body, &comment.Author.ID, &comment.Author.Username, &comment.Author.Role, &comment.Author.Bio, &comment.Author.Avatar, &comment.Author.CreatedAt, &comment.Author.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Edited, &comment.EditedAt, &comment.RevisionCount, &comment.Deleted,
+	)
+
+	return
+}
+
+func (s sqlStore) GetPostComment(ctx context.Context, commentID int64) (comment api.Comment, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("c", "id"),
+				mysql.Quote("c", "post_id"),
+				mysql.Quote("c", "parent_id"),
+				mysql.Quote("c", "depth"),
+				mysql.Quote("c", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("c", "created_at"),
+				mysql.Quote("c", "updated_at"),
+				mysql.Quote("c", "edited_at").IsNotNull(),
+				mysql.Quote("c", "edited_at"),
+				revisionCountExpr("comment_revisions", "comment_id", mysql.Quote("c", "id")),
+				mysql.Quote("c", "deleted_at").IsNotNull()),
+			sm.From("comments").As("c"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
+			sm.Where(mysql.Quote("c", "id").EQ(mysql.Arg(commentID)))),
+		&comment.ID, &comment.PostID, &comment.ParentID, &comment.Depth, &comment.Body, &comment.Author.ID, &comment.Author.Username, &comment.Author.Role, &comment.Author.Bio, &comment.Author.Avatar, &comment.Author.CreatedAt, &comment.Author.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Edited, &comment.EditedAt, &comment.RevisionCount, &comment.Deleted,
+	)
+
+	return
+}
+
+func (s sqlStore) GetPostCommentReplies(ctx context.Context, parentIDs []int64) (comments []api.Comment, err error) {
+	var comment api.Comment
+
+	if len(parentIDs) == 0 {
+		return make([]api.Comment, 0), nil
+	}
+
+	ids := make([]any, len(parentIDs))
+
+	for i, parentID := range parentIDs {
+		ids[i] = parentID
+	}
+
+	comments, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("c", "id"),
+				mysql.Quote("c", "post_id"),
+				mysql.Quote("c", "parent_id"),
+				mysql.Quote("c", "depth"),
+				mysql.Quote("c", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("c", "created_at"),
+				mysql.Quote("c", "updated_at"),
+				mysql.Quote("c", "edited_at").IsNotNull(),
+				mysql.Quote("c", "edited_at"),
+				revisionCountExpr("comment_revisions", "comment_id", mysql.Quote("c", "id")),
+				mysql.Quote("c", "deleted_at").IsNotNull()),
+			sm.From("comments").As("c"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
+			sm.Where(mysql.Quote("c", "parent_id").In(mysql.Arg(ids...))),
+			sm.OrderBy(mysql.Quote("c", "created_at")).Asc(),
+			sm.OrderBy(mysql.Quote("c", "id")).Asc()),
+		&comment, &comment.ID, &comment.PostID, &comment.ParentID, &comment.Depth, &comment.Body, &comment.Author.ID, &comment.Author.Username, &comment.Author.Role, &comment.Author.Bio, &comment.Author.Avatar, &comment.Author.CreatedAt, &comment.Author.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Edited, &comment.EditedAt, &comment.RevisionCount, &comment.Deleted,
+	)
+
+	return
+}
+
+// UpdatePostComment replaces the body of a comment, keeping the previous
+// version as a revision. Nothing is recorded if it has not changed.
+func (s sqlStore) UpdatePostComment(ctx context.Context, commentID, editorID int64, body string) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		changed := mysql.And(
+			mysql.Quote("id").EQ(mysql.Arg(commentID)),
+			mysql.Quote("deleted_at").IsNull(),
+			mysql.Quote("body").NE(mysql.Arg(body)))
+
+		_, err = queryExec(ctx,
+			insertQuery(
+				im.Into("comment_revisions", "comment_id", "editor_id", "body", "created_at"),
+				im.Query(selectQuery(
+					sm.Columns(
+						"id",
+						mysql.F("COALESCE", mysql.Quote("edited_by"), mysql.Quote("user_id")),
+						"body",
+						mysql.F("COALESCE", mysql.Quote("edited_at"), mysql.Quote("created_at"))),
+					sm.From("comments"),
+					sm.Where(changed))),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		_, err = queryExec(ctx,
+			updateQuery(
+				um.Table("comments"),
+				um.SetCol("body").ToArg(body),
+				um.SetCol("body_text").ToArg(utils.StripTags(body)),
+				um.SetCol("edited_at").To(mysql.F("NOW")),
+				um.SetCol("edited_by").ToArg(editorID),
+				um.Where(changed),
+			),
+		)
+
+		return
+	})
+}
+
+// GetPostCommentRevisions returns every version of a comment, oldest first,
+// ending with the current one.
+func (s sqlStore) GetPostCommentRevisions(ctx context.Context, commentID int64) (revisions []api.Revision, err error) {
+	var revision api.Revision
+
+	revisions, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("cv", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("cv", "created_at")),
+			sm.From("comment_revisions").As("cv"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("cv", "editor_id")),
+			sm.Where(mysql.Quote("cv", "comment_id").EQ(mysql.Arg(commentID))),
+			sm.OrderBy(mysql.Quote("cv", "id")).Asc()),
+		&revision, &revision.Body, &revision.Editor.ID, &revision.Editor.Username, &revision.Editor.Role, &revision.Editor.Bio, &revision.Editor.Avatar, &revision.Editor.CreatedAt, &revision.Editor.Deleted, &revision.CreatedAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	var current api.Revision
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("c", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.F("COALESCE", mysql.Quote("c", "edited_at"), mysql.Quote("c", "created_at"))),
+			sm.From("comments").As("c"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.F("COALESCE", mysql.Quote("c", "edited_by"), mysql.Quote("c", "user_id"))),
+			sm.Where(mysql.Quote("c", "id").EQ(mysql.Arg(commentID)))),
+		&current.Body, &current.Editor.ID, &current.Editor.Username, &current.Editor.Role, &current.Editor.Bio, &current.Editor.Avatar, &current.Editor.CreatedAt, &current.Editor.Deleted, &current.CreatedAt,
 	)
 
+	if err != nil {
+		return
+	}
+
+	current.Current = true
+	revisions = append(revisions, current)
+
+	for i := range revisions {
+		revisions[i].Number = uint(i + 1)
+	}
+
 	return
 }
 
-func GetCommentReactions(commentID int64) (reactions []api.Reaction, err error) {
+func (s sqlStore) DeletePostComment(ctx context.Context, commentID int64) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		var userID, postID int64
+
+		err = queryOne(ctx,
+			selectQuery(
+				sm.Columns("user_id", "post_id"),
+				sm.From("comments"),
+				sm.Where(mysql.And(
+					mysql.Quote("id").EQ(mysql.Arg(commentID)),
+					mysql.Quote("deleted_at").IsNull())),
+				sm.ForUpdate()),
+			&userID, &postID,
+		)
+
+		if err == ErrNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return
+		}
+
+		_, err = queryExec(ctx,
+			updateQuery(
+				um.Table("comments"),
+				um.SetCol("deleted_at").To(mysql.F("NOW")),
+				um.Where(mysql.Quote("id").EQ(mysql.Arg(commentID))),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		err = adjustCount(ctx, "posts", "comment_count", postID, -1)
+
+		if err != nil {
+			return
+		}
+
+		return adjustCount(ctx, "users", "comment_count", userID, -1)
+	})
+}
+
+func (s sqlStore) CreateCommentReaction(ctx context.Context, userID, commentID int64, reaction string) (err error) {
+	return setReaction(ctx, commentReactionTable, userID, commentID, reaction)
+}
+
+func (s sqlStore) GetCommentReactions(ctx context.Context, commentID int64) (reactions []api.Reaction, err error) {
 	var reaction api.Reaction
 
-	reactions, err = queryMany(
-		mysql.Select(
+	reactions, err = queryMany(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("r", "id"),
 				mysql.Quote("r", "name"),
-				mysql.F("COUNT", mysql.Quote("r", "id"))),
-			sm.From("comment_reactions").As("cr"),
-			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("cr", "reaction_id")),
-			sm.Where(mysql.Quote("cr", "comment_id").EQ(mysql.Arg(commentID))),
-			sm.GroupBy(mysql.Quote("r", "id"))),
+				mysql.Quote("crc", "count")),
+			sm.From("comment_reaction_counts").As("crc"),
+			sm.InnerJoin("reactions").As("r").OnEQ(mysql.Quote("r", "id"), mysql.Quote("crc", "reaction_id")),
+			sm.Where(mysql.And(
+				mysql.Quote("crc", "comment_id").EQ(mysql.Arg(commentID)),
+				mysql.Quote("crc", "count").GT(mysql.Arg(0))))),
 		&reaction, &reaction.ID, &reaction.Name, &reaction.Count,
 	)
 
 	return
 }
 
-func GetCommentReaction(userID, commentID int64) (reaction api.Reaction, err error) {
-	err = queryOne(
-		mysql.Select(
+func (s sqlStore) GetCommentReaction(ctx context.Context, userID, commentID int64) (reaction api.Reaction, err error) {
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("r", "id"),
 				mysql.Quote("r", "name"),
@@ -660,41 +1351,35 @@ func GetCommentReaction(userID, commentID int64) (reaction api.Reaction, err err
 	return
 }
 
-func DeleteCommentReaction(userID, commentID int64) (err error) {
-	_, err = queryExec(
-		mysql.Delete(
-			dm.From("comment_reactions"),
-			dm.Where(mysql.And(
-				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
-				mysql.Quote("comment_id").EQ(mysql.Arg(commentID)))),
-		),
-	)
-
-	return
+func (s sqlStore) DeleteCommentReaction(ctx context.Context, userID, commentID int64) (err error) {
+	return setReaction(ctx, commentReactionTable, userID, commentID, "")
 }
 
-func CreateTag(name, color, description string) (tagID int64, err error) {
-	res, err := queryExec(
-		mysql.Insert(
+func (s sqlStore) CreateTag(ctx context.Context, name, color, description string) (tagID int64, err error) {
+	tagID, err = queryInsert(ctx,
+		insertQuery(
 			im.Into("tags", "name", "color", "description"),
 			im.Values(mysql.Arg(name, color, description)),
 		),
 	)
 
-	if err != nil {
-		return
-	}
-
-	tagID, err = res.LastInsertId()
-
 	return
 }
 
-func GetTags(limit, offset int64, filters []bob.Expression) (tags []api.Tag, err error) {
+func (s sqlStore) GetTags(ctx context.Context, q store.TagQuery) (tags []api.Tag, err error) {
 	var tag api.Tag
+	var filters []bob.Expression
+
+	if q.Name != "" {
+		filters = append(filters, mysql.Quote("t", "name").Like(mysql.Arg("%"+q.Name+"%")))
+	}
 
-	tags, err = queryMany(
-		mysql.Select(
+	if q.Since != nil {
+		filters = append(filters, mysql.Quote("p", "created_at").GTE(mysql.Arg(*q.Since)))
+	}
+
+	tags, err = queryMany(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("t", "id"),
 				mysql.Quote("t", "name"),
@@ -708,17 +1393,17 @@ func GetTags(limit, offset int64, filters []bob.Expression) (tags []api.Tag, err
 			sm.GroupBy(mysql.Quote("t", "id")),
 			sm.OrderBy(mysql.F("COUNT", mysql.Quote("p", "id"))).Desc(),
 			sm.OrderBy(mysql.Quote("t", "id")).Asc(),
-			sm.Limit(limit),
-			sm.Offset(offset)),
+			sm.Limit(q.Limit),
+			sm.Offset(q.Offset)),
 		&tag, &tag.ID, &tag.Name, &tag.Color, &tag.Description,
 	)
 
 	return
 }
 
-func GetTag(tagID int64) (tag api.Tag, err error) {
-	err = queryOne(
-		mysql.Select(
+func (s sqlStore) GetTag(ctx context.Context, tagID int64) (tag api.Tag, err error) {
+	err = queryOne(ctx,
+		selectQuery(
 			sm.Columns(
 				mysql.Quote("t", "id"),
 				mysql.Quote("t", "name"),
@@ -732,9 +1417,9 @@ func GetTag(tagID int64) (tag api.Tag, err error) {
 	return
 }
 
-func UpdateTag(tagID int64, color, description string) (err error) {
-	_, err = queryExec(
-		mysql.Update(
+func (s sqlStore) UpdateTag(ctx context.Context, tagID int64, color, description string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
 			um.Table("tags"),
 			um.SetCol("color").ToArg(color),
 			um.SetCol("description").ToArg(description),
@@ -744,11 +1429,11 @@ func UpdateTag(tagID int64, color, description string) (err error) {
 	return
 }
 
-func CreatePostTag(postID int64, tag string) (err error) {
-	_, err = queryExec(
-		mysql.Insert(
+func (s sqlStore) CreatePostTag(ctx context.Context, postID int64, tag string) (err error) {
+	_, err = queryExec(ctx,
+		insertQuery(
 			im.Into("post_tags", "post_id", "tag_id"),
-			im.Query(mysql.Select(
+			im.Query(selectQuery(
 				sm.Columns(mysql.Arg(postID), "id"),
 				sm.From("tags"),
 				sm.Where(mysql.Quote("name").EQ(mysql.Arg(tag))))),
@@ -757,3 +1442,1481 @@ func CreatePostTag(postID int64, tag string) (err error) {
 
 	return
 }
+
+func (s sqlStore) CreateNotifications(ctx context.Context, userIDs []int64, actorID int64, kind string, postID int64, commentID *int64, coalesce bool) (err error) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	ids := make([]any, len(userIDs))
+
+	for i, userID := range userIDs {
+		ids[i] = userID
+	}
+
+	// The unread notifications of the users about the same thing, which
+	// coalesced notifications are added to.
+	filters := []bob.Expression{
+		mysql.Quote("user_id").In(mysql.Arg(ids...)),
+		mysql.Quote("type").EQ(mysql.Arg(kind)),
+		mysql.Quote("post_id").EQ(mysql.Arg(postID)),
+		mysql.Quote("read_at").IsNull(),
+	}
+
+	if commentID != nil {
+		filters = append(filters, mysql.Quote("comment_id").EQ(mysql.Arg(*commentID)))
+	} else {
+		filters = append(filters, mysql.Quote("comment_id").IsNull())
+	}
+
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		pending := userIDs
+
+		if coalesce {
+			var userID int64
+
+			notified, err := queryMany(ctx,
+				selectQuery(
+					sm.Columns("user_id"),
+					sm.From("notifications"),
+					sm.Where(mysql.And(filters...)),
+					sm.ForUpdate()),
+				&userID, &userID,
+			)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = queryExec(ctx,
+				updateQuery(
+					um.Table("notifications"),
+					um.SetCol("actor_id").ToArg(actorID),
+					um.SetCol("updated_at").To(mysql.F("NOW")),
+					um.Where(mysql.And(filters...))),
+			)
+
+			if err != nil {
+				return err
+			}
+
+			pending = slices.DeleteFunc(slices.Clone(userIDs), func(userID int64) bool {
+				return slices.Contains(notified, userID)
+			})
+		}
+
+		if len(pending) > 0 {
+			rows := make([][]bob.Expression, len(pending))
+
+			for i, userID := range pending {
+				rows[i] = []bob.Expression{mysql.Arg(userID), mysql.Arg(actorID), mysql.Arg(kind), mysql.Arg(postID), mysql.Arg(commentID)}
+			}
+
+			_, err = queryExec(ctx,
+				insertQuery(
+					im.Into("notifications", "user_id", "actor_id", "type", "post_id", "comment_id"),
+					im.Rows(rows...),
+				),
+			)
+
+			if err != nil {
+				return
+			}
+		}
+
+		// The actor is written as a literal, as PostgreSQL would take a
+		// placeholder in the select list for text.
+		_, err = queryExec(ctx,
+			insertIgnoreQuery("notification_actors", []string{"notification_id", "user_id"},
+				selectQuery(
+					sm.Columns("id", mysql.Raw(strconv.FormatInt(actorID, 10))),
+					sm.From("notifications"),
+					sm.Where(mysql.And(filters...)))),
+		)
+
+		return
+	})
+}
+
+func (s sqlStore) GetNotifications(ctx context.Context, userID, limit, offset int64, unreadOnly bool) (notifications []api.Notification, err error) {
+	var notification api.Notification
+
+	filters := []bob.Expression{mysql.Quote("n", "user_id").EQ(mysql.Arg(userID))}
+
+	if unreadOnly {
+		filters = append(filters, mysql.Quote("n", "read_at").IsNull())
+	}
+
+	notifications, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("n", "id"),
+				mysql.Quote("n", "type"),
+				mysql.Quote("n", "post_id"),
+				mysql.Quote("n", "comment_id"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.F("COUNT", "DISTINCT na.user_id"),
+				mysql.Quote("n", "read_at").IsNotNull(),
+				mysql.Quote("n", "created_at"),
+				mysql.Quote("n", "updated_at")),
+			sm.From("notifications").As("n"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("n", "actor_id")),
+			sm.LeftJoin("notification_actors").As("na").OnEQ(mysql.Quote("na", "notification_id"), mysql.Quote("n", "id")),
+			sm.Where(mysql.And(filters...)),
+			sm.GroupBy(mysql.Quote("n", "id")),
+			sm.GroupBy(mysql.Quote("u", "id")),
+			sm.OrderBy(mysql.Quote("n", "updated_at")).Desc(),
+			sm.OrderBy(mysql.Quote("n", "id")).Desc(),
+			sm.Limit(limit),
+			sm.Offset(offset)),
+		&notification, &notification.ID, &notification.Type, &notification.PostID, &notification.CommentID, &notification.Actor.ID, &notification.Actor.Username, &notification.Actor.Role, &notification.Actor.Bio, &notification.Actor.Avatar, &notification.Actor.CreatedAt, &notification.Actor.Deleted, &notification.ActorCount, &notification.Read, &notification.CreatedAt, &notification.UpdatedAt,
+	)
+
+	return
+}
+
+func (s sqlStore) GetNotificationOwner(ctx context.Context, notificationID int64) (userID int64, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("user_id"),
+			sm.From("notifications"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(notificationID)))),
+		&userID,
+	)
+
+	return
+}
+
+func (s sqlStore) GetUnreadNotificationCounts(ctx context.Context, userIDs []int64) (counts map[int64]uint, err error) {
+	counts = make(map[int64]uint, len(userIDs))
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	ids := make([]any, len(userIDs))
+
+	for i, userID := range userIDs {
+		ids[i] = userID
+	}
+
+	type unreadCount struct {
+		UserID int64
+		Count  uint
+	}
+
+	var row unreadCount
+
+	rows, err := queryMany(ctx,
+		selectQuery(
+			sm.Columns("user_id", mysql.F("COUNT", 1)),
+			sm.From("notifications"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").In(mysql.Arg(ids...)),
+				mysql.Quote("read_at").IsNull())),
+			sm.GroupBy("user_id")),
+		&row, &row.UserID, &row.Count,
+	)
+
+	if err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+
+	return
+}
+
+func (s sqlStore) GetUnreadNotificationCount(ctx context.Context, userID int64) (count uint, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("notifications"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("read_at").IsNull()))),
+		&count,
+	)
+
+	return
+}
+
+func (s sqlStore) MarkNotificationRead(ctx context.Context, notificationID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("notifications"),
+			um.SetCol("read_at").To(mysql.F("NOW")),
+			um.SetCol("updated_at").To(mysql.Quote("updated_at")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(notificationID)),
+				mysql.Quote("read_at").IsNull()))),
+	)
+
+	return
+}
+
+func (s sqlStore) MarkAllNotificationsRead(ctx context.Context, userID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("notifications"),
+			um.SetCol("read_at").To(mysql.F("NOW")),
+			um.SetCol("updated_at").To(mysql.Quote("updated_at")),
+			um.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("read_at").IsNull()))),
+	)
+
+	return
+}
+
+func (s sqlStore) GetPostParticipants(ctx context.Context, postID int64) (userIDs []int64, err error) {
+	var userID int64
+
+	userIDs, err = queryMany(ctx,
+		selectQuery(
+			sm.Distinct(),
+			sm.Columns(mysql.Quote("user_id")),
+			sm.From("comments"),
+			sm.Where(mysql.And(
+				mysql.Quote("post_id").EQ(mysql.Arg(postID)),
+				mysql.Quote("deleted_at").IsNull()))),
+		&userID, &userID,
+	)
+
+	return
+}
+
+// searchFilters matches the text of q against the FULLTEXT index on columns,
+// or on PostgreSQL against the tsvector column named by vector.
+func searchFilters(q search.Query, columns, vector, postID, createdAt string) (filters []bob.Expression, score bob.Expression) {
+	score = mysql.Raw("0")
+
+	switch {
+	case driver == DriverPostgres:
+		if q.HasText() {
+			score = mysql.Raw("TS_RANK("+vector+", TO_TSQUERY('simple', ?))", q.TSQuery())
+		}
+
+		if q.HasText() || len(q.Excluded) > 0 {
+			filters = append(filters, mysql.Raw(vector+" @@ TO_TSQUERY('simple', ?)", q.TSQuery()))
+		}
+	case q.HasText():
+		score = mysql.Raw("MATCH("+columns+") AGAINST (? IN BOOLEAN MODE)", q.Boolean())
+		filters = append(filters, mysql.Raw("MATCH("+columns+") AGAINST (? IN BOOLEAN MODE)", q.Boolean()))
+	case len(q.Excluded) > 0:
+		filters = append(filters, mysql.Raw("NOT MATCH("+columns+") AGAINST (? IN BOOLEAN MODE)", q.Exclusions()))
+	}
+
+	if q.Tag != "" {
+		filters = append(filters, mysql.Raw("EXISTS (SELECT 1 FROM post_tags spt INNER JOIN tags st ON st.id = spt.tag_id WHERE spt.post_id = "+postID+" AND st.name = ?)", q.Tag))
+	}
+
+	if q.User != "" {
+		filters = append(filters, mysql.Quote("u", "username").EQ(mysql.Arg(q.User)))
+	}
+
+	if q.Before != nil {
+		filters = append(filters, mysql.Raw(createdAt+" < ?", *q.Before))
+	}
+
+	if q.After != nil {
+		filters = append(filters, mysql.Raw(createdAt+" >= ?", *q.After))
+	}
+
+	return
+}
+
+func postSearchFilters(q search.Query) ([]bob.Expression, bob.Expression) {
+	return searchFilters(q, "p.title, p.body_text", "p.search_vector", "p.id", "p.created_at")
+}
+
+func commentSearchFilters(q search.Query) ([]bob.Expression, bob.Expression) {
+	return searchFilters(q, "c.body_text", "c.search_vector", "c.post_id", "c.created_at")
+}
+
+func (s sqlStore) SearchPosts(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error) {
+	filters, score := postSearchFilters(q)
+
+	type match struct {
+		post  api.Post
+		score float64
+	}
+
+	var m match
+
+	matches, err := queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("p", "id"),
+				mysql.Quote("p", "title"),
+				mysql.Quote("p", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("p", "comment_count"),
+				mysql.Quote("p", "reaction_count"),
+				mysql.Quote("p", "hot_score"),
+				postTagIDsExpr(mysql.Quote("p", "id")),
+				mysql.Quote("p", "created_at"),
+				mysql.Quote("p", "updated_at"),
+				mysql.Quote("p", "deleted_at").IsNotNull(),
+				mysql.As(mysql.Group(score), "score")),
+			sm.From("posts").As("p"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("p", "user_id")),
+			sm.Where(mysql.And(
+				append(filters, mysql.Quote("p", "deleted_at").IsNull())...)),
+			sm.OrderBy(mysql.Quote("score")).Desc(),
+			sm.OrderBy(mysql.Quote("p", "created_at")).Desc(),
+			sm.OrderBy(mysql.Quote("p", "id")).Desc(),
+			sm.Limit(limit),
+			sm.Offset(offset)),
+		&m, &m.post.ID, &m.post.Title, &m.post.Body, &m.post.Author.ID, &m.post.Author.Username, &m.post.Author.Role, &m.post.Author.Bio, &m.post.Author.Avatar, &m.post.Author.CreatedAt, &m.post.Author.Deleted, &m.post.CommentCount, &m.post.ReactionCount, &m.post.HotScore, &m.post.Tags, &m.post.CreatedAt, &m.post.UpdatedAt, &m.post.Deleted, &m.score,
+	)
+
+	if err != nil {
+		return
+	}
+
+	results = make([]api.SearchResult, 0, len(matches))
+
+	for _, m := range matches {
+		post := m.post
+		results = append(results, api.SearchResult{Type: "post", Score: m.score, Post: &post})
+	}
+
+	return
+}
+
+func (s sqlStore) SearchComments(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error) {
+	filters, score := commentSearchFilters(q)
+
+	type match struct {
+		comment api.Comment
+		score   float64
+	}
+
+	var m match
+
+	matches, err := queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("c", "id"),
+				mysql.Quote("c", "post_id"),
+				mysql.Quote("c", "parent_id"),
+				mysql.Quote("c", "depth"),
+				mysql.Quote("c", "body"),
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull(),
+				mysql.Quote("c", "created_at"),
+				mysql.Quote("c", "updated_at"),
+				mysql.Quote("c", "deleted_at").IsNotNull(),
+				mysql.As(mysql.Group(score), "score")),
+			sm.From("comments").As("c"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("c", "user_id")),
+			sm.InnerJoin("posts").As("p").OnEQ(mysql.Quote("p", "id"), mysql.Quote("c", "post_id")),
+			sm.Where(mysql.And(
+				append(filters,
+					mysql.Quote("c", "deleted_at").IsNull(),
+					mysql.Quote("p", "deleted_at").IsNull())...)),
+			sm.OrderBy(mysql.Quote("score")).Desc(),
+			sm.OrderBy(mysql.Quote("c", "created_at")).Desc(),
+			sm.OrderBy(mysql.Quote("c", "id")).Desc(),
+			sm.Limit(limit),
+			sm.Offset(offset)),
+		&m, &m.comment.ID, &m.comment.PostID, &m.comment.ParentID, &m.comment.Depth, &m.comment.Body, &m.comment.Author.ID, &m.comment.Author.Username, &m.comment.Author.Role, &m.comment.Author.Bio, &m.comment.Author.Avatar, &m.comment.Author.CreatedAt, &m.comment.Author.Deleted, &m.comment.CreatedAt, &m.comment.UpdatedAt, &m.comment.Deleted, &m.score,
+	)
+
+	if err != nil {
+		return
+	}
+
+	results = make([]api.SearchResult, 0, len(matches))
+
+	for _, m := range matches {
+		comment := m.comment
+		results = append(results, api.SearchResult{Type: "comment", Score: m.score, Comment: &comment})
+	}
+
+	return
+}
+
+func (s sqlStore) CreateReport(ctx context.Context, reporterID int64, targetType string, targetID int64, reason, note string) (reportID int64, err error) {
+	reportID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("reports", "reporter_id", "target_type", "target_id", "reason", "note"),
+			im.Values(mysql.Arg(reporterID, targetType, targetID, reason, note)),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetReports(ctx context.Context, q store.ReportQuery) (reports []api.Report, err error) {
+	var report api.Report
+	var filters []bob.Expression
+
+	if len(q.Statuses) > 0 {
+		statuses := make([]any, len(q.Statuses))
+
+		for i, status := range q.Statuses {
+			statuses[i] = status
+		}
+
+		filters = append(filters, mysql.Quote("status").In(mysql.Arg(statuses...)))
+	}
+
+	if q.TargetType != "" {
+		filters = append(filters, mysql.Quote("target_type").EQ(mysql.Arg(q.TargetType)))
+	}
+
+	if q.TargetID != nil {
+		filters = append(filters, mysql.Quote("target_id").EQ(mysql.Arg(*q.TargetID)))
+	}
+
+	if q.Reason != "" {
+		filters = append(filters, mysql.Quote("reason").EQ(mysql.Arg(q.Reason)))
+	}
+
+	selectArgs := []bob.Mod[*dialect.SelectQuery]{
+		sm.Columns(
+			mysql.Quote("id"),
+			mysql.Quote("reporter_id"),
+			mysql.Quote("target_type"),
+			mysql.Quote("target_id"),
+			mysql.Quote("reason"),
+			mysql.Quote("note"),
+			mysql.Quote("status"),
+			mysql.Quote("assignee_id"),
+			mysql.Quote("resolution"),
+			mysql.Quote("resolution_note"),
+			mysql.Quote("resolved_by"),
+			mysql.Quote("resolved_at"),
+			mysql.Quote("created_at"),
+			mysql.Quote("updated_at")),
+		sm.From("reports"),
+	}
+
+	// With no filters, And would render an empty WHERE ().
+	if len(filters) > 0 {
+		selectArgs = append(selectArgs, sm.Where(mysql.And(filters...)))
+	}
+
+	selectArgs = append(selectArgs,
+		sm.OrderBy(mysql.Quote("created_at")).Desc(),
+		sm.OrderBy(mysql.Quote("id")).Desc(),
+		sm.Limit(q.Limit),
+		sm.Offset(q.Offset))
+
+	reports, err = queryMany(ctx,
+		selectQuery(selectArgs...),
+		&report, &report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.Reason, &report.Note, &report.Status, &report.AssigneeID, &report.Resolution, &report.ResolutionNote, &report.ResolvedBy, &report.ResolvedAt, &report.CreatedAt, &report.UpdatedAt,
+	)
+
+	return
+}
+
+func (s sqlStore) GetReport(ctx context.Context, reportID int64) (report api.Report, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("id"),
+				mysql.Quote("reporter_id"),
+				mysql.Quote("target_type"),
+				mysql.Quote("target_id"),
+				mysql.Quote("reason"),
+				mysql.Quote("note"),
+				mysql.Quote("status"),
+				mysql.Quote("assignee_id"),
+				mysql.Quote("resolution"),
+				mysql.Quote("resolution_note"),
+				mysql.Quote("resolved_by"),
+				mysql.Quote("resolved_at"),
+				mysql.Quote("created_at"),
+				mysql.Quote("updated_at")),
+			sm.From("reports"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(reportID)))),
+		&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.Reason, &report.Note, &report.Status, &report.AssigneeID, &report.Resolution, &report.ResolutionNote, &report.ResolvedBy, &report.ResolvedAt, &report.CreatedAt, &report.UpdatedAt,
+	)
+
+	return
+}
+
+func (s sqlStore) ClaimReport(ctx context.Context, reportID, assigneeID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("reports"),
+			um.SetCol("status").ToArg("claimed"),
+			um.SetCol("assignee_id").ToArg(assigneeID),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(reportID)),
+				mysql.Quote("status").NE(mysql.Arg("resolved")))),
+		),
+	)
+
+	return
+}
+
+// ResolveReport resolves a report that is still open or claimed, returning
+// ErrNotFound if there is none, so that a report is only resolved once.
+func (s sqlStore) ResolveReport(ctx context.Context, reportID, resolvedBy int64, resolution, note string) (err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("reports"),
+			um.SetCol("status").ToArg("resolved"),
+			um.SetCol("resolution").ToArg(resolution),
+			um.SetCol("resolution_note").ToArg(note),
+			um.SetCol("resolved_by").ToArg(resolvedBy),
+			um.SetCol("resolved_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(reportID)),
+				mysql.Quote("status").NE(mysql.Arg("resolved")))),
+		),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err == nil && rows == 0 {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+func (s sqlStore) CreateWarning(ctx context.Context, userID, createdBy int64, reportID *int64, reason string) (warningID int64, err error) {
+	warningID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("warnings", "user_id", "created_by", "report_id", "reason"),
+			im.Values(mysql.Arg(userID, createdBy, reportID, reason)),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserWarnings(ctx context.Context, userID int64) (warnings []api.Warning, err error) {
+	var warning api.Warning
+
+	warnings, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "user_id", "report_id", "reason", "created_by", "created_at"),
+			sm.From("warnings"),
+			sm.Where(mysql.Quote("user_id").EQ(mysql.Arg(userID))),
+			sm.OrderBy(mysql.Quote("created_at")).Desc()),
+		&warning, &warning.ID, &warning.UserID, &warning.ReportID, &warning.Reason, &warning.CreatedBy, &warning.CreatedAt,
+	)
+
+	return
+}
+
+func (s sqlStore) CreateSuspension(ctx context.Context, userID, createdBy int64, reportID *int64, reason string, endsAt *time.Time) (suspensionID int64, err error) {
+	suspensionID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("suspensions", "user_id", "created_by", "report_id", "reason", "ends_at"),
+			im.Values(mysql.Arg(userID, createdBy, reportID, reason, endsAt)),
+		),
+	)
+
+	return
+}
+
+func activeSuspensionExpr() bob.Expression {
+	return mysql.And(
+		mysql.Quote("lifted_at").IsNull(),
+		mysql.Or(
+			mysql.Quote("ends_at").IsNull(),
+			mysql.Quote("ends_at").GT(mysql.F("NOW"))))
+}
+
+func (s sqlStore) GetUserSuspended(ctx context.Context, userID int64) (suspended bool, err error) {
+	var count int
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("suspensions"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				activeSuspensionExpr()))),
+		&count,
+	)
+
+	suspended = count > 0
+
+	return
+}
+
+func (s sqlStore) GetUserSuspensions(ctx context.Context, userID int64) (suspensions []api.Suspension, err error) {
+	var suspension api.Suspension
+
+	suspensions, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "user_id", "report_id", "reason", "ends_at", "lifted_at", "lifted_by", "created_by", "created_at", mysql.As(mysql.Group(activeSuspensionExpr()), "active")),
+			sm.From("suspensions"),
+			sm.Where(mysql.Quote("user_id").EQ(mysql.Arg(userID))),
+			sm.OrderBy(mysql.Quote("created_at")).Desc(),
+			sm.OrderBy(mysql.Quote("id")).Desc()),
+		&suspension, &suspension.ID, &suspension.UserID, &suspension.ReportID, &suspension.Reason, &suspension.EndsAt, &suspension.LiftedAt, &suspension.LiftedBy, &suspension.CreatedBy, &suspension.CreatedAt, &suspension.Active,
+	)
+
+	return
+}
+
+// GetUserActiveSuspension returns the active suspension that ends last, with
+// permanent suspensions taking precedence over timed ones.
+func (s sqlStore) GetUserActiveSuspension(ctx context.Context, userID int64) (suspension api.Suspension, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("id", "user_id", "report_id", "reason", "ends_at", "lifted_at", "lifted_by", "created_by", "created_at"),
+			sm.From("suspensions"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				activeSuspensionExpr())),
+			sm.OrderBy(mysql.Quote("ends_at").IsNull()).Desc(),
+			sm.OrderBy(mysql.Quote("ends_at")).Desc(),
+			sm.Limit(1)),
+		&suspension.ID, &suspension.UserID, &suspension.ReportID, &suspension.Reason, &suspension.EndsAt, &suspension.LiftedAt, &suspension.LiftedBy, &suspension.CreatedBy, &suspension.CreatedAt,
+	)
+
+	suspension.Active = err == nil
+
+	return
+}
+
+func (s sqlStore) GetSuspension(ctx context.Context, suspensionID int64) (suspension api.Suspension, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("id", "user_id", "report_id", "reason", "ends_at", "lifted_at", "lifted_by", "created_by", "created_at", mysql.As(mysql.Group(activeSuspensionExpr()), "active")),
+			sm.From("suspensions"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(suspensionID)))),
+		&suspension.ID, &suspension.UserID, &suspension.ReportID, &suspension.Reason, &suspension.EndsAt, &suspension.LiftedAt, &suspension.LiftedBy, &suspension.CreatedBy, &suspension.CreatedAt, &suspension.Active,
+	)
+
+	return
+}
+
+func (s sqlStore) LiftSuspension(ctx context.Context, suspensionID, liftedBy int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("suspensions"),
+			um.SetCol("lifted_at").To(mysql.F("NOW")),
+			um.SetCol("lifted_by").ToArg(liftedBy),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(suspensionID)),
+				mysql.Quote("lifted_at").IsNull())),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserRole(ctx context.Context, userID int64) (role string, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("role"),
+			sm.From("users"),
+			sm.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(userID)),
+				mysql.Quote("deleted_at").IsNull()))),
+		&role,
+	)
+
+	return
+}
+
+func (s sqlStore) CreateTagModerator(ctx context.Context, tagID, userID int64) (err error) {
+	_, err = queryExec(ctx,
+		insertIgnore("tag_moderators", []string{"tag_id", "user_id"},
+			[]bob.Expression{mysql.Arg(tagID), mysql.Arg(userID)}),
+	)
+
+	return
+}
+
+func (s sqlStore) DeleteTagModerator(ctx context.Context, tagID, userID int64) (err error) {
+	_, err = queryExec(ctx,
+		deleteQuery(
+			dm.From("tag_moderators"),
+			dm.Where(mysql.And(
+				mysql.Quote("tag_id").EQ(mysql.Arg(tagID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)))),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetTagModerators(ctx context.Context, tagID int64) (users []api.User, err error) {
+	var user api.User
+
+	users, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("u", "id"),
+				mysql.Quote("u", "username"),
+				mysql.Quote("u", "role"),
+				mysql.Quote("u", "bio"),
+				mysql.Quote("u", "avatar"),
+				mysql.Quote("u", "created_at"),
+				mysql.Quote("u", "deleted_at").IsNotNull()),
+			sm.From("tag_moderators").As("tm"),
+			sm.InnerJoin("users").As("u").OnEQ(mysql.Quote("u", "id"), mysql.Quote("tm", "user_id")),
+			sm.Where(mysql.Quote("tm", "tag_id").EQ(mysql.Arg(tagID))),
+			sm.OrderBy(mysql.Quote("u", "username")).Asc()),
+		&user, &user.ID, &user.Username, &user.Role, &user.Bio, &user.Avatar, &user.CreatedAt, &user.Deleted,
+	)
+
+	return
+}
+
+func (s sqlStore) GetTagModerator(ctx context.Context, tagID, userID int64) (moderator bool, err error) {
+	var count int
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("tag_moderators"),
+			sm.Where(mysql.And(
+				mysql.Quote("tag_id").EQ(mysql.Arg(tagID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID))))),
+		&count,
+	)
+
+	moderator = count > 0
+
+	return
+}
+
+func (s sqlStore) GetPostTagModerator(ctx context.Context, postID, userID int64) (moderator bool, err error) {
+	var count int
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("post_tags").As("pt"),
+			sm.InnerJoin("tag_moderators").As("tm").OnEQ(mysql.Quote("tm", "tag_id"), mysql.Quote("pt", "tag_id")),
+			sm.Where(mysql.And(
+				mysql.Quote("pt", "post_id").EQ(mysql.Arg(postID)),
+				mysql.Quote("tm", "user_id").EQ(mysql.Arg(userID))))),
+		&count,
+	)
+
+	moderator = count > 0
+
+	return
+}
+
+func (s sqlStore) CreateSession(ctx context.Context, userID int64, tokenHash, userAgent, ip string, expiresAt time.Time) (sessionID int64, err error) {
+	sessionID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("sessions", "user_id", "token_hash", "user_agent", "ip", "expires_at"),
+			im.Values(mysql.Arg(userID, tokenHash, userAgent, ip, expiresAt)),
+		),
+	)
+
+	return
+}
+
+// RotateSession replaces the refresh token of an active session. If tokenHash
+// belongs to a token that was already rotated, the session is returned with
+// ErrSessionReused when it was rotated within grace (a concurrent refresh), and
+// is otherwise revoked since the old token has likely been stolen.
+func (s sqlStore) RotateSession(ctx context.Context, tokenHash, newTokenHash, userAgent, ip string, expiresAt time.Time, grace time.Duration) (sessionID, userID int64, err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("sessions"),
+			um.SetCol("previous_token_hash").To(mysql.Quote("token_hash")),
+			um.SetCol("token_hash").ToArg(newTokenHash),
+			um.SetCol("user_agent").ToArg(userAgent),
+			um.SetCol("ip").ToArg(ip),
+			um.SetCol("rotated_at").To(mysql.F("NOW")),
+			um.SetCol("last_seen_at").To(mysql.F("NOW")),
+			um.SetCol("expires_at").ToArg(expiresAt),
+			um.Where(mysql.And(
+				mysql.Quote("token_hash").EQ(mysql.Arg(tokenHash)),
+				mysql.Quote("revoked_at").IsNull(),
+				mysql.Quote("expires_at").GT(mysql.F("NOW")))),
+		),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return
+	}
+
+	if rows > 0 {
+		err = queryOne(ctx,
+			selectQuery(
+				sm.Columns("id", "user_id"),
+				sm.From("sessions"),
+				sm.Where(mysql.Quote("token_hash").EQ(mysql.Arg(newTokenHash)))),
+			&sessionID, &userID,
+		)
+
+		return
+	}
+
+	var rotatedAt time.Time
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("id", "user_id", "rotated_at"),
+			sm.From("sessions"),
+			sm.Where(mysql.And(
+				mysql.Quote("previous_token_hash").EQ(mysql.Arg(tokenHash)),
+				mysql.Quote("revoked_at").IsNull(),
+				mysql.Quote("expires_at").GT(mysql.F("NOW"))))),
+		&sessionID, &userID, &rotatedAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	if time.Since(rotatedAt) < grace {
+		err = ErrSessionReused
+		return
+	}
+
+	err = s.RevokeSession(ctx, userID, sessionID)
+
+	if err == nil {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+func (s sqlStore) GetSessionActive(ctx context.Context, sessionID, userID int64) (active bool, err error) {
+	var count int
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("sessions"),
+			sm.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(sessionID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("revoked_at").IsNull(),
+				mysql.Quote("expires_at").GT(mysql.F("NOW"))))),
+		&count,
+	)
+
+	active = count > 0
+
+	return
+}
+
+func (s sqlStore) GetUserSessions(ctx context.Context, userID int64) (sessions []api.Session, err error) {
+	var session api.Session
+
+	sessions, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "user_agent", "ip", "created_at", "last_seen_at", "expires_at"),
+			sm.From("sessions"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("revoked_at").IsNull(),
+				mysql.Quote("expires_at").GT(mysql.F("NOW")))),
+			sm.OrderBy(mysql.Quote("last_seen_at")).Desc()),
+		&session, &session.ID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+	)
+
+	return
+}
+
+func (s sqlStore) RevokeSession(ctx context.Context, userID, sessionID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("sessions"),
+			um.SetCol("revoked_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(sessionID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("revoked_at").IsNull())),
+		),
+	)
+
+	return
+}
+
+// RevokeUserSessions revokes every active session of a user, except for
+// exceptSessionID if it is not nil.
+func (s sqlStore) RevokeUserSessions(ctx context.Context, userID int64, exceptSessionID *int64) (err error) {
+	filters := []bob.Expression{
+		mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+		mysql.Quote("revoked_at").IsNull(),
+	}
+
+	if exceptSessionID != nil {
+		filters = append(filters, mysql.Quote("id").NE(mysql.Arg(*exceptSessionID)))
+	}
+
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("sessions"),
+			um.SetCol("revoked_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(filters...)),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserEmail(ctx context.Context, userID int64) (email *string, verified bool, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("email"),
+				mysql.Quote("email_verified_at").IsNotNull()),
+			sm.From("users"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+		&email, &verified,
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserIDByEmail(ctx context.Context, email string) (userID int64, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("id"),
+			sm.From("users"),
+			sm.Where(mysql.And(
+				mysql.Quote("email").EQ(mysql.Arg(email)),
+				mysql.Quote("deleted_at").IsNull()))),
+		&userID,
+	)
+
+	return
+}
+
+func (s sqlStore) UpdateUserEmail(ctx context.Context, userID int64, email string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("email").ToArg(email),
+			um.SetCol("email_verified_at").ToArg(nil),
+			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+	)
+
+	return
+}
+
+// VerifyUserEmail marks the email of a user as verified, provided it has not
+// been changed since the verification was requested.
+func (s sqlStore) VerifyUserEmail(ctx context.Context, userID int64, email string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("email_verified_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(userID)),
+				mysql.Quote("email").EQ(mysql.Arg(email)),
+				mysql.Quote("email_verified_at").IsNull()))),
+	)
+
+	return
+}
+
+// CreateUserToken stores a single-use token, invalidating any unused tokens
+// issued earlier to the user for the same purpose.
+func (s sqlStore) CreateUserToken(ctx context.Context, userID int64, purpose, tokenHash, email string, expiresAt time.Time) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		_, err = queryExec(ctx,
+			updateQuery(
+				um.Table("user_tokens"),
+				um.SetCol("used_at").To(mysql.F("NOW")),
+				um.Where(mysql.And(
+					mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+					mysql.Quote("purpose").EQ(mysql.Arg(purpose)),
+					mysql.Quote("used_at").IsNull()))),
+		)
+
+		if err != nil {
+			return
+		}
+
+		_, err = queryExec(ctx,
+			insertQuery(
+				im.Into("user_tokens", "user_id", "purpose", "token_hash", "email", "expires_at"),
+				im.Values(mysql.Arg(userID, purpose, tokenHash, email, expiresAt)),
+			),
+		)
+
+		return
+	})
+}
+
+// ConsumeUserToken marks an unexpired token as used and returns the user and
+// email it was issued for. It returns ErrNotFound if the token is invalid,
+// expired or already used.
+func (s sqlStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (userID int64, email string, err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("user_tokens"),
+			um.SetCol("used_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("token_hash").EQ(mysql.Arg(tokenHash)),
+				mysql.Quote("purpose").EQ(mysql.Arg(purpose)),
+				mysql.Quote("used_at").IsNull(),
+				mysql.Quote("expires_at").GT(mysql.F("NOW"))))),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return
+	}
+
+	if rows == 0 {
+		err = ErrNotFound
+		return
+	}
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("user_id", "email"),
+			sm.From("user_tokens"),
+			sm.Where(mysql.Quote("token_hash").EQ(mysql.Arg(tokenHash)))),
+		&userID, &email,
+	)
+
+	return
+}
+
+func (s sqlStore) GetSetting(ctx context.Context, key string, value any) (err error) {
+	var raw []byte
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.Quote("value")),
+			sm.From("settings"),
+			sm.Where(mysql.Quote("key").EQ(mysql.Arg(key)))),
+		&raw,
+	)
+
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(raw, value)
+
+	return
+}
+
+func (s sqlStore) UpdateSetting(ctx context.Context, key string, value any) (err error) {
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return
+	}
+
+	_, err = queryExec(ctx,
+		upsert("settings", []string{"key", "value"}, []string{"key"}, []string{"value"}, key, string(raw)),
+	)
+
+	return
+}
+
+// GetUserTwoFactor returns the TOTP secret of a user, which is set once
+// enrolment starts, and whether enrolment has been confirmed.
+func (s sqlStore) GetUserTwoFactor(ctx context.Context, userID int64) (secret *string, enabled bool, lastStep *int64, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("totp_secret"),
+				mysql.Quote("totp_enabled_at").IsNotNull(),
+				mysql.Quote("totp_last_step")),
+			sm.From("users"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+		&secret, &enabled, &lastStep,
+	)
+
+	return
+}
+
+func (s sqlStore) UpdateUserTwoFactorSecret(ctx context.Context, userID int64, secret *string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("totp_secret").ToArg(secret),
+			um.SetCol("totp_enabled_at").ToArg(nil),
+			um.SetCol("totp_last_step").ToArg(nil),
+			um.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+	)
+
+	return
+}
+
+func (s sqlStore) EnableUserTwoFactor(ctx context.Context, userID int64) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("totp_enabled_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(userID)),
+				mysql.Quote("totp_secret").IsNotNull()))),
+	)
+
+	return
+}
+
+// UseUserTwoFactorStep records step as the latest TOTP time step used by a
+// user. It returns ErrNotFound if step is not newer than the last one, so
+// that a code cannot be replayed.
+func (s sqlStore) UseUserTwoFactorStep(ctx context.Context, userID, step int64) (err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("users"),
+			um.SetCol("totp_last_step").ToArg(step),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(userID)),
+				mysql.Or(
+					mysql.Quote("totp_last_step").IsNull(),
+					mysql.Quote("totp_last_step").LT(mysql.Arg(step)))))),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err == nil && rows == 0 {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+func (s sqlStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) (err error) {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		_, err = queryExec(ctx,
+			deleteQuery(
+				dm.From("recovery_codes"),
+				dm.Where(mysql.Quote("user_id").EQ(mysql.Arg(userID)))),
+		)
+
+		if err != nil || len(codeHashes) == 0 {
+			return
+		}
+
+		rows := make([][]bob.Expression, len(codeHashes))
+
+		for i, codeHash := range codeHashes {
+			rows[i] = []bob.Expression{mysql.Arg(userID), mysql.Arg(codeHash)}
+		}
+
+		_, err = queryExec(ctx,
+			insertQuery(
+				im.Into("recovery_codes", "user_id", "code_hash"),
+				im.Rows(rows...),
+			),
+		)
+
+		return
+	})
+}
+
+// UseRecoveryCode marks an unused recovery code as used, returning ErrNotFound
+// if the user has no such code.
+func (s sqlStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("recovery_codes"),
+			um.SetCol("used_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("code_hash").EQ(mysql.Arg(codeHash)),
+				mysql.Quote("used_at").IsNull()))),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err == nil && rows == 0 {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+func (s sqlStore) GetRecoveryCodeCount(ctx context.Context, userID int64) (count int, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.F("COUNT", 1)),
+			sm.From("recovery_codes"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("used_at").IsNull()))),
+		&count,
+	)
+
+	return
+}
+
+// CreateExternalUser creates a user without a password, who signs in through
+// a linked identity provider.
+func (s sqlStore) CreateExternalUser(ctx context.Context, username string, email *string) (userID int64, err error) {
+	var emailVerifiedAt *time.Time
+
+	// Only addresses the identity provider has verified are passed in.
+	if email != nil {
+		now := time.Now()
+		emailVerifiedAt = &now
+	}
+
+	userID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("users", "username", "email", "email_verified_at", "password", "role"),
+			im.Values(mysql.Arg(username, email, emailVerifiedAt, "", "member")),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) GetIdentityUserID(ctx context.Context, issuer, subject string) (userID int64, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.Quote("i", "user_id")),
+			sm.From("user_identities").As("i"),
+			sm.InnerJoin("users").As("u").On(
+				mysql.Quote("u", "id").EQ(mysql.Quote("i", "user_id"))),
+			sm.Where(mysql.And(
+				mysql.Quote("i", "issuer").EQ(mysql.Arg(issuer)),
+				mysql.Quote("i", "subject").EQ(mysql.Arg(subject)),
+				mysql.Quote("u", "deleted_at").IsNull()))),
+		&userID,
+	)
+
+	return
+}
+
+func (s sqlStore) CreateIdentity(ctx context.Context, userID int64, issuer, subject string, email *string) (err error) {
+	_, err = queryExec(ctx,
+		insertQuery(
+			im.Into("user_identities", "user_id", "issuer", "subject", "email", "last_login_at"),
+			im.Values(mysql.Arg(userID, issuer, subject, email), mysql.F("NOW")),
+		),
+	)
+
+	return
+}
+
+func (s sqlStore) UpdateIdentityLogin(ctx context.Context, issuer, subject string, email *string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("user_identities"),
+			um.SetCol("email").ToArg(email),
+			um.SetCol("last_login_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("issuer").EQ(mysql.Arg(issuer)),
+				mysql.Quote("subject").EQ(mysql.Arg(subject))))),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserIdentities(ctx context.Context, userID int64) (identities []api.Identity, err error) {
+	var identity api.Identity
+
+	identities, err = queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "issuer", "email", "created_at", "last_login_at"),
+			sm.From("user_identities"),
+			sm.Where(mysql.Quote("user_id").EQ(mysql.Arg(userID))),
+			sm.OrderBy(mysql.Quote("created_at"))),
+		&identity, &identity.ID, &identity.Issuer, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt,
+	)
+
+	return
+}
+
+func (s sqlStore) DeleteIdentity(ctx context.Context, userID, identityID int64) (err error) {
+	_, err = queryExec(ctx,
+		deleteQuery(
+			dm.From("user_identities"),
+			dm.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(identityID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID))))),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserHasPassword(ctx context.Context, userID int64) (hasPassword bool, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(mysql.Quote("password").NE(mysql.Arg(""))),
+			sm.From("users"),
+			sm.Where(mysql.Quote("id").EQ(mysql.Arg(userID)))),
+		&hasPassword,
+	)
+
+	return
+}
+
+func (s sqlStore) CreateAPIToken(ctx context.Context, userID int64, name, tokenHash string, scopes []string, expiresAt *time.Time) (tokenID int64, err error) {
+	tokenID, err = queryInsert(ctx,
+		insertQuery(
+			im.Into("api_tokens", "user_id", "name", "token_hash", "scopes", "expires_at"),
+			im.Values(mysql.Arg(userID, name, tokenHash, strings.Join(scopes, ","), expiresAt)),
+		),
+	)
+
+	return
+}
+
+// GetAPITokenUser returns the owner and scopes of an active token that has
+// not expired, or ErrNotFound.
+func (s sqlStore) GetAPITokenUser(ctx context.Context, tokenHash string) (tokenID, userID int64, scopes []string, err error) {
+	var scopeSet string
+
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns(
+				mysql.Quote("t", "id"),
+				mysql.Quote("t", "user_id"),
+				mysql.Quote("t", "scopes")),
+			sm.From("api_tokens").As("t"),
+			sm.InnerJoin("users").As("u").On(
+				mysql.Quote("u", "id").EQ(mysql.Quote("t", "user_id"))),
+			sm.Where(mysql.And(
+				mysql.Quote("t", "token_hash").EQ(mysql.Arg(tokenHash)),
+				mysql.Quote("t", "revoked_at").IsNull(),
+				mysql.Or(
+					mysql.Quote("t", "expires_at").IsNull(),
+					mysql.Quote("t", "expires_at").GT(mysql.F("NOW"))),
+				mysql.Quote("u", "deleted_at").IsNull()))),
+		&tokenID, &userID, &scopeSet,
+	)
+
+	if scopeSet != "" {
+		scopes = strings.Split(scopeSet, ",")
+	}
+
+	return
+}
+
+// UpdateAPITokenLastUsed records token usage, at most once a minute per token
+// to avoid a write on every request.
+func (s sqlStore) UpdateAPITokenLastUsed(ctx context.Context, tokenID int64, ip string) (err error) {
+	_, err = queryExec(ctx,
+		updateQuery(
+			um.Table("api_tokens"),
+			um.SetCol("last_used_at").To(mysql.F("NOW")),
+			um.SetCol("last_used_ip").ToArg(ip),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(tokenID)),
+				mysql.Or(
+					mysql.Quote("last_used_at").IsNull(),
+					mysql.Quote("last_used_at").LT(mysql.Arg(time.Now().Add(-time.Minute))))))),
+	)
+
+	return
+}
+
+func (s sqlStore) GetUserAPITokens(ctx context.Context, userID int64) (tokens []api.APIToken, err error) {
+	type apiToken struct {
+		api.APIToken
+		scopes string
+	}
+
+	var token apiToken
+
+	rows, err := queryMany(ctx,
+		selectQuery(
+			sm.Columns("id", "name", "scopes", "created_at", "expires_at", "last_used_at", "last_used_ip"),
+			sm.From("api_tokens"),
+			sm.Where(mysql.And(
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("revoked_at").IsNull())),
+			sm.OrderBy(mysql.Quote("created_at")).Desc()),
+		&token, &token.ID, &token.Name, &token.scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP,
+	)
+
+	if err != nil {
+		return
+	}
+
+	tokens = make([]api.APIToken, len(rows))
+
+	for i, row := range rows {
+		tokens[i] = row.APIToken
+		tokens[i].Scopes = strings.Split(row.scopes, ",")
+	}
+
+	return
+}
+
+func (s sqlStore) RevokeAPIToken(ctx context.Context, userID, tokenID int64) (err error) {
+	res, err := queryExec(ctx,
+		updateQuery(
+			um.Table("api_tokens"),
+			um.SetCol("revoked_at").To(mysql.F("NOW")),
+			um.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(tokenID)),
+				mysql.Quote("user_id").EQ(mysql.Arg(userID)),
+				mysql.Quote("revoked_at").IsNull()))),
+	)
+
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err == nil && rows == 0 {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+func (s sqlStore) GetUserCreatedAt(ctx context.Context, userID int64) (createdAt time.Time, err error) {
+	err = queryOne(ctx,
+		selectQuery(
+			sm.Columns("created_at"),
+			sm.From("users"),
+			sm.Where(mysql.And(
+				mysql.Quote("id").EQ(mysql.Arg(userID)),
+				mysql.Quote("deleted_at").IsNull()))),
+		&createdAt,
+	)
+
+	return
+}
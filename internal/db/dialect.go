@@ -0,0 +1,236 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/mysql"
+	mysqldialect "github.com/stephenafamo/bob/dialect/mysql/dialect"
+	"github.com/stephenafamo/bob/dialect/mysql/im"
+	"github.com/stephenafamo/bob/dialect/psql"
+	psqldialect "github.com/stephenafamo/bob/dialect/psql/dialect"
+	pim "github.com/stephenafamo/bob/dialect/psql/im"
+)
+
+// Databases that can be selected with DB_DRIVER.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+)
+
+// driver is the database opened by Connect.
+var driver = DriverMySQL
+
+// sqlDialect is the bob dialect that queries are written in for the database
+// opened by Connect, which sets their placeholders and identifier quoting.
+func sqlDialect() bob.Dialect {
+	if driver == DriverPostgres {
+		return psqldialect.Dialect
+	}
+
+	return mysqldialect.Dialect
+}
+
+// The queries of both databases are composed with bob's mysql query mods,
+// whose clauses are written the same way by both, and started by the helpers
+// below so that they are rendered in the dialect of the database opened by
+// Connect. The few statements without a common syntax are built for each
+// database with its own mods by the helpers further down.
+
+func withDialect[E bob.Expression](q bob.BaseQuery[E]) bob.BaseQuery[E] {
+	q.Dialect = sqlDialect()
+	return q
+}
+
+func selectQuery(mods ...bob.Mod[*mysqldialect.SelectQuery]) bob.BaseQuery[*mysqldialect.SelectQuery] {
+	return withDialect(mysql.Select(mods...))
+}
+
+func insertQuery(mods ...bob.Mod[*mysqldialect.InsertQuery]) bob.BaseQuery[*mysqldialect.InsertQuery] {
+	return withDialect(mysql.Insert(mods...))
+}
+
+func updateQuery(mods ...bob.Mod[*mysqldialect.UpdateQuery]) bob.BaseQuery[*mysqldialect.UpdateQuery] {
+	return withDialect(mysql.Update(mods...))
+}
+
+func deleteQuery(mods ...bob.Mod[*mysqldialect.DeleteQuery]) bob.BaseQuery[*mysqldialect.DeleteQuery] {
+	return withDialect(mysql.Delete(mods...))
+}
+
+// returningID asks an insert for the ID of the new row, as PostgreSQL does not
+// report the last insert ID.
+type returningID struct {
+	bob.Query
+}
+
+func (q returningID) WriteQuery(w io.Writer, start int) (args []any, err error) {
+	args, err = q.Query.WriteQuery(w, start)
+	w.Write([]byte(" RETURNING "))
+	psqldialect.Dialect.WriteQuoted(w, "id")
+
+	return
+}
+
+// idList aggregates the distinct values of an ID column into a comma-separated
+// list.
+func idList(column string) bob.Expression {
+	if driver == DriverPostgres {
+		return psql.F("STRING_AGG", "DISTINCT "+column+"::text", psql.S(","))
+	}
+
+	return mysql.F("GROUP_CONCAT", "DISTINCT "+column)
+}
+
+// hoursSince is the number of hours, with a fraction, from the time in column
+// until now.
+func hoursSince(column string) string {
+	if driver == DriverPostgres {
+		return "EXTRACT(EPOCH FROM NOW() - " + column + ") / 3600"
+	}
+
+	return "TIMESTAMPDIFF(SECOND, " + column + ", NOW()) / 3600"
+}
+
+// isDuplicate reports whether err is the database rejecting a row for
+// duplicating a unique key.
+func isDuplicate(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	var pqErr *pq.Error
+
+	switch {
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1062
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23505"
+	}
+
+	return false
+}
+
+// isPermissionDenied reports whether err is the database refusing a statement
+// that the user has not been granted the privilege to run.
+func isPermissionDenied(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	var pqErr *pq.Error
+
+	switch {
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1044 || mysqlErr.Number == 1142
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "42501"
+	}
+
+	return false
+}
+
+// jsonSet sets a key of the JSON object in column to value.
+func jsonSet(column, key string, value any) (bob.Expression, error) {
+	if driver == DriverPostgres {
+		raw, err := json.Marshal(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return psql.F("JSONB_SET", psql.Quote(column), psql.Raw("ARRAY[?::text]", key), psql.Raw("?::jsonb", string(raw))), nil
+	}
+
+	return mysql.F("JSON_SET", mysql.Quote(column), mysql.Arg("$."+key), mysql.Arg(value)), nil
+}
+
+// insertIgnore inserts rows into table, skipping those that would duplicate a
+// unique key.
+func insertIgnore(table string, columns []string, rows ...[]bob.Expression) bob.Query {
+	if driver == DriverPostgres {
+		return psql.Insert(
+			pim.Into(table, columns...),
+			pim.Rows(rows...),
+			pim.OnConflict().DoNothing(),
+		)
+	}
+
+	return mysql.Insert(
+		im.Into(table, columns...),
+		im.Ignore(),
+		im.Rows(rows...),
+	)
+}
+
+// insertIgnoreQuery inserts the rows selected by q into table, skipping those
+// that would duplicate a unique key.
+func insertIgnoreQuery(table string, columns []string, q bob.Query) bob.Query {
+	if driver == DriverPostgres {
+		return psql.Insert(
+			pim.Into(table, columns...),
+			pim.Query(q),
+			pim.OnConflict().DoNothing(),
+		)
+	}
+
+	return mysql.Insert(
+		im.Into(table, columns...),
+		im.Ignore(),
+		im.Query(q),
+	)
+}
+
+// upsert inserts a row into table, or updates the given columns of the row
+// that already has the same key.
+func upsert(table string, columns, key, update []string, values ...any) bob.Query {
+	if driver == DriverPostgres {
+		target := make([]any, len(key))
+
+		for i, column := range key {
+			target[i] = psql.Quote(column)
+		}
+
+		return psql.Insert(
+			pim.Into(table, columns...),
+			pim.Values(psql.Arg(values...)),
+			pim.OnConflict(target...).DoUpdate(
+				pim.SetExcluded(update...)),
+		)
+	}
+
+	return mysql.Insert(
+		im.Into(table, columns...),
+		im.Values(mysql.Arg(values...)),
+		im.OnDuplicateKeyUpdate(
+			im.UpdateWithValues(update...)),
+	)
+}
+
+// increment adds delta to a counter column of the row of table with the given
+// key, inserting the row if there is none yet.
+func increment(table string, key []string, column string, delta int, keyValues ...any) bob.Query {
+	columns := append(slices.Clip(key), column)
+	values := append(slices.Clip(keyValues), delta)
+
+	if driver == DriverPostgres {
+		target := make([]any, len(key))
+
+		for i, column := range key {
+			target[i] = psql.Quote(column)
+		}
+
+		return psql.Insert(
+			pim.Into(table, columns...),
+			pim.Values(psql.Arg(values...)),
+			pim.OnConflict(target...).DoUpdate(
+				pim.SetCol(column).To(psql.Quote(table, column).OP("+", psql.Raw("EXCLUDED."+column)))),
+		)
+	}
+
+	return mysql.Insert(
+		im.Into(table, columns...),
+		im.Values(mysql.Arg(values...)),
+		im.OnDuplicateKeyUpdate(
+			im.UpdateCol(column).To(mysql.Quote(column).OP("+", mysql.Arg(delta)))),
+	)
+}
@@ -0,0 +1,439 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/stephenafamo/bob/dialect/mysql/dm"
+	"github.com/stephenafamo/bob/dialect/mysql/im"
+	"github.com/stephenafamo/bob/dialect/mysql/sm"
+)
+
+//go:embed migrations/mysql/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+var ErrSchemaAhead = errors.New("database schema is newer than this build")
+
+// ErrSchemaPrivileges is returned when the database user may not change the
+// schema, as in deployments set up before migrations that were only granted
+// SELECT, INSERT, UPDATE and DELETE.
+var ErrSchemaPrivileges = errors.New("database user lacks the privileges to migrate the schema (grant CREATE, ALTER, DROP, INDEX and REFERENCES, see Database Migrations in the README)")
+
+// migrationLock names the lock held while migrating, so that servers started
+// together do not apply the same migrations at once.
+const migrationLock = "schema_migrations"
+
+// migrateCtx is used for migrations, which run outside of any request.
+var migrateCtx = context.Background()
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+func loadMigrations() (migrations []Migration, err error) {
+	files, err := fs.Glob(migrationFiles, path.Join("migrations", driver, "*.sql"))
+
+	if err != nil {
+		return
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, file := range files {
+		base := path.Base(file)
+		versionStr, rest, ok := strings.Cut(base, "_")
+
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing version prefix", base)
+		}
+
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", base, err)
+		}
+
+		contents, err := migrationFiles.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			m.Name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = string(contents)
+		case strings.HasSuffix(rest, ".down.sql"):
+			m.Down = string(contents)
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", base)
+		}
+	}
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d: missing up script", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return
+}
+
+// splitStatements splits a script into statements ending with a semicolon at
+// the end of a line, except within $$-quoted function bodies.
+func splitStatements(script string) (statements []string) {
+	var current strings.Builder
+	quoted := false
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.Count(line, "$$")%2 == 1 {
+			quoted = !quoted
+		}
+
+		if !quoted && strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if strings.TrimSpace(current.String()) != "" {
+		statements = append(statements, strings.TrimSpace(current.String()))
+	}
+
+	return
+}
+
+// schemaError reports statements that the database user may not run as
+// ErrSchemaPrivileges.
+func schemaError(err error) error {
+	if isPermissionDenied(err) {
+		return fmt.Errorf("%w: %v", ErrSchemaPrivileges, err)
+	}
+
+	return err
+}
+
+func execScript(script string) (err error) {
+	for _, statement := range splitStatements(script) {
+		_, err = db.ExecContext(migrateCtx, statement)
+
+		if err != nil {
+			return schemaError(err)
+		}
+	}
+
+	return
+}
+
+// lockMigrations waits until no other server is migrating and takes the
+// migration lock, which is held by a connection of its own until unlock is
+// called.
+func lockMigrations() (unlock func(), err error) {
+	conn, err := pool.Conn(migrateCtx)
+
+	if err != nil {
+		return
+	}
+
+	if driver == DriverPostgres {
+		_, err = conn.ExecContext(migrateCtx, "SELECT pg_advisory_lock(hashtext($1))", migrationLock)
+	} else {
+		var acquired sql.NullInt64
+		err = conn.QueryRowContext(migrateCtx, "SELECT GET_LOCK(?, -1)", migrationLock).Scan(&acquired)
+
+		if err == nil && acquired.Int64 != 1 {
+			err = errors.New("could not take the migration lock")
+		}
+	}
+
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	unlock = func() {
+		if driver == DriverPostgres {
+			conn.ExecContext(migrateCtx, "SELECT pg_advisory_unlock(hashtext($1))", migrationLock)
+		} else {
+			conn.ExecContext(migrateCtx, "SELECT RELEASE_LOCK(?)", migrationLock)
+		}
+
+		conn.Close()
+	}
+
+	return
+}
+
+func ensureMigrationsTable() (err error) {
+	if driver == DriverPostgres {
+		_, err = db.ExecContext(migrateCtx, "CREATE TABLE IF NOT EXISTS schema_migrations ("+
+			"version bigint NOT NULL, "+
+			"name varchar(255) NOT NULL, "+
+			"applied_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP, "+
+			"PRIMARY KEY (version)"+
+			")")
+
+		return schemaError(err)
+	}
+
+	_, err = db.ExecContext(migrateCtx, "CREATE TABLE IF NOT EXISTS `schema_migrations` ("+
+		"`version` bigint NOT NULL, "+
+		"`name` varchar(255) NOT NULL, "+
+		"`applied_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, "+
+		"PRIMARY KEY (`version`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci")
+
+	return schemaError(err)
+}
+
+// migrationsTableExists reports whether schema_migrations has been created, so
+// that checking the schema does not change it.
+func migrationsTableExists() (exists bool, err error) {
+	if driver == DriverPostgres {
+		err = pool.QueryRowContext(migrateCtx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+		return
+	}
+
+	err = pool.QueryRowContext(migrateCtx,
+		"SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'",
+	).Scan(&exists)
+
+	return
+}
+
+// getAppliedMigrations returns when each applied migration was applied, which
+// is none if schema_migrations has not been created yet.
+func getAppliedMigrations() (applied map[int64]time.Time, err error) {
+	exists, err := migrationsTableExists()
+
+	if err != nil || !exists {
+		return
+	}
+
+	type appliedMigration struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+
+	var row appliedMigration
+
+	rows, err := queryMany(migrateCtx,
+		selectQuery(
+			sm.Columns("version", "applied_at"),
+			sm.From("schema_migrations")),
+		&row, &row.Version, &row.AppliedAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	applied = make(map[int64]time.Time, len(rows))
+
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	return
+}
+
+// GetMigrationStatus lists the embedded migrations and when each was applied,
+// without changing the schema.
+func GetMigrationStatus() (statuses []MigrationStatus, err error) {
+	migrations, err := loadMigrations()
+
+	if err != nil {
+		return
+	}
+
+	applied, err := getAppliedMigrations()
+
+	if err != nil {
+		return
+	}
+
+	known := make(map[int64]bool, len(migrations))
+
+	for _, m := range migrations {
+		known[m.Version] = true
+		status := MigrationStatus{Migration: m}
+
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for version := range applied {
+		if !known[version] {
+			err = fmt.Errorf("%w: version %04d is applied but unknown", ErrSchemaAhead, version)
+			return
+		}
+	}
+
+	return
+}
+
+func Migrate() (count int, err error) {
+	unlock, err := lockMigrations()
+
+	if err != nil {
+		return
+	}
+
+	defer unlock()
+
+	err = ensureMigrationsTable()
+
+	if err != nil {
+		return
+	}
+
+	statuses, err := GetMigrationStatus()
+
+	if err != nil {
+		return
+	}
+
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			continue
+		}
+
+		err = execScript(status.Up)
+
+		if err != nil {
+			err = fmt.Errorf("migration %04d_%s: %w", status.Version, status.Name, err)
+			return
+		}
+
+		_, err = queryExec(migrateCtx,
+			insertQuery(
+				im.Into("schema_migrations", "version", "name"),
+				im.Values(mysql.Arg(status.Version, status.Name)),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		count++
+	}
+
+	return
+}
+
+func MigrateDown(steps int) (count int, err error) {
+	unlock, err := lockMigrations()
+
+	if err != nil {
+		return
+	}
+
+	defer unlock()
+
+	err = ensureMigrationsTable()
+
+	if err != nil {
+		return
+	}
+
+	statuses, err := GetMigrationStatus()
+
+	if err != nil {
+		return
+	}
+
+	for i := len(statuses) - 1; i >= 0 && count < steps; i-- {
+		status := statuses[i]
+
+		if status.AppliedAt == nil {
+			continue
+		}
+
+		if status.Down == "" {
+			err = fmt.Errorf("migration %04d_%s: missing down script", status.Version, status.Name)
+			return
+		}
+
+		err = execScript(status.Down)
+
+		if err != nil {
+			err = fmt.Errorf("migration %04d_%s: %w", status.Version, status.Name, err)
+			return
+		}
+
+		_, err = queryExec(migrateCtx,
+			deleteQuery(
+				dm.From("schema_migrations"),
+				dm.Where(mysql.Quote("version").EQ(mysql.Arg(status.Version))),
+			),
+		)
+
+		if err != nil {
+			return
+		}
+
+		count++
+	}
+
+	return
+}
+
+func CheckMigrations() (pending int, err error) {
+	statuses, err := GetMigrationStatus()
+
+	if err != nil {
+		return
+	}
+
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+
+	return
+}
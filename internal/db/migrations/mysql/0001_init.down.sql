@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS `comment_reactions`;
+DROP TABLE IF EXISTS `post_reactions`;
+DROP TABLE IF EXISTS `reactions`;
+DROP TABLE IF EXISTS `post_tags`;
+DROP TABLE IF EXISTS `tags`;
+DROP TABLE IF EXISTS `comments`;
+DROP TABLE IF EXISTS `posts`;
+DROP TABLE IF EXISTS `users`;
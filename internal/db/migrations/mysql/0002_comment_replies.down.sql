@@ -0,0 +1,5 @@
+ALTER TABLE `comments`
+  DROP FOREIGN KEY `fk_comments_parent`,
+  DROP KEY `fk_comments_parent`,
+  DROP COLUMN `depth`,
+  DROP COLUMN `parent_id`;
@@ -0,0 +1,5 @@
+ALTER TABLE `comments`
+  ADD COLUMN `parent_id` int NULL DEFAULT NULL AFTER `post_id`,
+  ADD COLUMN `depth` int NOT NULL DEFAULT 0 AFTER `parent_id`,
+  ADD KEY `fk_comments_parent` (`parent_id`),
+  ADD CONSTRAINT `fk_comments_parent` FOREIGN KEY (`parent_id`) REFERENCES `comments` (`id`);
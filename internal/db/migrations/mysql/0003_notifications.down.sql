@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `notification_actors`;
+DROP TABLE IF EXISTS `notifications`;
@@ -0,0 +1,29 @@
+CREATE TABLE `notifications` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `actor_id` int NOT NULL,
+  `type` enum('post_comment','comment_reply','thread_comment','post_reaction','comment_reaction') NOT NULL,
+  `post_id` int NOT NULL,
+  `comment_id` int NULL DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `read_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_notifications_user` (`user_id`,`read_at`,`updated_at`),
+  KEY `fk_notifications_actor` (`actor_id`),
+  KEY `fk_notifications_post` (`post_id`),
+  KEY `fk_notifications_comment` (`comment_id`),
+  CONSTRAINT `fk_notifications_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_notifications_actor` FOREIGN KEY (`actor_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_notifications_post` FOREIGN KEY (`post_id`) REFERENCES `posts` (`id`),
+  CONSTRAINT `fk_notifications_comment` FOREIGN KEY (`comment_id`) REFERENCES `comments` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `notification_actors` (
+  `notification_id` int NOT NULL,
+  `user_id` int NOT NULL,
+  PRIMARY KEY (`notification_id`,`user_id`),
+  KEY `fk_notification_actors_user` (`user_id`),
+  CONSTRAINT `fk_notification_actors_notification` FOREIGN KEY (`notification_id`) REFERENCES `notifications` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_notification_actors_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
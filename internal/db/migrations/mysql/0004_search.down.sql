@@ -0,0 +1,7 @@
+ALTER TABLE `comments`
+  DROP KEY `ft_comments`,
+  DROP COLUMN `body_text`;
+
+ALTER TABLE `posts`
+  DROP KEY `ft_posts`,
+  DROP COLUMN `body_text`;
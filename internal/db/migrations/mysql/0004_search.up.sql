@@ -0,0 +1,15 @@
+ALTER TABLE `posts`
+  ADD COLUMN `body_text` text NOT NULL AFTER `body`;
+
+ALTER TABLE `comments`
+  ADD COLUMN `body_text` text NOT NULL AFTER `body`;
+
+UPDATE `posts` SET `body_text` = TRIM(REGEXP_REPLACE(`body`, '<[^>]*>', ' '));
+
+UPDATE `comments` SET `body_text` = TRIM(REGEXP_REPLACE(`body`, '<[^>]*>', ' '));
+
+ALTER TABLE `posts`
+  ADD FULLTEXT KEY `ft_posts` (`title`,`body_text`);
+
+ALTER TABLE `comments`
+  ADD FULLTEXT KEY `ft_comments` (`body_text`);
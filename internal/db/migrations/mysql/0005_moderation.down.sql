@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `suspensions`;
+DROP TABLE IF EXISTS `warnings`;
+DROP TABLE IF EXISTS `reports`;
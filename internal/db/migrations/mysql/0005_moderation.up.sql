@@ -0,0 +1,58 @@
+CREATE TABLE `reports` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `reporter_id` int NOT NULL,
+  `target_type` enum('post','comment','user') NOT NULL,
+  `target_id` int NOT NULL,
+  `reason` enum('spam','harassment','hate','violence','nsfw','misinformation','other') NOT NULL,
+  `note` text NOT NULL,
+  `status` enum('open','claimed','resolved') NOT NULL DEFAULT 'open',
+  `assignee_id` int NULL DEFAULT NULL,
+  `resolution` enum('dismiss','delete','warn','suspend') NULL DEFAULT NULL,
+  `resolution_note` text,
+  `resolved_by` int NULL DEFAULT NULL,
+  `resolved_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_reports_status` (`status`,`created_at`),
+  KEY `idx_reports_target` (`target_type`,`target_id`),
+  KEY `fk_reports_reporter` (`reporter_id`),
+  KEY `fk_reports_assignee` (`assignee_id`),
+  KEY `fk_reports_resolved_by` (`resolved_by`),
+  CONSTRAINT `fk_reports_reporter` FOREIGN KEY (`reporter_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_reports_assignee` FOREIGN KEY (`assignee_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_reports_resolved_by` FOREIGN KEY (`resolved_by`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `warnings` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `report_id` int NULL DEFAULT NULL,
+  `reason` text NOT NULL,
+  `created_by` int NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `fk_warnings_user` (`user_id`),
+  KEY `fk_warnings_report` (`report_id`),
+  KEY `fk_warnings_created_by` (`created_by`),
+  CONSTRAINT `fk_warnings_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_warnings_report` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`),
+  CONSTRAINT `fk_warnings_created_by` FOREIGN KEY (`created_by`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `suspensions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `report_id` int NULL DEFAULT NULL,
+  `reason` text NOT NULL,
+  `ends_at` timestamp NULL DEFAULT NULL,
+  `created_by` int NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_suspensions_user` (`user_id`,`ends_at`),
+  KEY `fk_suspensions_report` (`report_id`),
+  KEY `fk_suspensions_created_by` (`created_by`),
+  CONSTRAINT `fk_suspensions_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
+  CONSTRAINT `fk_suspensions_report` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`),
+  CONSTRAINT `fk_suspensions_created_by` FOREIGN KEY (`created_by`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS `tag_moderators`;
+
+UPDATE `users` SET `role` = 'member' WHERE `role` = 'moderator';
+
+ALTER TABLE `users`
+  MODIFY COLUMN `role` enum('member','admin') NOT NULL;
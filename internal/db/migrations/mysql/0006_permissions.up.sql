@@ -0,0 +1,12 @@
+ALTER TABLE `users`
+  MODIFY COLUMN `role` enum('member','moderator','admin') NOT NULL;
+
+CREATE TABLE `tag_moderators` (
+  `tag_id` int NOT NULL,
+  `user_id` int NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`tag_id`,`user_id`),
+  KEY `fk_tag_moderators_user` (`user_id`),
+  CONSTRAINT `fk_tag_moderators_tag` FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`),
+  CONSTRAINT `fk_tag_moderators_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
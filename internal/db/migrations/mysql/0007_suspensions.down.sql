@@ -0,0 +1,5 @@
+ALTER TABLE `suspensions`
+  DROP FOREIGN KEY `fk_suspensions_lifted_by`,
+  DROP KEY `fk_suspensions_lifted_by`,
+  DROP COLUMN `lifted_by`,
+  DROP COLUMN `lifted_at`;
@@ -0,0 +1,5 @@
+ALTER TABLE `suspensions`
+  ADD COLUMN `lifted_at` timestamp NULL DEFAULT NULL AFTER `ends_at`,
+  ADD COLUMN `lifted_by` int NULL DEFAULT NULL AFTER `lifted_at`,
+  ADD KEY `fk_suspensions_lifted_by` (`lifted_by`),
+  ADD CONSTRAINT `fk_suspensions_lifted_by` FOREIGN KEY (`lifted_by`) REFERENCES `users` (`id`);
@@ -0,0 +1,19 @@
+ALTER TABLE `users`
+  ADD COLUMN `email` varchar(255) NULL DEFAULT NULL AFTER `username`,
+  ADD COLUMN `email_verified_at` timestamp NULL DEFAULT NULL AFTER `email`,
+  ADD UNIQUE KEY `email` (`email`);
+
+CREATE TABLE `user_tokens` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `purpose` enum('verify_email','reset_password') NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash` (`token_hash`),
+  KEY `idx_user_tokens_user` (`user_id`,`purpose`),
+  CONSTRAINT `fk_user_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,24 @@
+ALTER TABLE `users`
+  ADD COLUMN `totp_secret` varchar(64) NULL DEFAULT NULL AFTER `password`,
+  ADD COLUMN `totp_enabled_at` timestamp NULL DEFAULT NULL AFTER `totp_secret`,
+  ADD COLUMN `totp_last_step` bigint NULL DEFAULT NULL AFTER `totp_enabled_at`;
+
+CREATE TABLE `recovery_codes` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `user_code` (`user_id`,`code_hash`),
+  CONSTRAINT `fk_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `settings` (
+  `key` varchar(64) NOT NULL,
+  `value` json NOT NULL,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT IGNORE INTO `settings` (`key`, `value`) VALUES ('twoFactorRoles', '[]');
@@ -0,0 +1,34 @@
+ALTER TABLE `posts`
+  ADD COLUMN `edited_at` timestamp NULL DEFAULT NULL AFTER `updated_at`,
+  ADD COLUMN `edited_by` int NULL DEFAULT NULL AFTER `edited_at`,
+  ADD CONSTRAINT `fk_posts_edited_by` FOREIGN KEY (`edited_by`) REFERENCES `users` (`id`);
+
+ALTER TABLE `comments`
+  ADD COLUMN `edited_at` timestamp NULL DEFAULT NULL AFTER `updated_at`,
+  ADD COLUMN `edited_by` int NULL DEFAULT NULL AFTER `edited_at`,
+  ADD CONSTRAINT `fk_comments_edited_by` FOREIGN KEY (`edited_by`) REFERENCES `users` (`id`);
+
+CREATE TABLE `post_revisions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `post_id` int NOT NULL,
+  `editor_id` int NOT NULL,
+  `title` text NOT NULL,
+  `body` text NOT NULL,
+  `created_at` timestamp NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_post_revisions_post` (`post_id`,`id`),
+  CONSTRAINT `fk_post_revisions_post` FOREIGN KEY (`post_id`) REFERENCES `posts` (`id`),
+  CONSTRAINT `fk_post_revisions_editor` FOREIGN KEY (`editor_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `comment_revisions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `comment_id` int NOT NULL,
+  `editor_id` int NOT NULL,
+  `body` text NOT NULL,
+  `created_at` timestamp NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_comment_revisions_comment` (`comment_id`,`id`),
+  CONSTRAINT `fk_comment_revisions_comment` FOREIGN KEY (`comment_id`) REFERENCES `comments` (`id`),
+  CONSTRAINT `fk_comment_revisions_editor` FOREIGN KEY (`editor_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,5 @@
+ALTER TABLE `comments`
+  DROP KEY `idx_comments_post_created`;
+
+ALTER TABLE `posts`
+  DROP KEY `idx_posts_created`;
@@ -0,0 +1,5 @@
+ALTER TABLE `posts`
+  ADD KEY `idx_posts_created` (`created_at`,`id`);
+
+ALTER TABLE `comments`
+  ADD KEY `idx_comments_post_created` (`post_id`,`created_at`,`id`);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS `comment_reaction_counts`;
+DROP TABLE IF EXISTS `post_reaction_counts`;
+
+ALTER TABLE `posts`
+  DROP KEY `idx_posts_reaction_count`,
+  DROP KEY `idx_posts_comment_count`,
+  DROP COLUMN `reaction_count`,
+  DROP COLUMN `comment_count`;
+
+ALTER TABLE `users`
+  DROP COLUMN `comment_count`,
+  DROP COLUMN `post_count`;
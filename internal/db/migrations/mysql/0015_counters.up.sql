@@ -0,0 +1,44 @@
+ALTER TABLE `users`
+  ADD COLUMN `post_count` int NOT NULL DEFAULT 0,
+  ADD COLUMN `comment_count` int NOT NULL DEFAULT 0;
+
+ALTER TABLE `posts`
+  ADD COLUMN `comment_count` int NOT NULL DEFAULT 0,
+  ADD COLUMN `reaction_count` int NOT NULL DEFAULT 0,
+  ADD KEY `idx_posts_comment_count` (`comment_count`,`id`),
+  ADD KEY `idx_posts_reaction_count` (`reaction_count`,`id`);
+
+CREATE TABLE `post_reaction_counts` (
+  `post_id` int NOT NULL,
+  `reaction_id` int NOT NULL,
+  `count` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`post_id`,`reaction_id`),
+  KEY `fk_post_reaction_counts_reaction` (`reaction_id`),
+  CONSTRAINT `fk_post_reaction_counts_post` FOREIGN KEY (`post_id`) REFERENCES `posts` (`id`),
+  CONSTRAINT `fk_post_reaction_counts_reaction` FOREIGN KEY (`reaction_id`) REFERENCES `reactions` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `comment_reaction_counts` (
+  `comment_id` int NOT NULL,
+  `reaction_id` int NOT NULL,
+  `count` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`comment_id`,`reaction_id`),
+  KEY `fk_comment_reaction_counts_reaction` (`reaction_id`),
+  CONSTRAINT `fk_comment_reaction_counts_comment` FOREIGN KEY (`comment_id`) REFERENCES `comments` (`id`),
+  CONSTRAINT `fk_comment_reaction_counts_reaction` FOREIGN KEY (`reaction_id`) REFERENCES `reactions` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+UPDATE `users` SET
+  `post_count` = (SELECT COUNT(*) FROM `posts` WHERE `posts`.`user_id` = `users`.`id` AND `posts`.`deleted_at` IS NULL),
+  `comment_count` = (SELECT COUNT(*) FROM `comments` WHERE `comments`.`user_id` = `users`.`id` AND `comments`.`deleted_at` IS NULL);
+
+UPDATE `posts` SET
+  `comment_count` = (SELECT COUNT(*) FROM `comments` WHERE `comments`.`post_id` = `posts`.`id` AND `comments`.`deleted_at` IS NULL),
+  `reaction_count` = (SELECT COUNT(*) FROM `post_reactions` WHERE `post_reactions`.`post_id` = `posts`.`id`),
+  `updated_at` = `updated_at`;
+
+INSERT INTO `post_reaction_counts` (`post_id`, `reaction_id`, `count`)
+  SELECT `post_id`, `reaction_id`, COUNT(*) FROM `post_reactions` GROUP BY `post_id`, `reaction_id`;
+
+INSERT INTO `comment_reaction_counts` (`comment_id`, `reaction_id`, `count`)
+  SELECT `comment_id`, `reaction_id`, COUNT(*) FROM `comment_reactions` GROUP BY `comment_id`, `reaction_id`;
@@ -0,0 +1,3 @@
+ALTER TABLE `posts`
+  DROP KEY `idx_posts_hot_score`,
+  DROP COLUMN `hot_score`;
@@ -0,0 +1,3 @@
+ALTER TABLE `posts`
+  ADD COLUMN `hot_score` double NOT NULL DEFAULT 0,
+  ADD KEY `idx_posts_hot_score` (`hot_score`,`id`);
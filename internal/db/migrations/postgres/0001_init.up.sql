@@ -0,0 +1,128 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  IF NEW IS DISTINCT FROM OLD THEN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE users (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  username citext NOT NULL,
+  password varchar(60) NOT NULL,
+  role varchar(16) NOT NULL,
+  bio text,
+  avatar text,
+  prefs jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT users_username_key UNIQUE (username),
+  CONSTRAINT users_role_check CHECK (role IN ('member', 'admin'))
+);
+
+CREATE TABLE posts (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  title text NOT NULL,
+  body text NOT NULL,
+  user_id integer NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_users_posts FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_users_posts ON posts (user_id);
+
+CREATE TRIGGER posts_updated_at BEFORE UPDATE ON posts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE comments (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  body text NOT NULL,
+  user_id integer NOT NULL,
+  post_id integer NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_posts_comments FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_users_comments FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_users_comments ON comments (user_id);
+
+CREATE INDEX fk_posts_comments ON comments (post_id);
+
+CREATE TRIGGER comments_updated_at BEFORE UPDATE ON comments
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE tags (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  name citext NOT NULL,
+  color varchar(32) NOT NULL,
+  description text NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT tags_name_key UNIQUE (name)
+);
+
+CREATE TABLE post_tags (
+  post_id integer NOT NULL,
+  tag_id integer NOT NULL,
+  PRIMARY KEY (post_id, tag_id),
+  CONSTRAINT fk_post_tags_post FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_post_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE INDEX fk_post_tags_tag ON post_tags (tag_id);
+
+CREATE TABLE reactions (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  name citext NOT NULL,
+  type varchar(16) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT reactions_name_key UNIQUE (name),
+  CONSTRAINT reactions_type_check CHECK (type IN ('post', 'comment'))
+);
+
+CREATE TABLE post_reactions (
+  user_id integer NOT NULL,
+  post_id integer NOT NULL,
+  reaction_id integer NOT NULL,
+  PRIMARY KEY (user_id, post_id),
+  CONSTRAINT fk_post_reactions_post FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_post_reactions_reaction FOREIGN KEY (reaction_id) REFERENCES reactions (id),
+  CONSTRAINT fk_post_reactions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_post_reactions_post ON post_reactions (post_id);
+
+CREATE INDEX fk_post_reactions_reaction ON post_reactions (reaction_id);
+
+CREATE TABLE comment_reactions (
+  user_id integer NOT NULL,
+  comment_id integer NOT NULL,
+  reaction_id integer NOT NULL,
+  PRIMARY KEY (user_id, comment_id),
+  CONSTRAINT fk_comment_reactions_comment FOREIGN KEY (comment_id) REFERENCES comments (id),
+  CONSTRAINT fk_comment_reactions_reaction FOREIGN KEY (reaction_id) REFERENCES reactions (id),
+  CONSTRAINT fk_comment_reactions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_comment_reactions_comment ON comment_reactions (comment_id);
+
+CREATE INDEX fk_comment_reactions_reaction ON comment_reactions (reaction_id);
+
+INSERT INTO reactions (id, name, type) VALUES (1,'Laugh','post'),(2,'Love','post'),(3,'Wow','post'),(4,'Think','post'),(5,'Sus','post'),(6,'Cry','post'),(7,'Angry','post'),(8,'Upvote','comment'),(9,'Downvote','comment') ON CONFLICT DO NOTHING;
+
+INSERT INTO users (id, username, password, role, bio, avatar, prefs, created_at, deleted_at) VALUES (1,'admin','$2a$10$DlIl8WyWB8OKxEAzAdMq4eWKy9PLshJE0pdDhBItlRdqZvtdKgwyO','admin',NULL,NULL,'{}','2024-01-01 00:00:00+00',NULL) ON CONFLICT DO NOTHING;
+
+-- Rows inserted with explicit IDs do not advance the identity sequences.
+SELECT setval(pg_get_serial_sequence('reactions', 'id'), (SELECT MAX(id) FROM reactions));
+
+SELECT setval(pg_get_serial_sequence('users', 'id'), (SELECT MAX(id) FROM users));
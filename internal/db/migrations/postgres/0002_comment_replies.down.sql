@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS fk_comments_parent;
+
+ALTER TABLE comments
+  DROP CONSTRAINT fk_comments_parent,
+  DROP COLUMN depth,
+  DROP COLUMN parent_id;
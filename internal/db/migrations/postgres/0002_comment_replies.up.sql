@@ -0,0 +1,6 @@
+ALTER TABLE comments
+  ADD COLUMN parent_id integer NULL DEFAULT NULL,
+  ADD COLUMN depth integer NOT NULL DEFAULT 0,
+  ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments (id);
+
+CREATE INDEX fk_comments_parent ON comments (parent_id);
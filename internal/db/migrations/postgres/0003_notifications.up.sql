@@ -0,0 +1,38 @@
+CREATE TABLE notifications (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  actor_id integer NOT NULL,
+  type varchar(32) NOT NULL,
+  post_id integer NOT NULL,
+  comment_id integer NULL DEFAULT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  read_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT notifications_type_check CHECK (type IN ('post_comment', 'comment_reply', 'thread_comment', 'post_reaction', 'comment_reaction')),
+  CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_notifications_actor FOREIGN KEY (actor_id) REFERENCES users (id),
+  CONSTRAINT fk_notifications_post FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_notifications_comment FOREIGN KEY (comment_id) REFERENCES comments (id)
+);
+
+CREATE INDEX idx_notifications_user ON notifications (user_id, read_at, updated_at);
+
+CREATE INDEX fk_notifications_actor ON notifications (actor_id);
+
+CREATE INDEX fk_notifications_post ON notifications (post_id);
+
+CREATE INDEX fk_notifications_comment ON notifications (comment_id);
+
+CREATE TRIGGER notifications_updated_at BEFORE UPDATE ON notifications
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE notification_actors (
+  notification_id integer NOT NULL,
+  user_id integer NOT NULL,
+  PRIMARY KEY (notification_id, user_id),
+  CONSTRAINT fk_notification_actors_notification FOREIGN KEY (notification_id) REFERENCES notifications (id) ON DELETE CASCADE,
+  CONSTRAINT fk_notification_actors_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_notification_actors_user ON notification_actors (user_id);
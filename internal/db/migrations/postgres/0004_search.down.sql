@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS ft_comments;
+
+DROP INDEX IF EXISTS ft_posts;
+
+ALTER TABLE comments
+  DROP COLUMN search_vector,
+  DROP COLUMN body_text;
+
+ALTER TABLE posts
+  DROP COLUMN search_vector,
+  DROP COLUMN body_text;
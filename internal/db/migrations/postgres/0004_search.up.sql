@@ -0,0 +1,21 @@
+ALTER TABLE posts
+  ADD COLUMN body_text text NOT NULL DEFAULT '';
+
+ALTER TABLE comments
+  ADD COLUMN body_text text NOT NULL DEFAULT '';
+
+UPDATE posts SET body_text = TRIM(REGEXP_REPLACE(body, '<[^>]*>', ' ', 'g'));
+
+UPDATE comments SET body_text = TRIM(REGEXP_REPLACE(body, '<[^>]*>', ' ', 'g'));
+
+ALTER TABLE posts
+  ALTER COLUMN body_text DROP DEFAULT,
+  ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || body_text)) STORED;
+
+ALTER TABLE comments
+  ALTER COLUMN body_text DROP DEFAULT,
+  ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', body_text)) STORED;
+
+CREATE INDEX ft_posts ON posts USING GIN (search_vector);
+
+CREATE INDEX ft_comments ON comments USING GIN (search_vector);
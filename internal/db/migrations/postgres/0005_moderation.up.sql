@@ -0,0 +1,76 @@
+CREATE TABLE reports (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  reporter_id integer NOT NULL,
+  target_type varchar(16) NOT NULL,
+  target_id integer NOT NULL,
+  reason varchar(32) NOT NULL,
+  note text NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'open',
+  assignee_id integer NULL DEFAULT NULL,
+  resolution varchar(16) NULL DEFAULT NULL,
+  resolution_note text,
+  resolved_by integer NULL DEFAULT NULL,
+  resolved_at timestamptz NULL DEFAULT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT reports_target_type_check CHECK (target_type IN ('post', 'comment', 'user')),
+  CONSTRAINT reports_reason_check CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'nsfw', 'misinformation', 'other')),
+  CONSTRAINT reports_status_check CHECK (status IN ('open', 'claimed', 'resolved')),
+  CONSTRAINT reports_resolution_check CHECK (resolution IN ('dismiss', 'delete', 'warn', 'suspend')),
+  CONSTRAINT fk_reports_reporter FOREIGN KEY (reporter_id) REFERENCES users (id),
+  CONSTRAINT fk_reports_assignee FOREIGN KEY (assignee_id) REFERENCES users (id),
+  CONSTRAINT fk_reports_resolved_by FOREIGN KEY (resolved_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_reports_status ON reports (status, created_at);
+
+CREATE INDEX idx_reports_target ON reports (target_type, target_id);
+
+CREATE INDEX fk_reports_reporter ON reports (reporter_id);
+
+CREATE INDEX fk_reports_assignee ON reports (assignee_id);
+
+CREATE INDEX fk_reports_resolved_by ON reports (resolved_by);
+
+CREATE TRIGGER reports_updated_at BEFORE UPDATE ON reports
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE warnings (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  report_id integer NULL DEFAULT NULL,
+  reason text NOT NULL,
+  created_by integer NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_warnings_user FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_warnings_report FOREIGN KEY (report_id) REFERENCES reports (id),
+  CONSTRAINT fk_warnings_created_by FOREIGN KEY (created_by) REFERENCES users (id)
+);
+
+CREATE INDEX fk_warnings_user ON warnings (user_id);
+
+CREATE INDEX fk_warnings_report ON warnings (report_id);
+
+CREATE INDEX fk_warnings_created_by ON warnings (created_by);
+
+CREATE TABLE suspensions (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  report_id integer NULL DEFAULT NULL,
+  reason text NOT NULL,
+  ends_at timestamptz NULL DEFAULT NULL,
+  created_by integer NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_suspensions_user FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_suspensions_report FOREIGN KEY (report_id) REFERENCES reports (id),
+  CONSTRAINT fk_suspensions_created_by FOREIGN KEY (created_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_suspensions_user ON suspensions (user_id, ends_at);
+
+CREATE INDEX fk_suspensions_report ON suspensions (report_id);
+
+CREATE INDEX fk_suspensions_created_by ON suspensions (created_by);
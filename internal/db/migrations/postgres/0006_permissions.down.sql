@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS tag_moderators;
+
+UPDATE users SET role = 'member' WHERE role = 'moderator';
+
+ALTER TABLE users
+  DROP CONSTRAINT users_role_check,
+  ADD CONSTRAINT users_role_check CHECK (role IN ('member', 'admin'));
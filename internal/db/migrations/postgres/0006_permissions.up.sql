@@ -0,0 +1,14 @@
+ALTER TABLE users
+  DROP CONSTRAINT users_role_check,
+  ADD CONSTRAINT users_role_check CHECK (role IN ('member', 'moderator', 'admin'));
+
+CREATE TABLE tag_moderators (
+  tag_id integer NOT NULL,
+  user_id integer NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (tag_id, user_id),
+  CONSTRAINT fk_tag_moderators_tag FOREIGN KEY (tag_id) REFERENCES tags (id),
+  CONSTRAINT fk_tag_moderators_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_tag_moderators_user ON tag_moderators (user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS fk_suspensions_lifted_by;
+
+ALTER TABLE suspensions
+  DROP CONSTRAINT fk_suspensions_lifted_by,
+  DROP COLUMN lifted_by,
+  DROP COLUMN lifted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE suspensions
+  ADD COLUMN lifted_at timestamptz NULL DEFAULT NULL,
+  ADD COLUMN lifted_by integer NULL DEFAULT NULL,
+  ADD CONSTRAINT fk_suspensions_lifted_by FOREIGN KEY (lifted_by) REFERENCES users (id);
+
+CREATE INDEX fk_suspensions_lifted_by ON suspensions (lifted_by);
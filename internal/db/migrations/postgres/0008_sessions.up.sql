@@ -0,0 +1,20 @@
+CREATE TABLE sessions (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  token_hash char(64) NOT NULL,
+  previous_token_hash char(64) NULL DEFAULT NULL,
+  user_agent varchar(512) NOT NULL DEFAULT '',
+  ip varchar(45) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  rotated_at timestamptz NULL DEFAULT NULL,
+  last_seen_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT sessions_token_hash_key UNIQUE (token_hash),
+  CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_sessions_previous_token_hash ON sessions (previous_token_hash);
+
+CREATE INDEX idx_sessions_user ON sessions (user_id, revoked_at);
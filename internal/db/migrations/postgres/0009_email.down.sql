@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users
+  DROP CONSTRAINT users_email_key,
+  DROP COLUMN email_verified_at,
+  DROP COLUMN email;
@@ -0,0 +1,21 @@
+ALTER TABLE users
+  ADD COLUMN email citext NULL DEFAULT NULL,
+  ADD COLUMN email_verified_at timestamptz NULL DEFAULT NULL,
+  ADD CONSTRAINT users_email_key UNIQUE (email);
+
+CREATE TABLE user_tokens (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  purpose varchar(16) NOT NULL,
+  token_hash char(64) NOT NULL,
+  email citext NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT user_tokens_purpose_check CHECK (purpose IN ('verify_email', 'reset_password')),
+  CONSTRAINT user_tokens_token_hash_key UNIQUE (token_hash),
+  CONSTRAINT fk_user_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_user_tokens_user ON user_tokens (user_id, purpose);
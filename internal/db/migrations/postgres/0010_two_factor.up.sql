@@ -0,0 +1,27 @@
+ALTER TABLE users
+  ADD COLUMN totp_secret varchar(64) NULL DEFAULT NULL,
+  ADD COLUMN totp_enabled_at timestamptz NULL DEFAULT NULL,
+  ADD COLUMN totp_last_step bigint NULL DEFAULT NULL;
+
+CREATE TABLE recovery_codes (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  code_hash char(64) NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  used_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT recovery_codes_user_code_key UNIQUE (user_id, code_hash),
+  CONSTRAINT fk_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE settings (
+  key varchar(64) NOT NULL,
+  value jsonb NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (key)
+);
+
+CREATE TRIGGER settings_updated_at BEFORE UPDATE ON settings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO settings (key, value) VALUES ('twoFactorRoles', '[]') ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+CREATE TABLE user_identities (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  issuer varchar(255) NOT NULL,
+  subject varchar(255) NOT NULL,
+  email citext NULL DEFAULT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_login_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT user_identities_issuer_subject_key UNIQUE (issuer, subject),
+  CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX fk_user_identities_user ON user_identities (user_id);
@@ -0,0 +1,17 @@
+CREATE TABLE api_tokens (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  user_id integer NOT NULL,
+  name varchar(64) NOT NULL,
+  token_hash char(64) NOT NULL,
+  scopes varchar(32) NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamptz NULL DEFAULT NULL,
+  last_used_at timestamptz NULL DEFAULT NULL,
+  last_used_ip varchar(45) NULL DEFAULT NULL,
+  revoked_at timestamptz NULL DEFAULT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT api_tokens_token_hash_key UNIQUE (token_hash),
+  CONSTRAINT fk_api_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_api_tokens_user ON api_tokens (user_id, revoked_at);
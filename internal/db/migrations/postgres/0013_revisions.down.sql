@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS comment_revisions;
+DROP TABLE IF EXISTS post_revisions;
+
+ALTER TABLE comments
+  DROP CONSTRAINT fk_comments_edited_by,
+  DROP COLUMN edited_by,
+  DROP COLUMN edited_at;
+
+ALTER TABLE posts
+  DROP CONSTRAINT fk_posts_edited_by,
+  DROP COLUMN edited_by,
+  DROP COLUMN edited_at;
@@ -0,0 +1,36 @@
+ALTER TABLE posts
+  ADD COLUMN edited_at timestamptz NULL DEFAULT NULL,
+  ADD COLUMN edited_by integer NULL DEFAULT NULL,
+  ADD CONSTRAINT fk_posts_edited_by FOREIGN KEY (edited_by) REFERENCES users (id);
+
+ALTER TABLE comments
+  ADD COLUMN edited_at timestamptz NULL DEFAULT NULL,
+  ADD COLUMN edited_by integer NULL DEFAULT NULL,
+  ADD CONSTRAINT fk_comments_edited_by FOREIGN KEY (edited_by) REFERENCES users (id);
+
+CREATE TABLE post_revisions (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  post_id integer NOT NULL,
+  editor_id integer NOT NULL,
+  title text NOT NULL,
+  body text NOT NULL,
+  created_at timestamptz NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_post_revisions_post FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_post_revisions_editor FOREIGN KEY (editor_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_post_revisions_post ON post_revisions (post_id, id);
+
+CREATE TABLE comment_revisions (
+  id integer GENERATED BY DEFAULT AS IDENTITY,
+  comment_id integer NOT NULL,
+  editor_id integer NOT NULL,
+  body text NOT NULL,
+  created_at timestamptz NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_comment_revisions_comment FOREIGN KEY (comment_id) REFERENCES comments (id),
+  CONSTRAINT fk_comment_revisions_editor FOREIGN KEY (editor_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_comment_revisions_comment ON comment_revisions (comment_id, id);
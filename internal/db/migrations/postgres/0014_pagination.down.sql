@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_comments_post_created;
+
+DROP INDEX IF EXISTS idx_posts_created;
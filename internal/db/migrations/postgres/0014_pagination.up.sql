@@ -0,0 +1,3 @@
+CREATE INDEX idx_posts_created ON posts (created_at, id);
+
+CREATE INDEX idx_comments_post_created ON comments (post_id, created_at, id);
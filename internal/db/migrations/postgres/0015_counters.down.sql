@@ -0,0 +1,34 @@
+DROP TABLE IF EXISTS comment_reaction_counts;
+DROP TABLE IF EXISTS post_reaction_counts;
+
+DROP TRIGGER IF EXISTS comments_updated_at ON comments;
+
+CREATE TRIGGER comments_updated_at BEFORE UPDATE ON comments
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS posts_updated_at ON posts;
+
+CREATE TRIGGER posts_updated_at BEFORE UPDATE ON posts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  IF NEW IS DISTINCT FROM OLD THEN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_posts_reaction_count;
+
+DROP INDEX IF EXISTS idx_posts_comment_count;
+
+ALTER TABLE posts
+  DROP COLUMN reaction_count,
+  DROP COLUMN comment_count;
+
+ALTER TABLE users
+  DROP COLUMN comment_count,
+  DROP COLUMN post_count;
@@ -0,0 +1,72 @@
+ALTER TABLE users
+  ADD COLUMN post_count integer NOT NULL DEFAULT 0,
+  ADD COLUMN comment_count integer NOT NULL DEFAULT 0;
+
+ALTER TABLE posts
+  ADD COLUMN comment_count integer NOT NULL DEFAULT 0,
+  ADD COLUMN reaction_count integer NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_posts_comment_count ON posts (comment_count, id);
+
+CREATE INDEX idx_posts_reaction_count ON posts (reaction_count, id);
+
+-- Columns named as trigger arguments are ignored when deciding whether a row
+-- has changed, so that maintaining counters does not touch updated_at.
+-- Generated columns are passed too, as they are not computed yet in NEW.
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+DECLARE
+  ignored text[] := COALESCE(TG_ARGV, '{}');
+BEGIN
+  IF to_jsonb(NEW) - ignored IS DISTINCT FROM to_jsonb(OLD) - ignored THEN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_updated_at ON posts;
+
+CREATE TRIGGER posts_updated_at BEFORE UPDATE ON posts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at('search_vector', 'comment_count', 'reaction_count');
+
+DROP TRIGGER IF EXISTS comments_updated_at ON comments;
+
+CREATE TRIGGER comments_updated_at BEFORE UPDATE ON comments
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at('search_vector');
+
+CREATE TABLE post_reaction_counts (
+  post_id integer NOT NULL,
+  reaction_id integer NOT NULL,
+  count integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (post_id, reaction_id),
+  CONSTRAINT fk_post_reaction_counts_post FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT fk_post_reaction_counts_reaction FOREIGN KEY (reaction_id) REFERENCES reactions (id)
+);
+
+CREATE INDEX fk_post_reaction_counts_reaction ON post_reaction_counts (reaction_id);
+
+CREATE TABLE comment_reaction_counts (
+  comment_id integer NOT NULL,
+  reaction_id integer NOT NULL,
+  count integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (comment_id, reaction_id),
+  CONSTRAINT fk_comment_reaction_counts_comment FOREIGN KEY (comment_id) REFERENCES comments (id),
+  CONSTRAINT fk_comment_reaction_counts_reaction FOREIGN KEY (reaction_id) REFERENCES reactions (id)
+);
+
+CREATE INDEX fk_comment_reaction_counts_reaction ON comment_reaction_counts (reaction_id);
+
+UPDATE users SET
+  post_count = (SELECT COUNT(*) FROM posts WHERE posts.user_id = users.id AND posts.deleted_at IS NULL),
+  comment_count = (SELECT COUNT(*) FROM comments WHERE comments.user_id = users.id AND comments.deleted_at IS NULL);
+
+UPDATE posts SET
+  comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL),
+  reaction_count = (SELECT COUNT(*) FROM post_reactions WHERE post_reactions.post_id = posts.id);
+
+INSERT INTO post_reaction_counts (post_id, reaction_id, count)
+  SELECT post_id, reaction_id, COUNT(*) FROM post_reactions GROUP BY post_id, reaction_id;
+
+INSERT INTO comment_reaction_counts (comment_id, reaction_id, count)
+  SELECT comment_id, reaction_id, COUNT(*) FROM comment_reactions GROUP BY comment_id, reaction_id;
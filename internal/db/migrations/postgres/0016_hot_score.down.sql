@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS posts_updated_at ON posts;
+
+CREATE TRIGGER posts_updated_at BEFORE UPDATE ON posts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at('search_vector', 'comment_count', 'reaction_count');
+
+DROP INDEX IF EXISTS idx_posts_hot_score;
+
+ALTER TABLE posts
+  DROP COLUMN hot_score;
@@ -0,0 +1,9 @@
+ALTER TABLE posts
+  ADD COLUMN hot_score double precision NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_posts_hot_score ON posts (hot_score, id);
+
+DROP TRIGGER IF EXISTS posts_updated_at ON posts;
+
+CREATE TRIGGER posts_updated_at BEFORE UPDATE ON posts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at('search_vector', 'comment_count', 'reaction_count', 'hot_score');
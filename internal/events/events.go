@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	historySize     = 1024
+	subscriberQueue = 64
+)
+
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  json.RawMessage
+}
+
+type Subscription struct {
+	hub    *Hub
+	topics map[string]bool
+	events chan Event
+	once   sync.Once
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		history:     make([]Event, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Publish(topic, kind string, data any) (err error) {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Topic: topic, Type: kind, Data: payload}
+
+	if len(h.history) == historySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:historySize-1]
+	}
+
+	h.history = append(h.history, event)
+
+	for sub := range h.subscribers {
+		if !sub.topics[topic] {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			h.closeLocked(sub)
+		}
+	}
+
+	return
+}
+
+// Subscribe registers interest in topics. If lastEventID is non-zero, events
+// published after it are replayed; complete is false when some of them are no
+// longer held in history and the client should refetch instead.
+func (h *Hub) Subscribe(topics []string, lastEventID uint64) (sub *Subscription, missed []Event, complete bool) {
+	sub = &Subscription{
+		hub:    h,
+		topics: make(map[string]bool, len(topics)),
+		events: make(chan Event, subscriberQueue),
+	}
+
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers[sub] = struct{}{}
+	complete = true
+
+	if lastEventID == 0 {
+		return
+	}
+
+	if lastEventID > h.lastID || (len(h.history) > 0 && h.history[0].ID > lastEventID+1) {
+		complete = false
+		return
+	}
+
+	for _, event := range h.history {
+		if event.ID > lastEventID && sub.topics[event.Topic] {
+			missed = append(missed, event)
+		}
+	}
+
+	return
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closeLocked(sub)
+}
+
+func (h *Hub) closeLocked(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	})
+}
+
+var defaultHub = NewHub()
+
+func Publish(topic, kind string, data any) error {
+	return defaultHub.Publish(topic, kind, data)
+}
+
+func Subscribe(topics []string, lastEventID uint64) (*Subscription, []Event, bool) {
+	return defaultHub.Subscribe(topics, lastEventID)
+}
+
+func PostTopic(postID uint) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+func TagTopic(tagID uint) string {
+	return fmt.Sprintf("tag:%d", tagID)
+}
+
+func NotificationsTopic(userID uint) string {
+	return fmt.Sprintf("notifications:%d", userID)
+}
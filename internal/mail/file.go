@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// FileSender writes each message to an .eml file in Dir, for development.
+type FileSender struct {
+	Dir string
+}
+
+func (s FileSender) Send(from string, msg Message) (err error) {
+	dir := s.Dir
+
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+
+	if err != nil {
+		return
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+
+	return os.WriteFile(path.Join(dir, name), Render(from, msg), 0o644)
+}
+
+// LogSender writes messages to the standard logger instead of sending them.
+type LogSender struct{}
+
+func (LogSender) Send(from string, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(from string, msg Message) error
+}
+
+var (
+	sender Sender = LogSender{}
+	from          = "forum <noreply@localhost>"
+)
+
+// Configure selects the sender from the environment. MAIL_DRIVER is one of
+// smtp, file or log (the default).
+func Configure() (err error) {
+	if os.Getenv("MAIL_FROM") != "" {
+		from = os.Getenv("MAIL_FROM")
+	}
+
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		sender = SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	case "file":
+		sender = FileSender{Dir: os.Getenv("MAIL_DIR")}
+	case "", "log":
+		sender = LogSender{}
+	default:
+		err = fmt.Errorf("unknown MAIL_DRIVER %q, expected smtp, file or log", os.Getenv("MAIL_DRIVER"))
+	}
+
+	return
+}
+
+func SetSender(s Sender) {
+	sender = s
+}
+
+func Send(msg Message) error {
+	return sender.Send(from, msg)
+}
+
+// Render formats a message as an RFC 5322 plain text email.
+func Render(from string, msg Message) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (s SMTPSender) Send(from string, msg Message) (err error) {
+	sender, err := mail.ParseAddress(from)
+
+	if err != nil {
+		return
+	}
+
+	recipient, err := mail.ParseAddress(msg.To)
+
+	if err != nil {
+		return
+	}
+
+	port := s.Port
+
+	if port == "" {
+		port = "25"
+	}
+
+	var auth smtp.Auth
+
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(s.Host, port), auth, sender.Address, []string{recipient.Address}, Render(from, msg))
+}
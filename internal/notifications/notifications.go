@@ -0,0 +1,193 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	TypePostComment     = "post_comment"
+	TypeCommentReply    = "comment_reply"
+	TypeThreadComment   = "thread_comment"
+	TypePostReaction    = "post_reaction"
+	TypeCommentReaction = "comment_reaction"
+)
+
+var PreferenceKeys = map[string]string{
+	TypePostComment:     "notifyPostComment",
+	TypeCommentReply:    "notifyCommentReply",
+	TypeThreadComment:   "notifyThreadComment",
+	TypePostReaction:    "notifyPostReaction",
+	TypeCommentReaction: "notifyCommentReaction",
+}
+
+// enabledFor returns those of userIDs who have not turned off notifications
+// of kind.
+func enabledFor(ctx context.Context, userIDs []int64, kind string) (enabled []int64, err error) {
+	prefs, err := store.FromContext(ctx).Users.GetUsersPreferences(ctx, userIDs)
+
+	if err != nil {
+		return
+	}
+
+	for _, userID := range userIDs {
+		values, _ := prefs[userID].(map[string]any)
+		on, ok := values[PreferenceKeys[kind]].(bool)
+
+		if !ok || on {
+			enabled = append(enabled, userID)
+		}
+	}
+
+	return
+}
+
+// notify records a notification for each of userIDs in one batch, then pushes
+// them to the users' streams in the background so that the request does not
+// wait on it.
+func notify(ctx context.Context, userIDs []int64, actorID int64, kind string, postID int64, commentID *int64, coalesce bool) (err error) {
+	userIDs = slices.DeleteFunc(slices.Clone(userIDs), func(userID int64) bool {
+		return userID == actorID
+	})
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	userIDs, err = enabledFor(ctx, userIDs, kind)
+
+	if err != nil || len(userIDs) == 0 {
+		return
+	}
+
+	err = store.FromContext(ctx).Notifications.CreateNotifications(ctx, userIDs, actorID, kind, postID, commentID, coalesce)
+
+	if err != nil {
+		return
+	}
+
+	go push(context.WithoutCancel(ctx), userIDs, kind, postID)
+
+	return
+}
+
+// push publishes new notifications with the users' unread counts. It runs
+// after the response may have been sent, so errors are only logged.
+func push(ctx context.Context, userIDs []int64, kind string, postID int64) {
+	unread, err := store.FromContext(ctx).Notifications.GetUnreadNotificationCounts(ctx, userIDs)
+
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		err = events.Publish(events.NotificationsTopic(uint(userID)), "notification", map[string]any{
+			"type":   kind,
+			"postId": postID,
+			"unread": unread[userID],
+		})
+
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func CommentCreated(ctx context.Context, actorID int64, post api.Post, comment api.Comment, parent *api.Comment) (err error) {
+	commentID := int64(comment.ID)
+	notified := map[int64]bool{actorID: true}
+
+	if parent != nil && !parent.Author.Deleted {
+		authorID := int64(parent.Author.ID)
+		notified[authorID] = true
+
+		err = notify(ctx, []int64{authorID}, actorID, TypeCommentReply, int64(post.ID), &commentID, false)
+
+		if err != nil {
+			return
+		}
+	}
+
+	if !notified[int64(post.Author.ID)] && !post.Author.Deleted {
+		authorID := int64(post.Author.ID)
+		notified[authorID] = true
+
+		err = notify(ctx, []int64{authorID}, actorID, TypePostComment, int64(post.ID), &commentID, false)
+
+		if err != nil {
+			return
+		}
+	}
+
+	participants, err := store.FromContext(ctx).Posts.GetPostParticipants(ctx, int64(post.ID))
+
+	if err != nil {
+		return
+	}
+
+	participants = slices.DeleteFunc(participants, func(userID int64) bool {
+		return notified[userID]
+	})
+
+	return notify(ctx, participants, actorID, TypeThreadComment, int64(post.ID), nil, true)
+}
+
+func PostReacted(ctx context.Context, actorID int64, post api.Post) (err error) {
+	if post.Author.Deleted {
+		return
+	}
+
+	return notify(ctx, []int64{int64(post.Author.ID)}, actorID, TypePostReaction, int64(post.ID), nil, true)
+}
+
+func CommentReacted(ctx context.Context, actorID int64, comment api.Comment) (err error) {
+	if comment.Author.Deleted {
+		return
+	}
+
+	commentID := int64(comment.ID)
+
+	return notify(ctx, []int64{int64(comment.Author.ID)}, actorID, TypeCommentReaction, int64(comment.PostID), &commentID, true)
+}
+
+func Describe(notification api.Notification) string {
+	actor := notification.Actor.Username
+
+	if notification.Actor.Deleted {
+		actor = "Someone"
+	}
+
+	switch notification.Type {
+	case TypePostComment:
+		return fmt.Sprintf("%s commented on your post", actor)
+	case TypeCommentReply:
+		return fmt.Sprintf("%s replied to your comment", actor)
+	case TypeThreadComment:
+		if notification.ActorCount > 1 {
+			return fmt.Sprintf("%d people commented on a post you joined", notification.ActorCount)
+		}
+
+		return fmt.Sprintf("%s commented on a post you joined", actor)
+	case TypePostReaction:
+		if notification.ActorCount > 1 {
+			return fmt.Sprintf("%d people reacted to your post", notification.ActorCount)
+		}
+
+		return fmt.Sprintf("%s reacted to your post", actor)
+	case TypeCommentReaction:
+		if notification.ActorCount > 1 {
+			return fmt.Sprintf("%d people reacted to your comment", notification.ActorCount)
+		}
+
+		return fmt.Sprintf("%s reacted to your comment", actor)
+	}
+
+	return ""
+}
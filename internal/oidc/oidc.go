@@ -0,0 +1,378 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+var (
+	ErrNotConfigured = errors.New("oidc provider is not configured")
+	ErrInvalidToken  = errors.New("invalid id token")
+)
+
+var httpClient = &http.Client{Timeout: time.Second * 10}
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type Provider struct {
+	Config
+
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+
+	keysMu sync.Mutex
+	keys   jwk.Set
+}
+
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+var (
+	config     *Config
+	provider   *Provider
+	providerMu sync.Mutex
+)
+
+// Configure reads the provider configuration from the environment. OIDC login
+// is disabled unless OIDC_ISSUER and OIDC_CLIENT_ID are set.
+func Configure() {
+	if os.Getenv("OIDC_ISSUER") == "" || os.Getenv("OIDC_CLIENT_ID") == "" {
+		config = nil
+		return
+	}
+
+	config = &Config{
+		Name:         os.Getenv("OIDC_PROVIDER_NAME"),
+		Issuer:       strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+
+	if config.Name == "" {
+		config.Name = "SSO"
+	}
+
+	if config.RedirectURL == "" {
+		config.RedirectURL = utils.GetAppURL() + "/api/auth/oidc/callback"
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+}
+
+func Enabled() bool {
+	return config != nil
+}
+
+func Name() string {
+	if config == nil {
+		return ""
+	}
+
+	return config.Name
+}
+
+// GetProvider returns the configured provider, running discovery on first use
+// so that the identity provider need not be reachable when the server starts.
+func GetProvider(ctx context.Context) (p *Provider, err error) {
+	if config == nil {
+		return nil, ErrNotConfigured
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+
+	if provider != nil {
+		return provider, nil
+	}
+
+	p, err = Discover(ctx, *config)
+
+	if err != nil {
+		return
+	}
+
+	provider = p
+
+	return
+}
+
+func getJSON(ctx context.Context, u string, v any) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+
+	if err != nil {
+		return
+	}
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func Discover(ctx context.Context, c Config) (p *Provider, err error) {
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	wellKnown := c.Issuer + "/.well-known/openid-configuration"
+
+	err = getJSON(ctx, wellKnown, &metadata)
+
+	if err != nil {
+		return
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != c.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", metadata.Issuer, c.Issuer)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document at %s is incomplete", wellKnown)
+	}
+
+	// ID tokens carry the issuer exactly as the provider spells it.
+	c.Issuer = metadata.Issuer
+
+	p = &Provider{
+		Config:                c,
+		AuthorizationEndpoint: metadata.AuthorizationEndpoint,
+		TokenEndpoint:         metadata.TokenEndpoint,
+		JWKSURI:               metadata.JWKSURI,
+	}
+
+	return
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	_, err := rand.Read(buf)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewAuthRequest generates the state, nonce and PKCE verifier for a new
+// authorization request.
+func NewAuthRequest() (state, nonce, verifier string, err error) {
+	state, err = randomString(24)
+
+	if err == nil {
+		nonce, err = randomString(24)
+	}
+
+	if err == nil {
+		verifier, err = randomString(32)
+	}
+
+	return
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (idToken string, err error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s %s", res.Status, body.Error, body.ErrorDescription)
+	}
+
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint did not return an id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+func (p *Provider) getKeys(ctx context.Context, refresh bool) (keys jwk.Set, err error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if p.keys != nil && !refresh {
+		return p.keys, nil
+	}
+
+	keys, err = jwk.Fetch(ctx, p.JWKSURI, jwk.WithHTTPClient(httpClient))
+
+	if err != nil {
+		return
+	}
+
+	p.keys = keys
+
+	return
+}
+
+func (p *Provider) parseIDToken(ctx context.Context, raw string, refresh bool) (token jwt.Token, err error) {
+	keys, err := p.getKeys(ctx, refresh)
+
+	if err != nil {
+		return
+	}
+
+	return jwt.Parse([]byte(raw),
+		jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithAcceptableSkew(time.Minute),
+	)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an
+// ID token and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (claims Claims, err error) {
+	token, err := p.parseIDToken(ctx, raw, false)
+
+	if err != nil {
+		// The provider may have rotated its signing keys.
+		token, err = p.parseIDToken(ctx, raw, true)
+	}
+
+	if err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if tokenNonce, _ := token.Get("nonce"); tokenNonce != nonce {
+		return claims, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	if token.Subject() == "" {
+		return claims, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	claims.Issuer = p.Issuer
+	claims.Subject = token.Subject()
+	claims.Email, _ = getString(token, "email")
+	claims.PreferredUsername, _ = getString(token, "preferred_username")
+	claims.Name, _ = getString(token, "name")
+
+	if verified, ok := token.Get("email_verified"); ok {
+		claims.EmailVerified = verified == true || verified == "true"
+	}
+
+	return
+}
+
+func getString(token jwt.Token, key string) (string, bool) {
+	value, ok := token.Get(key)
+
+	if !ok {
+		return "", false
+	}
+
+	s, ok := value.(string)
+
+	return s, ok
+}
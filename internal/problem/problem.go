@@ -0,0 +1,139 @@
+// Package problem writes API errors as RFC 7807 problem details, with a
+// machine-readable code, the fields that failed validation if any, and the ID
+// of the request so that it can be found in the logs.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Codes of problems that are not simply named after their status.
+const (
+	CodeInvalid       = "invalid_request"
+	CodeMalformedBody = "malformed_body"
+	CodeTimeout       = "timeout"
+	CodeUsernameTaken = "username_taken"
+	CodeEmailTaken    = "email_taken"
+	CodeUserSuspended = "user_suspended"
+)
+
+// Codes of fields that failed validation.
+const (
+	FieldRequired = "required"
+	FieldTooShort = "too_short"
+	FieldTooLong  = "too_long"
+	FieldInvalid  = "invalid"
+	FieldUnknown  = "unknown"
+)
+
+// FieldError describes why the value of a field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is the body of an error response. Fields left empty are filled in
+// from Status by Write. Extensions are added as members alongside the others.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	Extensions map[string]any `json:"-"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type alias Problem
+	raw, err := json.Marshal(alias(p))
+
+	if err != nil || len(p.Extensions) == 0 {
+		return raw, err
+	}
+
+	members := make(map[string]any, len(p.Extensions))
+
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	err = json.Unmarshal(raw, &members)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(members)
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+
+	return http.StatusText(p.Status)
+}
+
+// Invalid is the problem with a request whose fields failed validation.
+func Invalid(errors ...FieldError) *Problem {
+	return &Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeInvalid,
+		Detail: "One or more fields are invalid.",
+		Errors: errors,
+	}
+}
+
+// Write writes p as the response to r.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	if p.Code == "" {
+		p.Code = statusCode(p.Status)
+	}
+
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	if p.RequestID == "" {
+		p.RequestID = middleware.GetReqID(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes the problem of a status with nothing more to say about it, in
+// place of http.Error.
+func Error(w http.ResponseWriter, r *http.Request, status int) {
+	Write(w, r, &Problem{Status: status})
+}
+
+// statusCode names a status in snake case, as in not_found.
+func statusCode(status int) string {
+	text := http.StatusText(status)
+
+	if text == "" {
+		return "error"
+	}
+
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
@@ -0,0 +1,79 @@
+// Package ranking keeps the hot scores of posts up to date. A post's score is
+// its reactions and comments divided by a power of its age, so that it sinks
+// as it gets older unless it keeps getting attention. The higher the gravity,
+// the faster posts sink.
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+var (
+	gravity  = 1.8
+	interval = 5 * time.Minute
+	// Posts older than maxAge are scored 0 rather than recomputed, as their
+	// scores are negligible by then.
+	maxAge = 30 * 24 * time.Hour
+)
+
+// Configure reads overrides for the defaults from the environment:
+// HOT_GRAVITY, HOT_INTERVAL, how often scores are recomputed, and HOT_MAX_AGE.
+func Configure() (err error) {
+	if value := os.Getenv("HOT_GRAVITY"); value != "" {
+		gravity, err = strconv.ParseFloat(value, 64)
+
+		if err != nil || gravity <= 0 {
+			return fmt.Errorf("HOT_GRAVITY: invalid gravity %q", value)
+		}
+	}
+
+	if value := os.Getenv("HOT_INTERVAL"); value != "" {
+		interval, err = time.ParseDuration(value)
+
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("HOT_INTERVAL: invalid interval %q", value)
+		}
+	}
+
+	if value := os.Getenv("HOT_MAX_AGE"); value != "" {
+		maxAge, err = time.ParseDuration(value)
+
+		if err != nil || maxAge <= 0 {
+			return fmt.Errorf("HOT_MAX_AGE: invalid age %q", value)
+		}
+	}
+
+	return
+}
+
+// Update recomputes the hot scores of posts once.
+func Update(ctx context.Context, posts store.PostStore) error {
+	return posts.UpdateHotScores(ctx, gravity, time.Now().Add(-maxAge))
+}
+
+// Run recomputes the hot scores of posts every interval until ctx is done.
+func Run(ctx context.Context, posts store.PostStore) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := Update(ctx, posts)
+
+		if err != nil {
+			log.Println("Failed to update hot scores:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	datastore "github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	GroupAPI       = "api"
+	GroupPosts     = "posts"
+	GroupComments  = "comments"
+	GroupReactions = "reactions"
+	GroupReports   = "reports"
+)
+
+var ErrInvalidRate = errors.New("invalid rate limit")
+
+// Rate allows Requests requests per Period, in bursts of up to Requests.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRate parses a rate such as "10/1m".
+func ParseRate(value string) (rate Rate, err error) {
+	requests, period, ok := strings.Cut(value, "/")
+
+	if !ok {
+		return rate, ErrInvalidRate
+	}
+
+	rate.Requests, err = strconv.Atoi(requests)
+
+	if err != nil || rate.Requests <= 0 {
+		return rate, ErrInvalidRate
+	}
+
+	rate.Period, err = time.ParseDuration(period)
+
+	if err != nil || rate.Period <= 0 {
+		return rate, ErrInvalidRate
+	}
+
+	return
+}
+
+type limits struct {
+	Default    Rate
+	NewAccount Rate
+}
+
+var groups = map[string]*limits{
+	GroupAPI: {
+		Default:    Rate{300, time.Minute},
+		NewAccount: Rate{300, time.Minute},
+	},
+	GroupPosts: {
+		Default:    Rate{10, time.Hour},
+		NewAccount: Rate{3, time.Hour},
+	},
+	GroupComments: {
+		Default:    Rate{30, 10 * time.Minute},
+		NewAccount: Rate{5, 10 * time.Minute},
+	},
+	GroupReactions: {
+		Default:    Rate{60, time.Minute},
+		NewAccount: Rate{20, time.Minute},
+	},
+	GroupReports: {
+		Default:    Rate{10, time.Hour},
+		NewAccount: Rate{3, time.Hour},
+	},
+}
+
+// Accounts younger than this are given the stricter NewAccount limits.
+var newAccountAge = 24 * time.Hour
+
+// Configure reads overrides for the default limits from the environment:
+// RATE_LIMIT_<GROUP> and RATE_LIMIT_<GROUP>_NEW for each group, and
+// RATE_LIMIT_NEW_ACCOUNT_AGE.
+func Configure() (err error) {
+	for group, limits := range groups {
+		name := "RATE_LIMIT_" + strings.ToUpper(group)
+
+		if value := os.Getenv(name); value != "" {
+			limits.Default, err = ParseRate(value)
+
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		if value := os.Getenv(name + "_NEW"); value != "" {
+			limits.NewAccount, err = ParseRate(value)
+
+			if err != nil {
+				return fmt.Errorf("%s_NEW: %w", name, err)
+			}
+		}
+	}
+
+	if value := os.Getenv("RATE_LIMIT_NEW_ACCOUNT_AGE"); value != "" {
+		newAccountAge, err = time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_NEW_ACCOUNT_AGE: %w", err)
+		}
+	}
+
+	return
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Account creation times and exemptions are needed on every signed in request,
+// so they are cached rather than looked up each time. A change of role takes
+// up to accountCacheTTL to change whether a user is exempt.
+const accountCacheTTL = 10 * time.Minute
+
+type cachedAccount struct {
+	createdAt time.Time
+	exempt    bool
+	expires   time.Time
+}
+
+var (
+	accountCacheMu    sync.Mutex
+	accountCache      = make(map[uint]cachedAccount)
+	accountCacheSwept time.Time
+)
+
+func getAccount(r *http.Request, userID uint) (account cachedAccount, err error) {
+	t := time.Now()
+
+	accountCacheMu.Lock()
+	account, ok := accountCache[userID]
+	accountCacheMu.Unlock()
+
+	if ok && t.Before(account.expires) {
+		return account, nil
+	}
+
+	account.createdAt, err = datastore.FromContext(r.Context()).Users.GetUserCreatedAt(r.Context(), int64(userID))
+
+	if err != nil {
+		return
+	}
+
+	account.exempt = auth.HasCapability(r, auth.RateLimitExempt)
+	account.expires = t.Add(accountCacheTTL)
+
+	accountCacheMu.Lock()
+	defer accountCacheMu.Unlock()
+
+	if t.Sub(accountCacheSwept) >= accountCacheTTL {
+		for id, account := range accountCache {
+			if !t.Before(account.expires) {
+				delete(accountCache, id)
+			}
+		}
+
+		accountCacheSwept = t
+	}
+
+	accountCache[userID] = account
+
+	return
+}
+
+// requestRate returns the key and rate that a request counts against within a
+// group. Signed in users are limited per account and others per address.
+// Users with the RateLimitExempt capability are not limited.
+func requestRate(r *http.Request, limits *limits) (key string, rate Rate, exempt bool, err error) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		return "ip:" + auth.ClientIP(r), limits.Default, false, nil
+	}
+
+	account, err := getAccount(r, userID)
+
+	if err != nil || account.exempt {
+		return "", rate, account.exempt, err
+	}
+
+	rate = limits.Default
+
+	if time.Since(account.createdAt) < newAccountAge {
+		rate = limits.NewAccount
+	}
+
+	return fmt.Sprintf("user:%d", userID), rate, false, nil
+}
+
+// serverError logs err with the request ID, like the routes do, and responds
+// with 500 Internal Server Error.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("[%s] %s %s: %v\n", middleware.GetReqID(r.Context()), r.Method, r.URL.Path, err)
+	problem.Error(w, r, http.StatusInternalServerError)
+}
+
+// Limit applies the limits of group to requests, using a token bucket per
+// user or address. Responses carry RateLimit-* headers, and requests over the
+// limit are rejected with 429 Too Many Requests and a Retry-After header.
+func Limit(group string) func(http.Handler) http.Handler {
+	limits, ok := groups[group]
+
+	if !ok {
+		panic("ratelimit: unknown group " + group)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, rate, exempt, err := requestRate(r, limits)
+
+			if err != nil {
+				serverError(w, r, err)
+				return
+			}
+
+			if exempt {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bucket, err := store.Take("bucket:"+group+":"+key, time.Now(), rate.Requests, rate.Period/time.Duration(rate.Requests))
+
+			if err != nil {
+				serverError(w, r, err)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(rate.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(bucket.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(bucket.Reset))
+
+			if !bucket.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(bucket.Retry))
+				problem.Error(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package ratelimit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+)
+
+const (
+	KindIP       = "ip"
+	KindUsername = "username"
+	KindUser     = "user"
+)
+
+const (
+	baseDelay = time.Second
+	maxDelay  = 30 * time.Second
+
+	usernameLookupWindow = time.Minute
+	usernameLookupLimit  = 30
+
+	twoFactorChallengeAttempts = 3
+)
+
+var (
+	ErrUnknownKind        = errors.New("unknown lockout kind")
+	ErrChallengeExhausted = errors.New("too many attempts at two-factor challenge")
+)
+
+// policy describes how failed sign in attempts against one key are throttled.
+// Once DelayAfter failures are recorded within Window, each further attempt
+// must wait twice as long as the last, and LockAfter failures lock the key
+// for LockFor.
+type policy struct {
+	Window     time.Duration
+	DelayAfter int
+	LockAfter  int
+	LockFor    time.Duration
+}
+
+var policies = map[string]policy{
+	KindUsername: {Window: 15 * time.Minute, DelayAfter: 3, LockAfter: 5, LockFor: 15 * time.Minute},
+	// Addresses can be shared by many users, so are given more leeway.
+	KindIP: {Window: 15 * time.Minute, DelayAfter: 10, LockAfter: 30, LockFor: 15 * time.Minute},
+}
+
+// Second factor codes are short, so wrong ones are tracked separately from
+// passwords and lock a user out sooner.
+var twoFactorPolicies = map[string]policy{
+	KindUser: {Window: 15 * time.Minute, DelayAfter: 3, LockAfter: 10, LockFor: 15 * time.Minute},
+	KindIP:   {Window: 15 * time.Minute, DelayAfter: 10, LockAfter: 30, LockFor: 15 * time.Minute},
+}
+
+func loginKey(kind, value string) string {
+	return "login:" + kind + ":" + value
+}
+
+func loginKeys(ip, username string) map[string]string {
+	keys := map[string]string{KindIP: loginKey(KindIP, ip)}
+
+	if username != "" {
+		keys[KindUsername] = loginKey(KindUsername, strings.ToLower(username))
+	}
+
+	return keys
+}
+
+func twoFactorKeys(ip string, userID uint) map[string]string {
+	return map[string]string{
+		KindIP:   "twoFactor:" + KindIP + ":" + ip,
+		KindUser: "twoFactor:" + KindUser + ":" + strconv.FormatUint(uint64(userID), 10),
+	}
+}
+
+func twoFactorChallengeKey(challenge string) string {
+	return "twoFactor:challenge:" + challenge
+}
+
+func (p policy) wait(key string, t time.Time) (time.Duration, error) {
+	until, locked, err := store.LockedUntil(key, t)
+
+	if err != nil || locked {
+		return until.Sub(t), err
+	}
+
+	window, err := store.Get(key, t, p.Window)
+
+	if err != nil || window.Count < p.DelayAfter {
+		return 0, err
+	}
+
+	delay := maxDelay
+
+	if shift := window.Count - p.DelayAfter; shift < 8 {
+		delay = min(baseDelay<<shift, maxDelay)
+	}
+
+	return max(window.Last.Add(delay).Sub(t), 0), nil
+}
+
+func (p policy) fail(key string, t time.Time) error {
+	window, err := store.Add(key, t, p.Window)
+
+	if err != nil || window.Count < p.LockAfter {
+		return err
+	}
+
+	return store.Lock(key, t.Add(p.LockFor))
+}
+
+// waitAll returns the longest wait across keys, each throttled by the policy
+// for its kind.
+func waitAll(policies map[string]policy, keys map[string]string, t time.Time) (wait time.Duration, err error) {
+	for kind, key := range keys {
+		keyWait, err := policies[kind].wait(key, t)
+
+		if err != nil {
+			return 0, err
+		}
+
+		wait = max(wait, keyWait)
+	}
+
+	return
+}
+
+func failAll(policies map[string]policy, keys map[string]string, t time.Time) error {
+	for kind, key := range keys {
+		err := policies[kind].fail(key, t)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckLogin returns how long a client at ip must wait before it may attempt
+// to sign in as username, or zero if it may try now.
+func CheckLogin(ip, username string) (time.Duration, error) {
+	return waitAll(policies, loginKeys(ip, username), time.Now())
+}
+
+// LoginFailed records a sign in attempt with the wrong password.
+func LoginFailed(ip, username string) error {
+	return failAll(policies, loginKeys(ip, username), time.Now())
+}
+
+// LoginSucceeded forgets the failures against username. Failures from the
+// address are kept, so that signing in to one account does not allow
+// guessing the passwords of others.
+func LoginSucceeded(username string) error {
+	return store.Clear(loginKey(KindUsername, strings.ToLower(username)))
+}
+
+// CheckTwoFactor returns how long a client at ip must wait before it may
+// submit another second factor code for userID, or zero if it may try now. It
+// returns ErrChallengeExhausted if challenge has already seen too many wrong
+// codes.
+func CheckTwoFactor(ip string, userID uint, challenge string) (time.Duration, error) {
+	t := time.Now()
+
+	window, err := store.Get(twoFactorChallengeKey(challenge), t, twoFactorPolicies[KindUser].Window)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if window.Count >= twoFactorChallengeAttempts {
+		return 0, ErrChallengeExhausted
+	}
+
+	return waitAll(twoFactorPolicies, twoFactorKeys(ip, userID), t)
+}
+
+// TwoFactorFailed records a wrong second factor code submitted for challenge.
+// It returns ErrChallengeExhausted once the challenge may not be used again,
+// so that the password must be entered afresh.
+func TwoFactorFailed(ip string, userID uint, challenge string) error {
+	t := time.Now()
+
+	err := failAll(twoFactorPolicies, twoFactorKeys(ip, userID), t)
+
+	if err != nil {
+		return err
+	}
+
+	window, err := store.Add(twoFactorChallengeKey(challenge), t, twoFactorPolicies[KindUser].Window)
+
+	if err == nil && window.Count >= twoFactorChallengeAttempts {
+		err = ErrChallengeExhausted
+	}
+
+	return err
+}
+
+// TwoFactorSucceeded forgets the wrong second factor codes entered for userID.
+func TwoFactorSucceeded(userID uint) error {
+	return store.Clear(twoFactorKeys("", userID)[KindUser])
+}
+
+// CheckUsernameLookup records a username availability lookup from ip, and
+// returns how long it must wait if it has made too many.
+func CheckUsernameLookup(ip string) (time.Duration, error) {
+	key := "checkUsername:" + KindIP + ":" + ip
+	t := time.Now()
+
+	window, err := store.Get(key, t, usernameLookupWindow)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if window.Count >= usernameLookupLimit {
+		return window.First.Add(usernameLookupWindow).Sub(t), nil
+	}
+
+	_, err = store.Add(key, t, usernameLookupWindow)
+
+	return 0, err
+}
+
+// Lockouts returns the addresses and usernames currently locked out of
+// signing in.
+func Lockouts() ([]api.Lockout, error) {
+	t := time.Now()
+
+	locks, err := store.Locks(t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := make([]api.Lockout, 0)
+
+	for _, lock := range locks {
+		kind, value, ok := strings.Cut(strings.TrimPrefix(lock.Key, "login:"), ":")
+
+		if !ok || !strings.HasPrefix(lock.Key, "login:") {
+			continue
+		}
+
+		window, err := store.Get(lock.Key, t, policies[kind].Window)
+
+		if err != nil {
+			return nil, err
+		}
+
+		lockouts = append(lockouts, api.Lockout{
+			Kind:        kind,
+			Value:       value,
+			Failures:    window.Count,
+			LockedUntil: lock.Until,
+		})
+	}
+
+	return lockouts, nil
+}
+
+// ClearLockout lifts the lockout on an address or username and forgets its
+// failures.
+func ClearLockout(kind, value string) error {
+	if _, ok := policies[kind]; !ok {
+		return ErrUnknownKind
+	}
+
+	if kind == KindUsername {
+		value = strings.ToLower(value)
+	}
+
+	return store.Clear(loginKey(kind, value))
+}
@@ -0,0 +1,254 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxAttempts bounds the attempts kept per key. Policies only need to count
+// far fewer, so older attempts beyond this can be forgotten.
+const maxAttempts = 256
+
+const sweepInterval = time.Minute
+
+// Window summarises the attempts recorded against a key within a window.
+type Window struct {
+	Count int
+	First time.Time
+	Last  time.Time
+}
+
+// Bucket is the result of taking a token from a token bucket.
+type Bucket struct {
+	Allowed   bool
+	Remaining int
+	// Retry is how long until a token is available, if none was taken.
+	Retry time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type Lock struct {
+	Key   string
+	Until time.Time
+}
+
+// Store records attempts in sliding windows and holds lockouts, keyed by
+// arbitrary strings. Implementations must be safe for concurrent use, so that
+// a shared backend can replace MemoryStore when running several servers.
+type Store interface {
+	// Add records an attempt against key at t and returns the attempts within
+	// the window ending at t, including this one.
+	Add(key string, t time.Time, window time.Duration) (Window, error)
+	// Get returns the attempts against key within the window ending at t.
+	Get(key string, t time.Time, window time.Duration) (Window, error)
+	// Take removes a token from the bucket for key, which holds up to burst
+	// tokens and gains one every interval.
+	Take(key string, t time.Time, burst int, interval time.Duration) (Bucket, error)
+	// Lock prevents attempts against key until the given time.
+	Lock(key string, until time.Time) error
+	// LockedUntil returns the end of the lockout on key if it is active at t.
+	LockedUntil(key string, t time.Time) (until time.Time, locked bool, err error)
+	// Locks returns the lockouts active at t.
+	Locks(t time.Time) ([]Lock, error)
+	// Clear forgets the attempts and lockout on key.
+	Clear(key string) error
+}
+
+type memoryEntry struct {
+	attempts    []time.Time
+	window      time.Duration
+	lockedUntil time.Time
+	tokens      float64
+	refilledAt  time.Time
+	fullAt      time.Time
+}
+
+func (e *memoryEntry) prune(t time.Time, window time.Duration) {
+	start := 0
+
+	for start < len(e.attempts) && !e.attempts[start].After(t.Add(-window)) {
+		start++
+	}
+
+	e.attempts = e.attempts[start:]
+}
+
+func (e *memoryEntry) summary() (w Window) {
+	w.Count = len(e.attempts)
+
+	if w.Count > 0 {
+		w.First = e.attempts[0]
+		w.Last = e.attempts[w.Count-1]
+	}
+
+	return
+}
+
+// MemoryStore is a Store local to this process.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Add(key string, t time.Time, window time.Duration) (Window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked(t)
+
+	entry := s.entryLocked(key)
+	entry.prune(t, window)
+
+	if len(entry.attempts) == maxAttempts {
+		entry.attempts = entry.attempts[1:]
+	}
+
+	entry.attempts = append(entry.attempts, t)
+
+	if window > entry.window {
+		entry.window = window
+	}
+
+	return entry.summary(), nil
+}
+
+func (s *MemoryStore) Get(key string, t time.Time, window time.Duration) (Window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+
+	if !ok {
+		return Window{}, nil
+	}
+
+	entry.prune(t, window)
+
+	return entry.summary(), nil
+}
+
+func (s *MemoryStore) Take(key string, t time.Time, burst int, interval time.Duration) (bucket Bucket, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked(t)
+
+	entry := s.entryLocked(key)
+	tokens := float64(burst)
+
+	if !entry.refilledAt.IsZero() {
+		tokens = min(tokens, entry.tokens+float64(t.Sub(entry.refilledAt))/float64(interval))
+	}
+
+	if tokens >= 1 {
+		tokens--
+		bucket.Allowed = true
+	} else {
+		bucket.Retry = time.Duration((1 - tokens) * float64(interval))
+	}
+
+	entry.tokens = tokens
+	entry.refilledAt = t
+
+	bucket.Remaining = int(tokens)
+	bucket.Reset = time.Duration((float64(burst) - tokens) * float64(interval))
+	entry.fullAt = t.Add(bucket.Reset)
+
+	return
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entryLocked(key).lockedUntil = until
+
+	return nil
+}
+
+func (s *MemoryStore) LockedUntil(key string, t time.Time) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+
+	if !ok || !entry.lockedUntil.After(t) {
+		return time.Time{}, false, nil
+	}
+
+	return entry.lockedUntil, true, nil
+}
+
+func (s *MemoryStore) Locks(t time.Time) ([]Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := make([]Lock, 0)
+
+	for key, entry := range s.entries {
+		if entry.lockedUntil.After(t) {
+			locks = append(locks, Lock{Key: key, Until: entry.lockedUntil})
+		}
+	}
+
+	return locks, nil
+}
+
+func (s *MemoryStore) Clear(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+func (s *MemoryStore) entryLocked(key string) *memoryEntry {
+	entry, ok := s.entries[key]
+
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	return entry
+}
+
+// sweepLocked periodically drops entries with no recent attempts, no active
+// lockout and a full bucket, so that memory does not grow with every client
+// seen.
+func (s *MemoryStore) sweepLocked(t time.Time) {
+	if t.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+
+	s.lastSweep = t
+
+	for key, entry := range s.entries {
+		entry.prune(t, entry.window)
+
+		if len(entry.attempts) == 0 && !entry.lockedUntil.After(t) && !entry.fullAt.After(t) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+var store Store = NewMemoryStore()
+
+// SetStore replaces the store used for rate limiting, and forgets the cached
+// accounts.
+func SetStore(s Store) {
+	store = s
+
+	accountCacheMu.Lock()
+	clear(accountCache)
+	accountCacheMu.Unlock()
+}
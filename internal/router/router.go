@@ -10,10 +10,12 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/themintchoco/cvwo/internal/routes"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
-func Setup() chi.Router {
+func Setup(s store.Store) chi.Router {
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
@@ -37,7 +39,7 @@ func Setup() chi.Router {
 
 	r.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(http.Dir(os.Getenv("UPLOADS_DIR")))))
 
-	r.Route("/api", routes.APIRoutes())
+	r.Route("/api", routes.APIRoutes(s))
 
 	return r
 }
@@ -2,69 +2,190 @@ package routes
 
 import (
 	"encoding/json"
+	"log"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/themintchoco/cvwo/internal/api"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	problem.Error(w, r, http.StatusTooManyRequests)
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 func handleLogin(w http.ResponseWriter, r *http.Request) {
-	userID, err := db.AuthenticateUser(r.FormValue("username"), r.FormValue("password"))
+	var req loginRequest
 
-	if err == db.ErrPasswordMismatch {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
+	ip := auth.ClientIP(r)
+	username := req.Username
+
+	wait, err := ratelimit.CheckLogin(ip, username)
+
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		tooManyRequests(w, r, wait)
+		return
+	}
+
+	userID, err := stores(r).Users.AuthenticateUser(r.Context(), username, req.Password)
+
+	if err == store.ErrPasswordMismatch {
+		err = ratelimit.LoginFailed(ip, username)
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	if err == store.ErrUserSuspended {
+		suspension, err := stores(r).Moderation.GetUserActiveSuspension(r.Context(), userID)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusForbidden)
+			return
+		}
+
+		problem.Write(w, r, &problem.Problem{
+			Status:     http.StatusForbidden,
+			Code:       problem.CodeUserSuspended,
+			Detail:     "This account is suspended.",
+			Extensions: map[string]any{"suspension": suspension},
+		})
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	_, twoFactorEnabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if twoFactorEnabled {
+		// Password failures are only forgotten once the second factor is
+		// also correct.
+		err = auth.StartTwoFactorChallenge(w, user.ID)
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]bool{"twoFactorRequired": true})
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	err = ratelimit.LoginSucceeded(username)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	err = auth.SignInUser(w, user.ID)
+	err = auth.SignInUser(w, r, user.ID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(api.Me{ID: user.ID})
 }
 
+var usernamePattern = regexp.MustCompile("^[a-zA-Z0-9_]{3,32}$")
+
+type registerRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+func (req *registerRequest) validate(errs *fieldErrors) {
+	errs.checkUsername("username", req.Username)
+	errs.checkPassword("password", req.Password)
+	req.Email = errs.checkEmail("email", req.Email)
+}
+
 func handleRegister(w http.ResponseWriter, r *http.Request) {
-	if len(r.FormValue("username")) < 3 || len(r.FormValue("username")) > 32 || len(r.FormValue("password")) < 8 {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	var req registerRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	match, err := regexp.MatchString("^[a-zA-Z0-9_]+$", r.FormValue("username"))
+	email := req.Email
+	_, err := stores(r).Users.GetUserIDByEmail(r.Context(), email)
 
-	if err != nil || !match {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	if err == nil {
+		problem.Write(w, r, &problem.Problem{Status: http.StatusConflict, Code: problem.CodeEmailTaken, Detail: "An account with this email address already exists."})
 		return
 	}
 
-	userID, err := db.CreateUser(r.FormValue("username"), r.FormValue("password"), "member")
+	if err != store.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+
+	userID, err := stores(r).Users.CreateUser(r.Context(), req.Username, email, req.Password, "member")
+
+	if err == store.ErrConflict {
+		problem.Write(w, r, &problem.Problem{Status: http.StatusConflict, Code: problem.CodeUsernameTaken, Detail: "This username is taken."})
+		return
+	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	err = auth.SignInUser(w, uint(userID))
+	err = sendVerificationEmail(r.Context(), userID, email)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		log.Println(err)
+	}
+
+	err = auth.SignInUser(w, r, uint(userID))
+
+	if err != nil {
+		serverError(w, r, err)
 		return
 	}
 
@@ -72,16 +193,49 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleLogout(w http.ResponseWriter, r *http.Request) {
-	auth.SignOutUser(w)
+	err := auth.SignOutUser(w, r)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := auth.RefreshSession(w, r)
+
+	if err == http.ErrNoCookie || err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Me{ID: userID})
+}
+
 func handleCheckUsername(w http.ResponseWriter, r *http.Request) {
-	available, err := db.GetUsernameAvailability(r.URL.Query().Get("username"))
+	wait, err := ratelimit.CheckUsernameLookup(auth.ClientIP(r))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		tooManyRequests(w, r, wait)
+		return
+	}
+
+	available, err := stores(r).Users.GetUsernameAvailability(r.Context(), r.URL.Query().Get("username"))
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -91,8 +245,18 @@ func handleCheckUsername(w http.ResponseWriter, r *http.Request) {
 func AuthRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Post("/login", handleLogin)
+		r.Post("/login/twoFactor", handleLoginTwoFactor)
 		r.Post("/register", handleRegister)
 		r.Post("/logout", handleLogout)
+		r.Post("/refresh", handleRefresh)
+		r.Post("/verifyEmail", handleVerifyEmail)
+		r.Post("/forgotPassword", handleForgotPassword)
+		r.Post("/resetPassword", handleResetPassword)
 		r.Get("/checkUsername", handleCheckUsername)
+		r.Get("/oidc", handleGetOIDC)
+		r.Get("/oidc/login", handleOIDCLogin)
+		r.Get("/oidc/callback", handleOIDCCallback)
+		r.Get("/oidc/signup", handleGetOIDCSignup)
+		r.Post("/oidc/signup", handleCompleteOIDCSignup)
 	}
 }
@@ -0,0 +1,92 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterSignsIn(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+
+	id := alice.register("alice")
+
+	var me struct {
+		ID    uint
+		Email string
+	}
+
+	alice.expect(http.StatusOK, "GET", "/api/me/", nil, &me)
+
+	if me.ID != id || me.Email != "alice@example.com" {
+		t.Fatalf("got %+v, want user %d with alice@example.com", me, id)
+	}
+
+	alice.expect(http.StatusNoContent, "POST", "/api/auth/logout", nil, nil)
+	alice.expect(http.StatusUnauthorized, "GET", "/api/me/", nil, nil)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+
+	var p problemBody
+
+	srv.client(t).expect(http.StatusConflict, "POST", "/api/auth/register", map[string]string{
+		"username": "alice",
+		"password": "password1",
+		"email":    "other@example.com",
+	}, &p)
+
+	if p.Code != "username_taken" {
+		t.Errorf("got code %q, want username_taken", p.Code)
+	}
+
+	p = problemBody{}
+
+	srv.client(t).expect(http.StatusBadRequest, "POST", "/api/auth/register", map[string]string{
+		"username": "b",
+		"password": "short",
+		"email":    "bob@example.com",
+	}, &p)
+
+	fields := map[string]string{}
+
+	for _, err := range p.Errors {
+		fields[err.Field] = err.Code
+	}
+
+	if fields["username"] != "too_short" || fields["password"] != "too_short" {
+		t.Errorf("got field errors %v, want username and password too_short", fields)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	srv := newTestServer(t)
+	srv.client(t).register("alice")
+
+	alice := srv.client(t)
+
+	alice.expect(http.StatusUnauthorized, "POST", "/api/auth/login", map[string]string{"username": "alice", "password": "wrong"}, nil)
+	alice.expect(http.StatusUnauthorized, "GET", "/api/me/", nil, nil)
+
+	alice.expect(http.StatusOK, "POST", "/api/auth/login", map[string]string{"username": "alice", "password": "password1"}, nil)
+	alice.expect(http.StatusOK, "GET", "/api/me/", nil, nil)
+}
+
+func TestLoginLockout(t *testing.T) {
+	srv := newTestServer(t)
+	srv.client(t).register("alice")
+
+	alice := srv.client(t)
+	login := map[string]string{"username": "alice", "password": "wrong"}
+
+	// The fourth attempt is delayed after three failures.
+	for i := 0; i < 3; i++ {
+		alice.expect(http.StatusUnauthorized, "POST", "/api/auth/login", login, nil)
+	}
+
+	login["password"] = "password1"
+	alice.expect(http.StatusTooManyRequests, "POST", "/api/auth/login", login, nil)
+}
@@ -1,15 +1,21 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/stephenafamo/bob"
-	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/themintchoco/cvwo/internal/api"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/notifications"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/store"
 	"github.com/themintchoco/cvwo/internal/utils"
 )
 
@@ -17,132 +23,344 @@ func handleGetPostComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	comment, err := db.GetPostComment(commentID)
+	comment, err := stores(r).Comments.GetPostComment(r.Context(), commentID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(comment)
 }
 
+const defaultCommentMaxDepth = 8
+
+func getCommentMaxDepth() uint {
+	maxDepth, err := strconv.ParseUint(os.Getenv("COMMENT_MAX_DEPTH"), 10, 64)
+
+	if err != nil {
+		return defaultCommentMaxDepth
+	}
+
+	return uint(maxDepth)
+}
+
+func getCommentThreads(ctx context.Context, roots []api.Comment, maxDepth uint) (threads []api.Comment, err error) {
+	children := map[uint][]api.Comment{}
+	parentIDs := make([]int64, 0, len(roots))
+
+	for _, root := range roots {
+		parentIDs = append(parentIDs, int64(root.ID))
+	}
+
+	for depth := uint(1); depth <= maxDepth && len(parentIDs) > 0; depth++ {
+		replies, err := store.FromContext(ctx).Comments.GetPostCommentReplies(ctx, parentIDs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parentIDs = parentIDs[:0]
+
+		for _, reply := range replies {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+			parentIDs = append(parentIDs, int64(reply.ID))
+		}
+	}
+
+	threads = attachCommentReplies(roots, children)
+
+	return
+}
+
+func attachCommentReplies(comments []api.Comment, children map[uint][]api.Comment) []api.Comment {
+	threads := make([]api.Comment, 0, len(comments))
+
+	for _, comment := range comments {
+		comment.Replies = attachCommentReplies(children[comment.ID], children)
+
+		if comment.Deleted && len(comment.Replies) == 0 {
+			continue
+		}
+
+		threads = append(threads, comment)
+	}
+
+	return threads
+}
+
+func flattenCommentThreads(threads []api.Comment) []api.Comment {
+	comments := make([]api.Comment, 0, len(threads))
+
+	for _, comment := range threads {
+		replies := comment.Replies
+		comment.Replies = nil
+		comments = append(comments, comment)
+		comments = append(comments, flattenCommentThreads(replies)...)
+	}
+
+	return comments
+}
+
 func handleGetPostComments(w http.ResponseWriter, r *http.Request) {
-	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+	p, err := parsePagination(r)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	view := r.URL.Query().Get("view")
+
+	if view != "" && view != "tree" && view != "flat" {
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	filters := []bob.Expression{mysql.Quote("c", "deleted_at").IsNull()}
-	sortBy := mysql.Quote("c", "created_at")
+	q := store.CommentQuery{
+		Limit:    p.limit + 1,
+		Offset:   p.offset,
+		After:    p.after,
+		Author:   r.URL.Query().Get("user"),
+		Threaded: view != "",
+	}
+	maxDepth := getCommentMaxDepth()
+
+	if r.URL.Query().Get("depth") != "" {
+		depth, err := strconv.ParseUint(r.URL.Query().Get("depth"), 10, 64)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		maxDepth = min(maxDepth, uint(depth))
+	}
+
+	if view != "" && r.URL.Query().Get("parent") != "" {
+		parentID, err := strconv.ParseInt(r.URL.Query().Get("parent"), 10, 64)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		q.ParentID = &parentID
+	}
 
 	if r.URL.Query().Get("post") != "" {
 		postID, err := strconv.ParseInt(r.URL.Query().Get("post"), 10, 64)
 
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			problem.Error(w, r, http.StatusBadRequest)
 			return
 		}
 
-		filters = append(filters, mysql.Quote("c", "post_id").EQ(mysql.Arg(postID)))
+		q.PostID = &postID
 	}
 
-	if r.URL.Query().Get("user") != "" {
-		filters = append(filters, mysql.Quote("u", "username").EQ(mysql.Arg(r.URL.Query().Get("user"))))
-	}
+	comments, err := stores(r).Comments.GetPostComments(r.Context(), q)
 
-	comments, err := db.GetPostComments(10, 10*(page-1), filters, sortBy)
+	if err == store.ErrInvalidCursor {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(comments)
+	comments, next := nextPage(p, comments, store.CommentCursor)
+
+	if view != "" {
+		comments, err = getCommentThreads(r.Context(), comments, maxDepth)
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+	}
+
+	if view == "flat" {
+		comments = flattenCommentThreads(comments)
+	}
+
+	writePage(w, p, comments, next)
+}
+
+type createCommentRequest struct {
+	// Parent is the ID of the comment replied to, if any.
+	Parent *int64 `json:"parent"`
+	Body   string `json:"body"`
 }
 
 func handleCreatePostComment(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
 	postID, err := strconv.ParseInt(r.URL.Query().Get("post"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req createCommentRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	commentID, err := db.CreatePostComment(int64(userID), postID, utils.Sanitize(r.FormValue("body")))
+	var parent *api.Comment
+	var parentID *int64
+	var depth uint
+
+	if req.Parent != nil {
+		id := *req.Parent
+		parentComment, err := stores(r).Comments.GetPostComment(r.Context(), id)
+
+		if err == store.ErrNotFound {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		if parentComment.Deleted || int64(parentComment.PostID) != postID || parentComment.Depth+1 > getCommentMaxDepth() {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		parent = &parentComment
+		parentID = &id
+		depth = parentComment.Depth + 1
+	}
+
+	post, err := stores(r).Posts.GetPost(r.Context(), postID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	comment, err := db.GetPostComment(commentID)
+	if post.Deleted {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	commentID, err := stores(r).Comments.CreatePostComment(r.Context(), int64(userID), postID, parentID, depth, utils.Sanitize(req.Body))
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	comment, err := stores(r).Comments.GetPostComment(r.Context(), commentID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = notifications.CommentCreated(r.Context(), int64(userID), post, comment, parent)
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = events.Publish(events.PostTopic(comment.PostID), "comment.created", comment)
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	json.NewEncoder(w).Encode(comment)
 }
 
+type updateCommentRequest struct {
+	Body string `json:"body"`
+}
+
 func handleUpdatePostComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req updateCommentRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	comment, err := db.GetPostComment(commentID)
+	comment, err := stores(r).Comments.GetPostComment(r.Context(), commentID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if comment.Deleted {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	if !auth.CheckUserID(r, comment.Author.ID) {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, comment.Author.ID) && !auth.HasPostCapability(r, comment.PostID, auth.CommentEditAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	comment.Body = utils.Sanitize(r.FormValue("body"))
+	userID, _ := auth.GetUserID(r)
+
+	err = stores(r).Comments.UpdatePostComment(r.Context(), commentID, int64(userID), utils.Sanitize(req.Body))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
 
-	err = db.UpdatePostComment(commentID, comment.Body)
+	comment, err = stores(r).Comments.GetPostComment(r.Context(), commentID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	err = events.Publish(events.PostTopic(comment.PostID), "comment.updated", comment)
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	json.NewEncoder(w).Encode(comment)
 }
 
@@ -150,39 +368,47 @@ func handleDeletePostComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	comment, err := db.GetPostComment(commentID)
+	comment, err := stores(r).Comments.GetPostComment(r.Context(), commentID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if comment.Deleted {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	if !auth.CheckUserID(r, comment.Author.ID) {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, comment.Author.ID) && !auth.HasPostCapability(r, comment.PostID, auth.CommentDeleteAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	err = db.DeletePostComment(commentID)
+	err = stores(r).Comments.DeletePostComment(r.Context(), commentID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	deleted := comment
+	deleted.Deleted = true
+	err = events.Publish(events.PostTopic(comment.PostID), "comment.deleted", deleted)
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	json.NewEncoder(w).Encode(comment)
 }
 
@@ -190,8 +416,11 @@ func CommentsRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/{id:\\d+}", handleGetPostComment)
 		r.Get("/", handleGetPostComments)
-		r.Post("/", handleCreatePostComment)
+		r.With(ratelimit.Limit(ratelimit.GroupComments)).Post("/", handleCreatePostComment)
 		r.Patch("/{id:\\d+}", handleUpdatePostComment)
 		r.Delete("/{id:\\d+}", handleDeletePostComment)
+		r.Get("/{id:\\d+}/revisions", handleGetPostCommentRevisions)
+		r.Get("/{id:\\d+}/revisions/diff", handleGetPostCommentRevisionDiff)
+		r.Post("/{id:\\d+}/revisions/{number:\\d+}/restore", handleRestorePostCommentRevision)
 	}
 }
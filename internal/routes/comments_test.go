@@ -0,0 +1,86 @@
+package routes_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type testComment struct {
+	ID      uint
+	PostID  uint
+	Depth   uint
+	Deleted bool
+	Body    string
+	Author  struct{ ID uint }
+	Replies []testComment
+}
+
+func TestCreateComment(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+	bob := srv.client(t)
+	bobID := bob.register("bob")
+
+	var post testPost
+
+	alice.expect(http.StatusOK, "POST", "/api/posts/", map[string]any{"title": "Hello", "body": "<p>Hi</p>"}, &post)
+	path := fmt.Sprintf("/api/comments/?post=%d", post.ID)
+
+	srv.client(t).expect(http.StatusUnauthorized, "POST", path, map[string]any{"body": "<p>Anonymous</p>"}, nil)
+	bob.expect(http.StatusBadRequest, "POST", "/api/comments/?post=999", map[string]any{"body": "<p>Nowhere</p>"}, nil)
+
+	var comment, reply testComment
+
+	bob.expect(http.StatusOK, "POST", path, map[string]any{"body": "<p>Welcome</p>"}, &comment)
+	alice.expect(http.StatusOK, "POST", path, map[string]any{"body": "<p>Thanks</p>", "parent": comment.ID}, &reply)
+
+	if comment.Author.ID != bobID || reply.Depth != comment.Depth+1 {
+		t.Fatalf("got %+v and reply %+v, want a comment by %d and a reply one deeper", comment, reply, bobID)
+	}
+
+	var threads struct{ Items []testComment }
+
+	srv.client(t).expect(http.StatusOK, "GET", path+"&view=tree", nil, &threads)
+
+	if len(threads.Items) != 1 || len(threads.Items[0].Replies) != 1 || threads.Items[0].Replies[0].ID != reply.ID {
+		t.Errorf("got %+v, want the comment with its reply", threads.Items)
+	}
+
+	srv.client(t).expect(http.StatusOK, "GET", fmt.Sprintf("/api/posts/%d", post.ID), nil, &post)
+
+	if post.CommentCount != 2 {
+		t.Errorf("got comment count %d, want 2", post.CommentCount)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+	bob := srv.client(t)
+	bob.register("bob")
+
+	var post testPost
+	var comment testComment
+
+	alice.expect(http.StatusOK, "POST", "/api/posts/", map[string]any{"title": "Hello", "body": "<p>Hi</p>"}, &post)
+	bob.expect(http.StatusOK, "POST", fmt.Sprintf("/api/comments/?post=%d", post.ID), map[string]any{"body": "<p>Welcome</p>"}, &comment)
+	path := fmt.Sprintf("/api/comments/%d", comment.ID)
+
+	// The reply keeps the deleted comment in the thread.
+	alice.expect(http.StatusOK, "POST", fmt.Sprintf("/api/comments/?post=%d", post.ID), map[string]any{"body": "<p>Thanks</p>", "parent": comment.ID}, nil)
+
+	alice.expect(http.StatusForbidden, "DELETE", path, nil, nil)
+	bob.expect(http.StatusOK, "DELETE", path, nil, nil)
+	bob.expect(http.StatusBadRequest, "DELETE", path, nil, nil)
+
+	var comments struct{ Items []testComment }
+
+	srv.client(t).expect(http.StatusOK, "GET", fmt.Sprintf("/api/comments/?post=%d&view=tree", post.ID), nil, &comments)
+
+	if len(comments.Items) != 1 || !comments.Items[0].Deleted || comments.Items[0].Body != "" || len(comments.Items[0].Replies) != 1 {
+		t.Errorf("got %+v, want the comment left without its body above the reply", comments.Items)
+	}
+}
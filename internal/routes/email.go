@@ -0,0 +1,268 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/auth"
+	mailer "github.com/themintchoco/cvwo/internal/mail"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+const (
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposeResetPassword = "reset_password"
+
+	verifyEmailTokenLifetime   = time.Hour * 24
+	resetPasswordTokenLifetime = time.Hour
+)
+
+// parseEmail normalises an email address, returning false if it is invalid.
+func parseEmail(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	address, err := mail.ParseAddress(value)
+
+	if err != nil || address.Address != value || len(value) > 255 {
+		return "", false
+	}
+
+	return value, true
+}
+
+func sendUserToken(ctx context.Context, userID int64, email, purpose string, lifetime time.Duration, subject, body, path string) (err error) {
+	token, tokenHash, err := auth.GenerateToken()
+
+	if err != nil {
+		return
+	}
+
+	err = store.FromContext(ctx).Sessions.CreateUserToken(ctx, userID, purpose, tokenHash, email, time.Now().Add(lifetime))
+
+	if err != nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s%s?token=%s", utils.GetAppURL(), path, url.QueryEscape(token))
+
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: subject,
+		Body:    fmt.Sprintf("%s\n\n%s\n\nThis link expires in %s. If you did not request this, you can ignore this email.\n", body, link, lifetime),
+	})
+}
+
+func sendVerificationEmail(ctx context.Context, userID int64, email string) error {
+	return sendUserToken(ctx, userID, email, tokenPurposeVerifyEmail, verifyEmailTokenLifetime,
+		"Verify your email address",
+		"Confirm your email address for forum. by opening the link below:",
+		"/verify-email")
+}
+
+func sendPasswordResetEmail(ctx context.Context, userID int64, email string) error {
+	return sendUserToken(ctx, userID, email, tokenPurposeResetPassword, resetPasswordTokenLifetime,
+		"Reset your password",
+		"Someone requested a password reset for your forum. account. Set a new password by opening the link below:",
+		"/reset-password")
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, email, err := stores(r).Sessions.ConsumeUserToken(r.Context(), tokenPurposeVerifyEmail, auth.HashToken(req.Token))
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Users.VerifyUserEmail(r.Context(), userID, email)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// emailRequest asks for something to be done with an email address.
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+func (req *emailRequest) validate(errs *fieldErrors) {
+	req.Email = errs.checkEmail("email", req.Email)
+}
+
+func handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	email := req.Email
+
+	// Respond the same way whether or not the address is registered, so that
+	// this endpoint cannot be used to discover accounts.
+	userID, err := stores(r).Users.GetUserIDByEmail(r.Context(), email)
+
+	if err == nil {
+		err = sendPasswordResetEmail(r.Context(), userID, email)
+	}
+
+	if err != nil && err != store.ErrNotFound {
+		log.Println(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (req *resetPasswordRequest) validate(errs *fieldErrors) {
+	errs.checkPassword("password", req.Password)
+}
+
+func handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	password := req.Password
+	userID, email, err := stores(r).Sessions.ConsumeUserToken(r.Context(), tokenPurposeResetPassword, auth.HashToken(req.Token))
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Users.UpdateUser(r.Context(), userID, nil, &password, nil, nil)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	// Receiving the reset link proves ownership of the address.
+	err = stores(r).Users.VerifyUserEmail(r.Context(), userID, email)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Sessions.RevokeUserSessions(r.Context(), userID, nil)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleUpdateMyEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	var req emailRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	email := req.Email
+	existingID, err := stores(r).Users.GetUserIDByEmail(r.Context(), email)
+
+	if err == nil && existingID != int64(userID) {
+		problem.Write(w, r, &problem.Problem{Status: http.StatusConflict, Code: problem.CodeEmailTaken, Detail: "An account with this email address already exists."})
+		return
+	}
+
+	if err != nil && err != store.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Users.UpdateUserEmail(r.Context(), int64(userID), email)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = sendVerificationEmail(r.Context(), int64(userID), email)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	email, verified, err := stores(r).Users.GetUserEmail(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if email == nil || verified {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = sendVerificationEmail(r.Context(), int64(userID), *email)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
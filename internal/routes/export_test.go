@@ -0,0 +1,12 @@
+package routes
+
+import "time"
+
+// SetStreamHeartbeat changes how often streams are checked, returning a
+// function that restores it.
+func SetStreamHeartbeat(d time.Duration) (restore func()) {
+	old := streamHeartbeat
+	streamHeartbeat = d
+
+	return func() { streamHeartbeat = old }
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+)
+
+func handleGetLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := ratelimit.Lockouts()
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(lockouts)
+}
+
+func handleClearLockout(w http.ResponseWriter, r *http.Request) {
+	err := ratelimit.ClearLockout(chi.URLParam(r, "kind"), chi.URLParam(r, "value"))
+
+	if err == ratelimit.ErrUnknownKind {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func LockoutsRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(auth.RequireCapability(auth.LockoutManage))
+
+		r.Get("/", handleGetLockouts)
+		r.Delete("/{kind}/{value}", handleClearLockout)
+	}
+}
@@ -3,39 +3,108 @@ package routes
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/themintchoco/cvwo/internal/api"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/notifications"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
 func handleMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
-	prefs, err := db.GetUserPreferences(int64(userID))
+	prefs, err := stores(r).Users.GetUserPreferences(r.Context(), int64(userID))
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(api.Me{
-		ID:    userID,
-		Prefs: prefs,
-	})
+	capabilities := make([]string, 0)
+
+	for _, capability := range auth.GetCapabilities(r) {
+		capabilities = append(capabilities, string(capability))
+	}
+
+	me := api.Me{
+		ID:           userID,
+		Prefs:        prefs,
+		Capabilities: capabilities,
+	}
+
+	me.Email, me.EmailVerified, err = stores(r).Users.GetUserEmail(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	_, me.TwoFactorEnabled, _, err = stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if !me.TwoFactorEnabled {
+		role, err := stores(r).Users.GetUserRole(r.Context(), int64(userID))
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		me.TwoFactorEnrolmentRequired = role != "member" && auth.RoleRequiresTwoFactor(r.Context(), role)
+	}
+
+	suspension, err := stores(r).Moderation.GetUserActiveSuspension(r.Context(), int64(userID))
+
+	if err != nil && err != store.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+
+	if err == nil {
+		me.Suspension = &suspension
+	}
+
+	json.NewEncoder(w).Encode(me)
+}
+
+// preferenceRequest sets a preference, which is a flag sent as a boolean or
+// "true", or text.
+type preferenceRequest struct {
+	Value any `json:"value"`
+}
+
+func (req preferenceRequest) flag() bool {
+	return req.Value == true || req.Value == "true"
+}
+
+func (req preferenceRequest) text() string {
+	text, _ := req.Value.(string)
+	return text
 }
 
 func handleUpdateMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	var req preferenceRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
@@ -43,24 +112,160 @@ func handleUpdateMe(w http.ResponseWriter, r *http.Request) {
 
 	switch chi.URLParam(r, "key") {
 	case "prefersDarkMode":
-		err = db.UpdateUserPreferences(int64(userID), "prefersDarkMode", r.FormValue("value") == "true")
+		err = stores(r).Users.UpdateUserPreferences(r.Context(), int64(userID), "prefersDarkMode", req.flag())
 	case "prefersReducedMotion":
-		err = db.UpdateUserPreferences(int64(userID), "prefersReducedMotion", r.FormValue("value") == "true")
+		err = stores(r).Users.UpdateUserPreferences(r.Context(), int64(userID), "prefersReducedMotion", req.flag())
 	case "preferredSort":
-		err = db.UpdateUserPreferences(int64(userID), "preferredSort", r.FormValue("value"))
+		err = stores(r).Users.UpdateUserPreferences(r.Context(), int64(userID), "preferredSort", req.text())
+	case notifications.PreferenceKeys[notifications.TypePostComment],
+		notifications.PreferenceKeys[notifications.TypeCommentReply],
+		notifications.PreferenceKeys[notifications.TypeThreadComment],
+		notifications.PreferenceKeys[notifications.TypePostReaction],
+		notifications.PreferenceKeys[notifications.TypeCommentReaction]:
+		err = stores(r).Users.UpdateUserPreferences(r.Context(), int64(userID), chi.URLParam(r, "key"), req.flag())
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetMyWarnings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	warnings, err := stores(r).Moderation.GetUserWarnings(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(warnings)
+}
+
+func handleGetMySuspensions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
 	}
 
+	suspensions, err := stores(r).Moderation.GetUserSuspensions(r.Context(), int64(userID))
+
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(suspensions)
+}
+
+func handleGetMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := stores(r).Sessions.GetUserSessions(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	currentSessionID, _ := auth.GetSessionID(r)
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	json.NewEncoder(w).Encode(sessions)
+}
+
+func handleRevokeMySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if currentSessionID, ok := auth.GetSessionID(r); ok && int64(currentSessionID) == sessionID {
+		err = auth.SignOutUser(w, r)
+	} else {
+		err = stores(r).Sessions.RevokeSession(r.Context(), int64(userID), sessionID)
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRevokeMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	err := stores(r).Sessions.RevokeUserSessions(r.Context(), int64(userID), nil)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	auth.SignOutUser(w, r)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func MeRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/", handleMe)
+		r.Get("/warnings", handleGetMyWarnings)
+		r.Get("/suspensions", handleGetMySuspensions)
 		r.Patch("/{key:\\w+}", handleUpdateMe)
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireSession())
+
+			r.Get("/sessions", handleGetMySessions)
+			r.Delete("/sessions", handleRevokeMySessions)
+			r.Delete("/sessions/{id:\\d+}", handleRevokeMySession)
+			r.Post("/email", handleUpdateMyEmail)
+			r.Post("/email/resend", handleResendVerificationEmail)
+			r.Post("/twoFactor", handleStartTwoFactor)
+			r.Post("/twoFactor/confirm", handleConfirmTwoFactor)
+			r.Post("/twoFactor/recoveryCodes", handleRegenerateRecoveryCodes)
+			r.Delete("/twoFactor", handleDisableTwoFactor)
+			r.Get("/identities", handleGetMyIdentities)
+			r.Delete("/identities/{id:\\d+}", handleDeleteMyIdentity)
+			r.Get("/tokens", handleGetMyAPITokens)
+			r.Post("/tokens", handleCreateMyAPIToken)
+			r.Delete("/tokens/{id:\\d+}", handleRevokeMyAPIToken)
+		})
 	}
 }
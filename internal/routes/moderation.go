@@ -0,0 +1,359 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func handleGetReports(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	q := store.ReportQuery{
+		Limit:      10,
+		Offset:     10 * (page - 1),
+		TargetType: r.URL.Query().Get("targetType"),
+		Reason:     r.URL.Query().Get("reason"),
+	}
+
+	switch r.URL.Query().Get("status") {
+	case "", "pending":
+		q.Statuses = []string{"open", "claimed"}
+	case "open", "claimed", "resolved":
+		q.Statuses = []string{r.URL.Query().Get("status")}
+	case "all":
+	default:
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("targetId") != "" {
+		targetID, err := strconv.ParseInt(r.URL.Query().Get("targetId"), 10, 64)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		q.TargetID = &targetID
+	}
+
+	reports, err := stores(r).Moderation.GetReports(r.Context(), q)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reports)
+}
+
+func handleGetReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	report, err := stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+func handleGetTargetReports(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "targetID"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	reports, err := stores(r).Moderation.GetReports(r.Context(), store.ReportQuery{
+		Limit:      100,
+		TargetType: chi.URLParam(r, "targetType"),
+		TargetID:   &targetID,
+	})
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reports)
+}
+
+func handleClaimReport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	report, err := stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if report.Status == "resolved" {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Moderation.ClaimReport(r.Context(), reportID, int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	report, err = stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+func deleteReportTarget(ctx context.Context, report api.Report) (err error) {
+	switch report.TargetType {
+	case "post":
+		post, err := store.FromContext(ctx).Posts.GetPost(ctx, int64(report.TargetID))
+
+		if err != nil || post.Deleted {
+			return err
+		}
+
+		err = store.FromContext(ctx).Posts.DeletePost(ctx, int64(report.TargetID))
+
+		if err != nil {
+			return err
+		}
+
+		post.Deleted = true
+		err = events.Publish(events.PostTopic(post.ID), "post.deleted", post)
+
+		if err != nil {
+			log.Println(err)
+		}
+	case "comment":
+		comment, err := store.FromContext(ctx).Comments.GetPostComment(ctx, int64(report.TargetID))
+
+		if err != nil || comment.Deleted {
+			return err
+		}
+
+		err = store.FromContext(ctx).Comments.DeletePostComment(ctx, int64(report.TargetID))
+
+		if err != nil {
+			return err
+		}
+
+		comment.Deleted = true
+		err = events.Publish(events.PostTopic(comment.PostID), "comment.deleted", comment)
+
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return
+}
+
+var errReportResolved = errors.New("report is already resolved")
+
+type resolveReportRequest struct {
+	// Action is one of dismiss, delete, warn or suspend.
+	Action string `json:"action"`
+	Note   string `json:"note"`
+	// Duration is how long a suspension lasts, or empty if indefinitely.
+	Duration string `json:"duration"`
+}
+
+func (req *resolveReportRequest) validate(errs *fieldErrors) {
+	switch req.Action {
+	case "dismiss", "delete", "warn", "suspend":
+	default:
+		errs.add("action", problem.FieldInvalid, "Action must be one of dismiss, delete, warn or suspend.")
+	}
+
+	if req.Action == "suspend" {
+		errs.checkSuspensionDuration("duration", req.Duration)
+	}
+}
+
+func handleResolveReport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req resolveReportRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	report, err := stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if report.Status == "resolved" {
+		problem.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	action, note := req.Action, req.Note
+
+	if action == "delete" && report.TargetType == "user" {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	authorID, _, err := getReportTargetAuthor(r.Context(), report.TargetType, int64(report.TargetID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	var capability auth.Capability
+
+	switch action {
+	case "delete":
+		capability = auth.PostDeleteAny
+
+		if report.TargetType == "comment" {
+			capability = auth.CommentDeleteAny
+		}
+	case "warn":
+		capability = auth.UserWarn
+	case "suspend":
+		capability = auth.UserSuspend
+	}
+
+	if capability != "" && !auth.HasCapability(r, capability) {
+		auth.Deny(w, r)
+		return
+	}
+
+	if action == "warn" || action == "suspend" {
+		role, err := stores(r).Users.GetUserRole(r.Context(), authorID)
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		if !auth.Outranks(r, role) {
+			auth.Deny(w, r)
+			return
+		}
+	}
+
+	// Resolving first claims the report, so that when moderators resolve it
+	// at the same time only one of them applies the action.
+	err = stores(r).WithTx(r.Context(), func(ctx context.Context) (err error) {
+		err = store.FromContext(ctx).Moderation.ResolveReport(ctx, reportID, int64(userID), action, note)
+
+		if err == store.ErrNotFound {
+			return errReportResolved
+		}
+
+		if err != nil {
+			return
+		}
+
+		switch action {
+		case "delete":
+			err = deleteReportTarget(ctx, report)
+		case "warn":
+			_, err = store.FromContext(ctx).Moderation.CreateWarning(ctx, authorID, int64(userID), &reportID, note)
+		case "suspend":
+			endsAt, _ := parseSuspensionEnd(req.Duration)
+			_, err = store.FromContext(ctx).Moderation.CreateSuspension(ctx, authorID, int64(userID), &reportID, note, endsAt)
+		}
+
+		return
+	})
+
+	if errors.Is(err, errReportResolved) {
+		problem.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	report, err = stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+func ModerationRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(auth.RequireCapability(auth.ReportView))
+
+		r.Get("/reports", handleGetReports)
+		r.Get("/reports/{id:\\d+}", handleGetReport)
+		r.With(auth.RequireCapability(auth.ReportResolve)).Post("/reports/{id:\\d+}/claim", handleClaimReport)
+		r.With(auth.RequireCapability(auth.ReportResolve)).Post("/reports/{id:\\d+}/resolve", handleResolveReport)
+		r.Get("/targets/{targetType:post|comment|user}/{targetID:\\d+}/reports", handleGetTargetReports)
+	}
+}
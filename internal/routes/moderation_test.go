@@ -0,0 +1,113 @@
+package routes_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type testReport struct {
+	ID     uint
+	Status string
+}
+
+// promote gives userID role directly in the store.
+func promote(t *testing.T, srv *testServer, userID uint, role string) {
+	t.Helper()
+
+	err := srv.store.Users.UpdateUser(context.Background(), int64(userID), nil, nil, &role, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reportPost has reporter report a new post by author, returning the report.
+func reportPost(t *testing.T, author, reporter *testClient) (report testReport) {
+	t.Helper()
+
+	var post testPost
+
+	author.expect(http.StatusOK, "POST", "/api/posts/", map[string]any{"title": "Buy now", "body": "<p>Cheap</p>"}, &post)
+	reporter.expect(http.StatusCreated, "POST", "/api/reports/", map[string]any{"targetType": "post", "targetId": post.ID, "reason": "spam"}, &report)
+
+	return
+}
+
+func TestGetReports(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+	bob := srv.client(t)
+	bob.register("bob")
+	mod := srv.client(t)
+	promote(t, srv, mod.register("mod"), "moderator")
+
+	report := reportPost(t, alice, bob)
+
+	bob.expect(http.StatusForbidden, "GET", "/api/moderation/reports?page=1", nil, nil)
+
+	for query, want := range map[string]int{"": 1, "&status=all": 1, "&status=resolved": 0} {
+		var reports []testReport
+
+		mod.expect(http.StatusOK, "GET", "/api/moderation/reports?page=1"+query, nil, &reports)
+
+		if len(reports) != want || want > 0 && reports[0].ID != report.ID {
+			t.Errorf("%q: got %+v, want %d report", query, reports, want)
+		}
+	}
+}
+
+func TestResolveReportOnce(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	aliceID := alice.register("alice")
+	bob := srv.client(t)
+	bob.register("bob")
+	mod := srv.client(t)
+	promote(t, srv, mod.register("mod"), "moderator")
+
+	report := reportPost(t, alice, bob)
+	path := fmt.Sprintf("/api/moderation/reports/%d/resolve", report.ID)
+	warn := map[string]any{"action": "warn", "note": "No spam"}
+
+	mod.expect(http.StatusOK, "POST", path, warn, &report)
+	mod.expect(http.StatusConflict, "POST", path, warn, nil)
+
+	warnings, err := srv.store.Moderation.GetUserWarnings(context.Background(), int64(aliceID))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != "resolved" || len(warnings) != 1 {
+		t.Errorf("got status %q and %d warnings, want resolved with 1", report.Status, len(warnings))
+	}
+}
+
+func TestModeratorCannotSanctionHigherRoles(t *testing.T) {
+	srv := newTestServer(t)
+	admin := srv.client(t)
+	adminID := admin.register("boss")
+	promote(t, srv, adminID, "admin")
+	mod := srv.client(t)
+	promote(t, srv, mod.register("mod"), "moderator")
+	other := srv.client(t)
+	promote(t, srv, other.register("other"), "moderator")
+	bob := srv.client(t)
+	bob.register("bob")
+
+	report := reportPost(t, admin, bob)
+	path := fmt.Sprintf("/api/moderation/reports/%d/resolve", report.ID)
+
+	mod.expect(http.StatusForbidden, "POST", path, map[string]any{"action": "suspend", "note": "No"}, nil)
+	mod.expect(http.StatusForbidden, "POST", path, map[string]any{"action": "warn", "note": "No"}, nil)
+	mod.expect(http.StatusForbidden, "POST", fmt.Sprintf("/api/users/%d/suspensions", adminID), map[string]any{"reason": "No"}, nil)
+
+	otherReport := reportPost(t, other, bob)
+	mod.expect(http.StatusForbidden, "POST", fmt.Sprintf("/api/moderation/reports/%d/resolve", otherReport.ID), map[string]any{"action": "warn", "note": "No"}, nil)
+
+	// Removing the post does not act against its author.
+	mod.expect(http.StatusOK, "POST", path, map[string]any{"action": "delete"}, nil)
+}
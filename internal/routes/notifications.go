@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/notifications"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	items, err := stores(r).Notifications.GetNotifications(r.Context(), int64(userID), 10, 10*(page-1), r.URL.Query().Get("unread") == "true")
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	for i := range items {
+		items[i].Message = notifications.Describe(items[i])
+	}
+
+	json.NewEncoder(w).Encode(items)
+}
+
+func handleGetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	count, err := stores(r).Notifications.GetUnreadNotificationCount(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]uint{"count": count})
+}
+
+func handleReadNotification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := stores(r).Notifications.GetNotificationOwner(r.Context(), notificationID)
+
+	if err == store.ErrNotFound || (err == nil && ownerID != int64(userID)) {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Notifications.MarkNotificationRead(r.Context(), notificationID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	err := stores(r).Notifications.MarkAllNotificationsRead(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NotificationsRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/", handleGetNotifications)
+		r.Get("/unread", handleGetUnreadNotificationCount)
+		r.Post("/read", handleReadAllNotifications)
+		r.Post("/{id:\\d+}/read", handleReadNotification)
+	}
+}
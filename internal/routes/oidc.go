@@ -0,0 +1,495 @@
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/oidc"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	oidcFlowLifetime   = time.Minute * 10
+	oidcSignupLifetime = time.Minute * 30
+)
+
+var usernameInvalidChars = regexp.MustCompile("[^a-zA-Z0-9_]+")
+
+// getLocalRedirect only allows redirects to paths on this site.
+func getLocalRedirect(value string) string {
+	if !strings.HasPrefix(value, "/") || strings.HasPrefix(value, "//") || strings.HasPrefix(value, "/\\") {
+		return "/"
+	}
+
+	return value
+}
+
+// addLocalRedirectQuery sets key in the query string of the local redirect,
+// keeping any query it already has.
+func addLocalRedirectQuery(redirect, key, value string) string {
+	target, err := url.Parse(getLocalRedirect(redirect))
+
+	if err != nil {
+		target = &url.URL{Path: "/"}
+	}
+
+	query := target.Query()
+	query.Set(key, value)
+	target.RawQuery = query.Encode()
+
+	return target.String()
+}
+
+func redirectOIDCError(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(reason), http.StatusFound)
+}
+
+// suggestUsername derives a username from the identity claims, returning an
+// empty string if none of them can be made into a valid one.
+func suggestUsername(claims oidc.Claims) string {
+	candidates := []string{claims.PreferredUsername, strings.Split(claims.Email, "@")[0], claims.Name}
+
+	for _, candidate := range candidates {
+		username := strings.Trim(usernameInvalidChars.ReplaceAllString(candidate, "_"), "_")
+
+		if len(username) > 32 {
+			username = username[:32]
+		}
+
+		if usernamePattern.MatchString(username) {
+			return username
+		}
+	}
+
+	return ""
+}
+
+func handleGetOIDC(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled": oidc.Enabled(),
+		"name":    oidc.Name(),
+	})
+}
+
+func handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := oidc.GetProvider(r.Context())
+
+	if err == oidc.ErrNotConfigured {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Println(err)
+		problem.Error(w, r, http.StatusBadGateway)
+		return
+	}
+
+	state, nonce, verifier, err := oidc.NewAuthRequest()
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	claims := map[string]any{
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"redirect": getLocalRedirect(r.URL.Query().Get("redirect")),
+	}
+
+	// Linking happens in the callback, which arrives from the identity provider
+	// without the SameSite=Strict session cookie, so the user is recorded here.
+	if r.URL.Query().Get("link") == "true" {
+		userID, ok := auth.GetUserID(r)
+
+		if !ok {
+			problem.Error(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		if auth.IsAPIToken(r) {
+			problem.Error(w, r, http.StatusForbidden)
+			return
+		}
+
+		claims["link_user_id"] = userID
+	}
+
+	err = auth.SetFlowCookie(w, "oidc", "/api/auth/oidc", oidcFlowLifetime, claims)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	flow, ok := auth.GetFlowCookie(r, "oidc")
+	auth.ClearFlowCookie(w, "oidc", "/api/auth/oidc")
+
+	if !ok {
+		redirectOIDCError(w, r, "expired")
+		return
+	}
+
+	state, _ := flow["state"].(string)
+	nonce, _ := flow["nonce"].(string)
+	verifier, _ := flow["verifier"].(string)
+	redirect, _ := flow["redirect"].(string)
+
+	if r.URL.Query().Get("error") != "" {
+		redirectOIDCError(w, r, "denied")
+		return
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(r.URL.Query().Get("state"))) != 1 {
+		redirectOIDCError(w, r, "state")
+		return
+	}
+
+	provider, err := oidc.GetProvider(r.Context())
+
+	if err != nil {
+		log.Println(err)
+		redirectOIDCError(w, r, "provider")
+		return
+	}
+
+	idToken, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), verifier)
+
+	if err != nil {
+		log.Println(err)
+		redirectOIDCError(w, r, "provider")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), idToken, nonce)
+
+	if err != nil {
+		log.Println(err)
+		redirectOIDCError(w, r, "token")
+		return
+	}
+
+	var email *string
+
+	if claims.EmailVerified {
+		if parsed, ok := parseEmail(claims.Email); ok {
+			email = &parsed
+		}
+	}
+
+	userID, err := stores(r).Users.GetIdentityUserID(r.Context(), claims.Issuer, claims.Subject)
+
+	if err != nil && err != store.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+
+	if linkUserID, ok := flow["link_user_id"].(float64); ok {
+		if err == nil && userID != int64(linkUserID) {
+			http.Redirect(w, r, addLocalRedirectQuery(redirect, "error", "identityInUse"), http.StatusFound)
+			return
+		}
+
+		if err == store.ErrNotFound {
+			err = stores(r).Users.CreateIdentity(r.Context(), int64(linkUserID), claims.Issuer, claims.Subject, email)
+		} else {
+			err = stores(r).Users.UpdateIdentityLogin(r.Context(), claims.Issuer, claims.Subject, email)
+		}
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, getLocalRedirect(redirect), http.StatusFound)
+		return
+	}
+
+	if err == store.ErrNotFound {
+		username := suggestUsername(claims)
+		available := false
+
+		if username != "" {
+			available, err = stores(r).Users.GetUsernameAvailability(r.Context(), username)
+
+			if err != nil {
+				serverError(w, r, err)
+				return
+			}
+		}
+
+		if !available {
+			signup := map[string]any{
+				"issuer":   claims.Issuer,
+				"subject":  claims.Subject,
+				"username": username,
+				"redirect": redirect,
+			}
+
+			if email != nil {
+				signup["email"] = *email
+			}
+
+			err = auth.SetFlowCookie(w, "oidc_signup", "/api/auth/oidc", oidcSignupLifetime, signup)
+
+			if err != nil {
+				serverError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/register?oidc=true&redirect="+url.QueryEscape(getLocalRedirect(redirect)), http.StatusFound)
+			return
+		}
+
+		userID, err = createExternalUser(r.Context(), username, email, claims.Issuer, claims.Subject)
+	} else {
+		err = stores(r).Users.UpdateIdentityLogin(r.Context(), claims.Issuer, claims.Subject, email)
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	suspended, err := stores(r).Moderation.GetUserSuspended(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if suspended {
+		redirectOIDCError(w, r, "suspended")
+		return
+	}
+
+	_, twoFactorEnabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	// The provider stands in for the password, but a second factor enrolled
+	// here is still required.
+	if twoFactorEnabled {
+		err = auth.StartTwoFactorChallenge(w, uint(userID))
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		query := url.Values{"twoFactor": {"true"}, "redirect": {getLocalRedirect(redirect)}}
+		http.Redirect(w, r, "/login?"+query.Encode(), http.StatusFound)
+		return
+	}
+
+	err = auth.SignInUser(w, r, uint(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, getLocalRedirect(redirect), http.StatusFound)
+}
+
+// createExternalUser provisions an account for a new identity. The verified
+// email is only attached if no other account uses it.
+func createExternalUser(ctx context.Context, username string, email *string, issuer, subject string) (userID int64, err error) {
+	if email != nil {
+		_, err = store.FromContext(ctx).Users.GetUserIDByEmail(ctx, *email)
+
+		if err == nil {
+			email = nil
+		} else if err != store.ErrNotFound {
+			return
+		}
+	}
+
+	err = store.FromContext(ctx).WithTx(ctx, func(ctx context.Context) (err error) {
+		userID, err = store.FromContext(ctx).Users.CreateExternalUser(ctx, username, email)
+
+		if err != nil {
+			return
+		}
+
+		return store.FromContext(ctx).Users.CreateIdentity(ctx, userID, issuer, subject, email)
+	})
+
+	return
+}
+
+func handleGetOIDCSignup(w http.ResponseWriter, r *http.Request) {
+	signup, ok := auth.GetFlowCookie(r, "oidc_signup")
+
+	if !ok {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"provider":          oidc.Name(),
+		"suggestedUsername": signup["username"],
+		"email":             signup["email"],
+	})
+}
+
+// oidcSignupRequest chooses the username of a user signing up through an
+// identity provider.
+type oidcSignupRequest struct {
+	Username string `json:"username"`
+}
+
+func (req *oidcSignupRequest) validate(errs *fieldErrors) {
+	errs.checkUsername("username", req.Username)
+}
+
+func handleCompleteOIDCSignup(w http.ResponseWriter, r *http.Request) {
+	signup, ok := auth.GetFlowCookie(r, "oidc_signup")
+
+	if !ok {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	var req oidcSignupRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	username := req.Username
+	available, err := stores(r).Users.GetUsernameAvailability(r.Context(), username)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if !available {
+		problem.Write(w, r, &problem.Problem{Status: http.StatusConflict, Code: problem.CodeUsernameTaken, Detail: "This username is taken."})
+		return
+	}
+
+	issuer, _ := signup["issuer"].(string)
+	subject, _ := signup["subject"].(string)
+
+	var email *string
+
+	if value, ok := signup["email"].(string); ok {
+		email = &value
+	}
+
+	_, err = stores(r).Users.GetIdentityUserID(r.Context(), issuer, subject)
+
+	if err == nil {
+		problem.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	if err != store.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+
+	userID, err := createExternalUser(r.Context(), username, email, issuer, subject)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	auth.ClearFlowCookie(w, "oidc_signup", "/api/auth/oidc")
+
+	err = auth.SignInUser(w, r, uint(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Me{ID: uint(userID)})
+}
+
+func handleGetMyIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	identities, err := stores(r).Users.GetUserIdentities(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(identities)
+}
+
+func handleDeleteMyIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	identityID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	hasPassword, err := stores(r).Users.GetUserHasPassword(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	identities, err := stores(r).Users.GetUserIdentities(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	// Keep at least one way to sign in.
+	if !hasPassword && len(identities) <= 1 {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Users.DeleteIdentity(r.Context(), int64(userID), identityID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 50
+)
+
+// pagination is the page of a list asked for by a request: the one after
+// cursor, or the numbered page for clients that predate cursors.
+type pagination struct {
+	limit  int64
+	offset int64
+	after  *store.Cursor
+	// numbered is set when the page was asked for by number, which is answered
+	// with the items alone rather than a Page.
+	numbered bool
+}
+
+func parsePagination(r *http.Request) (p pagination, err error) {
+	p.limit = defaultPageSize
+
+	if r.URL.Query().Get("limit") != "" {
+		p.limit, err = strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+		if err != nil || p.limit < 1 {
+			return p, errors.New("invalid limit")
+		}
+
+		p.limit = min(p.limit, maxPageSize)
+	}
+
+	if r.URL.Query().Get("page") != "" {
+		if r.URL.Query().Get("cursor") != "" {
+			return p, errors.New("page and cursor are exclusive")
+		}
+
+		page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+
+		if err != nil || page < 1 {
+			return p, errors.New("invalid page")
+		}
+
+		p.offset = p.limit * (page - 1)
+		p.numbered = true
+	}
+
+	if r.URL.Query().Get("cursor") != "" {
+		cursor, err := store.ParseCursor(r.URL.Query().Get("cursor"))
+
+		if err != nil {
+			return p, err
+		}
+
+		p.after = &cursor
+	}
+
+	return
+}
+
+// nextPage cuts items, which were queried with a limit one past the page size,
+// down to the page, returning the cursor of its last item if there are more.
+func nextPage[T any](p pagination, items []T, cursor func(T) store.Cursor) (page []T, next *string) {
+	if int64(len(items)) <= p.limit {
+		return items, nil
+	}
+
+	items = items[:p.limit]
+	next = new(string)
+	*next = cursor(items[len(items)-1]).String()
+
+	return items, next
+}
+
+func writePage[T any](w http.ResponseWriter, p pagination, items []T, next *string) {
+	if p.numbered {
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Page[T]{
+		Items:      items,
+		NextCursor: next,
+		HasMore:    next != nil,
+	})
+}
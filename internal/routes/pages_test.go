@@ -0,0 +1,153 @@
+package routes_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// seedPosts creates count posts by the seeded admin directly in the store,
+// as creating them through the API would be rate limited.
+func seedPosts(t *testing.T, srv *testServer, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		_, err := srv.store.Posts.CreatePost(context.Background(), 1, fmt.Sprintf("Post %d", i), "<p>Body</p>")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPostsCursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+	seedPosts(t, srv, 25)
+	c := srv.client(t)
+
+	seen := map[uint]bool{}
+	sizes := []int{}
+	query := url.Values{"limit": {"10"}}
+
+	for {
+		var page struct {
+			Items      []testPost
+			NextCursor *string
+			HasMore    bool
+		}
+
+		c.expect(http.StatusOK, "GET", "/api/posts/?"+query.Encode(), nil, &page)
+		sizes = append(sizes, len(page.Items))
+
+		for _, post := range page.Items {
+			if seen[post.ID] {
+				t.Fatalf("post %d returned twice", post.ID)
+			}
+
+			seen[post.ID] = true
+		}
+
+		if page.HasMore != (page.NextCursor != nil) {
+			t.Fatalf("got hasMore %v with cursor %v", page.HasMore, page.NextCursor)
+		}
+
+		if !page.HasMore {
+			break
+		}
+
+		query.Set("cursor", *page.NextCursor)
+	}
+
+	if fmt.Sprint(sizes) != "[10 10 5]" || len(seen) != 25 {
+		t.Errorf("got pages of %v with %d posts, want [10 10 5] with 25", sizes, len(seen))
+	}
+}
+
+func TestPostsCursorSkipsNewPosts(t *testing.T) {
+	srv := newTestServer(t)
+	seedPosts(t, srv, 5)
+	c := srv.client(t)
+
+	var first struct {
+		Items      []testPost
+		NextCursor *string
+	}
+
+	c.expect(http.StatusOK, "GET", "/api/posts/?limit=3", nil, &first)
+
+	// A post made in between would shift a numbered page, but not a cursor.
+	seedPosts(t, srv, 1)
+
+	var second struct{ Items []testPost }
+
+	c.expect(http.StatusOK, "GET", "/api/posts/?limit=3&cursor="+url.QueryEscape(*first.NextCursor), nil, &second)
+
+	if len(second.Items) != 2 || second.Items[0].ID >= first.Items[2].ID {
+		t.Errorf("got %+v after %+v, want the 2 older posts", second.Items, first.Items)
+	}
+}
+
+func TestPostsNumberedPages(t *testing.T) {
+	srv := newTestServer(t)
+	seedPosts(t, srv, 12)
+	c := srv.client(t)
+
+	var posts []testPost
+
+	c.expect(http.StatusOK, "GET", "/api/posts/?page=2", nil, &posts)
+
+	if len(posts) != 2 {
+		t.Errorf("got %d posts on page 2, want 2", len(posts))
+	}
+}
+
+func TestInvalidPagination(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.client(t)
+
+	for _, query := range []string{"limit=0", "page=0", "page=1&cursor=abc", "cursor=abc", "sort=top&cursor=abc"} {
+		c.expect(http.StatusBadRequest, "GET", "/api/posts/?"+query, nil, nil)
+	}
+}
+
+func TestCommentsCursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	postID, err := srv.store.Posts.CreatePost(ctx, 1, "Hello", "<p>Hi</p>")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 7; i++ {
+		_, err = srv.store.Comments.CreatePostComment(ctx, 1, postID, nil, 0, fmt.Sprintf("<p>Comment %d</p>", i))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := srv.client(t)
+	path := fmt.Sprintf("/api/comments/?post=%d&limit=4", postID)
+
+	var first, second struct {
+		Items      []testComment
+		NextCursor *string
+		HasMore    bool
+	}
+
+	c.expect(http.StatusOK, "GET", path, nil, &first)
+
+	if len(first.Items) != 4 || !first.HasMore {
+		t.Fatalf("got %d comments, hasMore %v, want 4 and more", len(first.Items), first.HasMore)
+	}
+
+	c.expect(http.StatusOK, "GET", path+"&cursor="+url.QueryEscape(*first.NextCursor), nil, &second)
+
+	if len(second.Items) != 3 || second.HasMore {
+		t.Errorf("got %d comments, hasMore %v, want the last 3", len(second.Items), second.HasMore)
+	}
+}
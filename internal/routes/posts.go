@@ -1,17 +1,22 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/stephenafamo/bob"
-	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/themintchoco/cvwo/internal/api"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/search"
+	"github.com/themintchoco/cvwo/internal/store"
 	"github.com/themintchoco/cvwo/internal/utils"
 )
 
@@ -19,165 +24,262 @@ func handleGetPost(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	post, err := db.GetPost(postID)
+	post, err := stores(r).Posts.GetPost(r.Context(), postID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(post)
 }
 
+// topWindows are the periods that sort=top can rank posts over. All time is
+// the default.
+var topWindows = map[string]time.Duration{
+	"":      0,
+	"all":   0,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
 func handleGetPosts(w http.ResponseWriter, r *http.Request) {
-	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+	p, err := parsePagination(r)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	filters := []bob.Expression{mysql.Quote("p", "deleted_at").IsNull()}
-	var sortBy any = mysql.Quote("p", "created_at")
-
-	if r.URL.Query().Get("user") != "" {
-		filters = append(filters, mysql.Quote("u", "username").EQ(mysql.Arg(r.URL.Query().Get("user"))))
+	q := store.PostQuery{
+		Limit:  p.limit + 1,
+		Offset: p.offset,
+		After:  p.after,
+		Author: r.URL.Query().Get("user"),
+		Sort:   r.URL.Query().Get("sort"),
 	}
 
 	if r.URL.Query().Get("tag") != "" {
-		filters = append(filters, mysql.Quote("t", "id").EQ(mysql.Arg(r.URL.Query().Get("tag"))))
+		tagID, err := strconv.ParseInt(r.URL.Query().Get("tag"), 10, 64)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		q.TagID = &tagID
 	}
 
 	if r.URL.Query().Get("query") != "" {
-		filters = append(filters, mysql.Or(
-			mysql.Quote("p", "title").Like(mysql.Arg("%"+r.URL.Query().Get("query")+"%")),
-			mysql.Quote("p", "body").Like(mysql.Arg("%"+r.URL.Query().Get("query")+"%")),
-		))
-	}
+		query, err := search.Parse(r.URL.Query().Get("query"))
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
 
-	if r.URL.Query().Get("sort") == "popular" {
-		sortBy = mysql.F("COUNT", mysql.Quote("pr", "reaction_id"))
+		q.Search = &query
 	}
 
-	if r.URL.Query().Get("sort") == "replies" {
-		sortBy = mysql.F("COUNT", "DISTINCT c.id")
+	if store.PostSort(q.Sort) == store.PostSortTop {
+		window, ok := topWindows[r.URL.Query().Get("window")]
+
+		if !ok {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		if window > 0 {
+			since := time.Now().Add(-window)
+			q.Since = &since
+		}
 	}
 
-	posts, err := db.GetPosts(10, 10*(page-1), filters, sortBy)
+	posts, err := stores(r).Posts.GetPosts(r.Context(), q)
+
+	if err == store.ErrInvalidCursor {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(posts)
+	posts, next := nextPage(p, posts, func(post api.Post) store.Cursor {
+		return store.PostCursor(q.Sort, post)
+	})
+
+	writePage(w, p, posts, next)
+}
+
+type createPostRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Tags are the names of up to 3 tags.
+	Tags []string `json:"tags"`
+}
+
+func (req *createPostRequest) validate(errs *fieldErrors) {
+	if req.Title == "" {
+		errs.add("title", problem.FieldRequired, "Title is required.")
+	}
+
+	req.Tags = errs.checkTags("tags", req.Tags)
 }
 
 func handleCreatePost(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
-	if len(r.FormValue("title")) == 0 {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	var req createPostRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	postID, err := db.CreatePost(int64(userID), r.FormValue("title"), utils.Sanitize(r.FormValue("body")))
+	var postID int64
+
+	err := stores(r).WithTx(r.Context(), func(ctx context.Context) (err error) {
+		postID, err = store.FromContext(ctx).Posts.CreatePost(ctx, int64(userID), req.Title, utils.Sanitize(req.Body))
+
+		if err != nil {
+			return
+		}
+
+		return store.FromContext(ctx).Posts.SetPostTags(ctx, postID, req.Tags)
+	})
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	re, err := regexp.Compile("^[a-z-]+$")
+	post, err := stores(r).Posts.GetPost(r.Context(), postID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	tags := strings.SplitN(r.FormValue("tags"), ",", 4)
-	tags = tags[:min(len(tags), 3)]
+	for _, tag := range strings.Split(string(post.Tags), ",") {
+		tagID, err := strconv.ParseUint(tag, 10, 64)
 
-	for _, tag := range tags {
-		tag = strings.TrimSpace(tag)
-
-		if len(tag) == 0 || len(tag) > 32 {
+		if err != nil {
 			continue
 		}
 
-		match := re.MatchString(tag)
+		err = events.Publish(events.TagTopic(uint(tagID)), "post.created", post)
 
-		if !match {
-			continue
+		if err != nil {
+			log.Println(err)
 		}
-
-		db.CreateTag(tag, "gray", "")
-		db.CreatePostTag(postID, tag)
 	}
 
-	post, err := db.GetPost(postID)
+	json.NewEncoder(w).Encode(post)
+}
 
-	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+// updatePostRequest changes the fields of a post that are not null.
+type updatePostRequest struct {
+	Title *string  `json:"title"`
+	Body  *string  `json:"body"`
+	Tags  []string `json:"tags"`
+}
+
+func (req *updatePostRequest) validate(errs *fieldErrors) {
+	if req.Title != nil && *req.Title == "" {
+		errs.add("title", problem.FieldRequired, "Title cannot be empty.")
 	}
 
-	json.NewEncoder(w).Encode(post)
+	if req.Tags != nil {
+		req.Tags = errs.checkTags("tags", req.Tags)
+	}
 }
 
 func handleUpdatePost(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	post, err := db.GetPost(postID)
+	post, err := stores(r).Posts.GetPost(r.Context(), postID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if post.Deleted {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	if !auth.CheckUserID(r, post.Author.ID) {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, post.Author.ID) && !auth.HasPostCapability(r, post.ID, auth.PostEditAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	post.Body = utils.Sanitize(r.FormValue("body"))
+	var req updatePostRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	title, body := post.Title, post.Body
+
+	if req.Title != nil {
+		title = *req.Title
+	}
+
+	if req.Body != nil {
+		body = utils.Sanitize(*req.Body)
+	}
+
+	userID, _ := auth.GetUserID(r)
+
+	err = stores(r).Posts.UpdatePost(r.Context(), postID, int64(userID), title, body, req.Tags)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
 
-	err = db.UpdatePost(postID, post.Title, post.Body)
+	post, err = stores(r).Posts.GetPost(r.Context(), postID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	err = events.Publish(events.PostTopic(post.ID), "post.updated", post)
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	json.NewEncoder(w).Encode(post)
 }
 
@@ -185,39 +287,47 @@ func handleDeletePost(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	post, err := db.GetPost(postID)
+	post, err := stores(r).Posts.GetPost(r.Context(), postID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if post.Deleted {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	if !auth.CheckUserID(r, post.Author.ID) {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, post.Author.ID) && !auth.HasPostCapability(r, post.ID, auth.PostDeleteAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	err = db.DeletePost(postID)
+	err = stores(r).Posts.DeletePost(r.Context(), postID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	deleted := post
+	deleted.Deleted = true
+	err = events.Publish(events.PostTopic(post.ID), "post.deleted", deleted)
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	json.NewEncoder(w).Encode(post)
 }
 
@@ -225,8 +335,11 @@ func PostsRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/{id:\\d+}", handleGetPost)
 		r.Get("/", handleGetPosts)
-		r.Post("/", handleCreatePost)
+		r.With(ratelimit.Limit(ratelimit.GroupPosts)).Post("/", handleCreatePost)
 		r.Patch("/{id:\\d+}", handleUpdatePost)
 		r.Delete("/{id:\\d+}", handleDeletePost)
+		r.Get("/{id:\\d+}/revisions", handleGetPostRevisions)
+		r.Get("/{id:\\d+}/revisions/diff", handleGetPostRevisionDiff)
+		r.Post("/{id:\\d+}/revisions/{number:\\d+}/restore", handleRestorePostRevision)
 	}
 }
@@ -0,0 +1,95 @@
+package routes_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type testPost struct {
+	ID           uint
+	Title        string
+	Body         string
+	Tags         []uint
+	CommentCount uint
+	Author       struct{ ID uint }
+}
+
+func TestCreatePost(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	id := alice.register("alice")
+
+	srv.client(t).expect(http.StatusUnauthorized, "POST", "/api/posts/", map[string]any{"title": "Hello", "body": "<p>Hi</p>"}, nil)
+
+	var post testPost
+
+	alice.expect(http.StatusOK, "POST", "/api/posts/", map[string]any{
+		"title": "Hello",
+		"body":  "<p>Hi</p><script>alert(1)</script>",
+		"tags":  []string{"go", "intro"},
+	}, &post)
+
+	if post.Author.ID != id || len(post.Tags) != 2 {
+		t.Fatalf("got %+v, want a post by %d with 2 tags", post, id)
+	}
+
+	var got testPost
+
+	srv.client(t).expect(http.StatusOK, "GET", fmt.Sprintf("/api/posts/%d", post.ID), nil, &got)
+
+	if got.Title != "Hello" || got.Body != "<p>Hi</p>" {
+		t.Errorf("got %+v, want the title and the sanitised body", got)
+	}
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	srv := newTestServer(t)
+
+	cases := []struct {
+		body  map[string]any
+		field string
+		code  string
+	}{
+		{map[string]any{"body": "<p>Hi</p>"}, "title", "required"},
+		{map[string]any{"title": "Hello", "tags": []string{"Not valid"}}, "tags", "invalid"},
+		{map[string]any{"title": "Hello", "tags": []string{"a", "b", "c", "d"}}, "tags", "too_long"},
+		{map[string]any{"title": "Hello", "extra": true}, "extra", "unknown"},
+	}
+
+	for i, c := range cases {
+		var p problemBody
+
+		// New accounts may only make a few posts, even invalid ones.
+		user := srv.client(t)
+		user.register(fmt.Sprintf("user%d", i))
+		user.expect(http.StatusBadRequest, "POST", "/api/posts/", c.body, &p)
+
+		if len(p.Errors) != 1 || p.Errors[0].Field != c.field || p.Errors[0].Code != c.code {
+			t.Errorf("%v: got %+v, want %s %s", c.body, p.Errors, c.field, c.code)
+		}
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+	bob := srv.client(t)
+	bob.register("bob")
+
+	var post testPost
+
+	alice.expect(http.StatusOK, "POST", "/api/posts/", map[string]any{"title": "Hello", "body": "<p>Hi</p>"}, &post)
+	path := fmt.Sprintf("/api/posts/%d", post.ID)
+
+	srv.client(t).expect(http.StatusUnauthorized, "PATCH", path, map[string]any{"title": "Anonymous"}, nil)
+	bob.expect(http.StatusForbidden, "PATCH", path, map[string]any{"title": "Bob's now"}, nil)
+	bob.expect(http.StatusForbidden, "DELETE", path, nil, nil)
+
+	alice.expect(http.StatusOK, "PATCH", path, map[string]any{"title": "Hello again"}, &post)
+
+	if post.Title != "Hello again" || post.Body != "<p>Hi</p>" {
+		t.Errorf("got %+v, want only the title changed", post)
+	}
+}
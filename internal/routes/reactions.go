@@ -2,38 +2,43 @@ package routes
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/notifications"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
 func handleGetPostReaction(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
 	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	reaction, err := db.GetPostReaction(userID, postID)
+	reaction, err := stores(r).Reactions.GetPostReaction(r.Context(), userID, postID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -44,48 +49,85 @@ func handleGetPostReactions(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	reactions, err := db.GetPostReactions(postID)
+	reactions, err := stores(r).Reactions.GetPostReactions(r.Context(), postID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(reactions)
 }
 
+// reactionRequest sets the reaction of the user to a post or comment, removing
+// it if Reaction is empty or null.
+type reactionRequest struct {
+	Reaction string `json:"reaction"`
+}
+
 func handleSetPostReaction(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
 	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req reactionRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	reaction := r.FormValue("reaction")
+	reaction := req.Reaction
 
 	if reaction == "" {
-		err = db.DeletePostReaction(int64(userID), postID)
+		err = stores(r).Reactions.DeletePostReaction(r.Context(), int64(userID), postID)
 	} else {
-		err = db.CreatePostReaction(int64(userID), postID, reaction)
+		err = stores(r).Reactions.CreatePostReaction(r.Context(), int64(userID), postID, reaction)
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	if reaction != "" {
+		post, err := stores(r).Posts.GetPost(r.Context(), postID)
+
+		if err == nil {
+			err = notifications.PostReacted(r.Context(), int64(userID), post)
+		}
+
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	reactions, err := stores(r).Reactions.GetPostReactions(r.Context(), postID)
+
+	if err == nil {
+		err = events.Publish(events.PostTopic(uint(postID)), "post.reactions", map[string]any{
+			"postId":    postID,
+			"reactions": reactions,
+		})
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -93,26 +135,26 @@ func handleGetCommentReaction(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
 	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	reaction, err := db.GetCommentReaction(userID, commentID)
+	reaction, err := stores(r).Reactions.GetCommentReaction(r.Context(), userID, commentID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -123,14 +165,14 @@ func handleGetCommentReactions(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	reactions, err := db.GetCommentReactions(commentID)
+	reactions, err := stores(r).Reactions.GetCommentReactions(r.Context(), commentID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -141,30 +183,59 @@ func handleSetCommentReaction(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized)
 		return
 	}
 
 	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	reaction := r.FormValue("reaction")
+	var req reactionRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	reaction := req.Reaction
 
 	if reaction == "" {
-		err = db.DeleteCommentReaction(int64(userID), commentID)
+		err = stores(r).Reactions.DeleteCommentReaction(r.Context(), int64(userID), commentID)
 	} else {
-		err = db.CreateCommentReaction(int64(userID), commentID, reaction)
+		err = stores(r).Reactions.CreateCommentReaction(r.Context(), int64(userID), commentID, reaction)
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	comment, err := stores(r).Comments.GetPostComment(r.Context(), commentID)
+
+	if err == nil && reaction != "" {
+		err = notifications.CommentReacted(r.Context(), int64(userID), comment)
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	reactions, err := stores(r).Reactions.GetCommentReactions(r.Context(), commentID)
+
+	if err == nil {
+		err = events.Publish(events.PostTopic(comment.PostID), "comment.reactions", map[string]any{
+			"commentId": commentID,
+			"reactions": reactions,
+		})
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -172,9 +243,9 @@ func ReactionsRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/post/{postID:\\d+}/{userID:\\d+}", handleGetPostReaction)
 		r.Get("/post/{postID:\\d+}", handleGetPostReactions)
-		r.Post("/post/{postID:\\d+}", handleSetPostReaction)
+		r.With(ratelimit.Limit(ratelimit.GroupReactions)).Post("/post/{postID:\\d+}", handleSetPostReaction)
 		r.Get("/comment/{commentID:\\d+}/{userID:\\d+}", handleGetCommentReaction)
 		r.Get("/comment/{commentID:\\d+}", handleGetCommentReactions)
-		r.Post("/comment/{commentID:\\d+}", handleSetCommentReaction)
+		r.With(ratelimit.Limit(ratelimit.GroupReactions)).Post("/comment/{commentID:\\d+}", handleSetCommentReaction)
 	}
 }
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"nsfw":           true,
+	"misinformation": true,
+	"other":          true,
+}
+
+func getReportTargetAuthor(ctx context.Context, targetType string, targetID int64) (authorID int64, deleted bool, err error) {
+	switch targetType {
+	case "post":
+		post, err := store.FromContext(ctx).Posts.GetPost(ctx, targetID)
+		return int64(post.Author.ID), post.Deleted, err
+	case "comment":
+		comment, err := store.FromContext(ctx).Comments.GetPostComment(ctx, targetID)
+		return int64(comment.Author.ID), comment.Deleted, err
+	case "user":
+		user, err := store.FromContext(ctx).Users.GetUser(ctx, targetID)
+		return int64(user.ID), user.Deleted, err
+	}
+
+	return 0, false, store.ErrNotFound
+}
+
+type createReportRequest struct {
+	// TargetType is post, comment or user.
+	TargetType string `json:"targetType"`
+	TargetID   *int64 `json:"targetId"`
+	Reason     string `json:"reason"`
+	Note       string `json:"note"`
+}
+
+func (req *createReportRequest) validate(errs *fieldErrors) {
+	if req.TargetID == nil {
+		errs.add("targetId", problem.FieldRequired, "Target ID is required.")
+	}
+
+	if !reportReasons[req.Reason] {
+		errs.add("reason", problem.FieldInvalid, "Reason is not one of the accepted reasons.")
+	}
+
+	if len(req.Note) > 1000 {
+		errs.add("note", problem.FieldTooLong, "Note must be at most 1000 characters.")
+	}
+}
+
+func handleCreateReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	var req createReportRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	targetType, targetID := req.TargetType, *req.TargetID
+
+	_, deleted, err := getReportTargetAuthor(r.Context(), targetType, targetID)
+
+	if err == store.ErrNotFound || deleted {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	reportID, err := stores(r).Moderation.CreateReport(r.Context(), int64(userID), targetType, targetID, req.Reason, req.Note)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	report, err := stores(r).Moderation.GetReport(r.Context(), reportID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+func ReportsRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.With(ratelimit.Limit(ratelimit.GroupReports)).Post("/", handleCreateReport)
+	}
+}
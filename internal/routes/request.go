@@ -0,0 +1,185 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/themintchoco/cvwo/internal/problem"
+)
+
+const (
+	maxJSONBodySize   = 1 << 20
+	maxFormMemorySize = 32 << 20
+)
+
+var errUnsupportedField = errors.New("routes: cannot decode form value into field")
+
+// validator is implemented by request structs that check their own fields
+// once decoded.
+type validator interface {
+	validate(errs *fieldErrors)
+}
+
+// decodeRequest reads the body of r into req, a pointer to a request struct,
+// and validates it. Bodies are read as JSON if sent as application/json, and
+// as form values otherwise, with each field named by its json tag. Pointer
+// fields are left nil if the field is absent, and slices are sent in forms as
+// comma-separated values.
+//
+// If the body cannot be read or fails validation, decodeRequest responds with
+// a problem and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	var errs fieldErrors
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if r.Header.Get("Content-Type") == "" {
+		mediaType, err = "", nil
+	}
+
+	switch {
+	case err != nil:
+		problem.Error(w, r, http.StatusUnsupportedMediaType)
+		return false
+	case mediaType == "application/json":
+		err = decodeJSON(w, r, req, &errs)
+	case mediaType == "", mediaType == "application/x-www-form-urlencoded", mediaType == "multipart/form-data":
+		err = decodeForm(r, req, &errs)
+	default:
+		problem.Write(w, r, &problem.Problem{Status: http.StatusUnsupportedMediaType, Detail: "Send the body as application/json, application/x-www-form-urlencoded or multipart/form-data."})
+		return false
+	}
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		problem.Error(w, r, http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	if errors.Is(err, errUnsupportedField) {
+		serverError(w, r, err)
+		return false
+	}
+
+	if err != nil {
+		problem.Write(w, r, &problem.Problem{Status: http.StatusBadRequest, Code: problem.CodeMalformedBody, Detail: "The request body could not be read."})
+		return false
+	}
+
+	if v, ok := req.(validator); ok && len(errs) == 0 {
+		v.validate(&errs)
+	}
+
+	return !errs.write(w, r)
+}
+
+// decodeJSON reads a JSON object into req. Fields of the wrong type or that
+// req does not have are reported in errs.
+func decodeJSON(w http.ResponseWriter, r *http.Request, req any, errs *fieldErrors) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(req)
+
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case err == io.EOF:
+		return nil
+	case errors.As(err, &typeErr):
+		errs.add(typeErr.Field, problem.FieldInvalid, "Value must be of type "+typeErr.Type.String()+".")
+		return nil
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		errs.add(field, problem.FieldUnknown, "Field is not recognised.")
+		return nil
+	}
+
+	return err
+}
+
+// decodeForm reads the form values in the body into the fields of req. Query
+// parameters are not read, so that they cannot stand in for the body. Values
+// that cannot be converted are reported in errs, and fields of a type that
+// forms cannot be decoded into return errUnsupportedField.
+func decodeForm(r *http.Request, req any, errs *fieldErrors) error {
+	err := r.ParseMultipartForm(maxFormMemorySize)
+
+	if err != nil && err != http.ErrNotMultipart {
+		return err
+	}
+
+	v := reflect.ValueOf(req).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+
+		if name == "" || name == "-" || !r.PostForm.Has(name) {
+			continue
+		}
+
+		field := v.Field(i)
+		value := r.PostForm.Get(name)
+		kind := field.Kind()
+
+		if kind == reflect.Pointer {
+			kind = field.Type().Elem().Kind()
+		}
+
+		// An empty number is taken as none, as forms cannot send null.
+		if value == "" && kind == reflect.Int64 {
+			continue
+		}
+
+		if field.Kind() == reflect.Pointer {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			field.SetBool(value == "true")
+		case reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+
+			if err != nil {
+				errs.add(name, problem.FieldInvalid, "Value must be a number.")
+				continue
+			}
+
+			field.SetInt(n)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%w: %s", errUnsupportedField, field.Type())
+			}
+
+			values := make([]string, 0)
+
+			if value != "" {
+				values = strings.Split(value, ",")
+			}
+
+			field.Set(reflect.ValueOf(values))
+		case reflect.Interface:
+			if !reflect.TypeOf(value).AssignableTo(field.Type()) {
+				return fmt.Errorf("%w: %s", errUnsupportedField, field.Type())
+			}
+
+			field.Set(reflect.ValueOf(value))
+		default:
+			return fmt.Errorf("%w: %s", errUnsupportedField, field.Type())
+		}
+	}
+
+	return nil
+}
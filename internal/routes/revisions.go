@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+// parseRevisionNumber reads a revision number from the query, or returns def
+// if it is absent.
+func parseRevisionNumber(value string, def, count int) (number int, ok bool) {
+	if value == "" {
+		return def, true
+	}
+
+	number, err := strconv.Atoi(value)
+
+	return number, err == nil && number >= 1 && number <= count
+}
+
+// diffRevisions compares two revisions, defaulting to the most recent edit.
+func diffRevisions(r *http.Request, revisions []api.Revision) (diff api.RevisionDiff, ok bool) {
+	from, ok := parseRevisionNumber(r.URL.Query().Get("from"), max(len(revisions)-1, 1), len(revisions))
+
+	if !ok {
+		return
+	}
+
+	to, ok := parseRevisionNumber(r.URL.Query().Get("to"), len(revisions), len(revisions))
+
+	if !ok {
+		return
+	}
+
+	a, b := revisions[from-1], revisions[to-1]
+
+	diff = api.RevisionDiff{
+		From: a.Number,
+		To:   b.Number,
+		Body: utils.Diff(utils.StripTags(a.Body), utils.StripTags(b.Body)),
+	}
+
+	if a.Title != nil && b.Title != nil {
+		diff.Title = utils.Diff(*a.Title, *b.Title)
+	}
+
+	return
+}
+
+// getRevisionPost returns a post whose revisions can be viewed. Revisions of
+// deleted posts are only available to moderators.
+func getRevisionPost(w http.ResponseWriter, r *http.Request) (post api.Post, ok bool) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	post, err = stores(r).Posts.GetPost(r.Context(), postID)
+
+	if err == store.ErrNotFound || (err == nil && post.Deleted && !auth.HasPostCapability(r, post.ID, auth.PostDeleteAny)) {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	return post, true
+}
+
+// getRevisionComment returns a comment whose revisions can be viewed.
+// Revisions of deleted comments are only available to moderators.
+func getRevisionComment(w http.ResponseWriter, r *http.Request) (comment api.Comment, ok bool) {
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	comment, err = stores(r).Comments.GetPostComment(r.Context(), commentID)
+
+	if err == store.ErrNotFound || (err == nil && comment.Deleted && !auth.HasPostCapability(r, comment.PostID, auth.CommentDeleteAny)) {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	return comment, true
+}
+
+func handleGetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	post, ok := getRevisionPost(w, r)
+
+	if !ok {
+		return
+	}
+
+	revisions, err := stores(r).Posts.GetPostRevisions(r.Context(), int64(post.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func handleGetPostRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	post, ok := getRevisionPost(w, r)
+
+	if !ok {
+		return
+	}
+
+	revisions, err := stores(r).Posts.GetPostRevisions(r.Context(), int64(post.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	diff, ok := diffRevisions(r, revisions)
+
+	if !ok {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(diff)
+}
+
+func handleRestorePostRevision(w http.ResponseWriter, r *http.Request) {
+	post, ok := getRevisionPost(w, r)
+
+	if !ok {
+		return
+	}
+
+	if post.Deleted {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if !auth.IsUser(r, post.Author.ID) && !auth.HasPostCapability(r, post.ID, auth.PostEditAny) {
+		auth.Deny(w, r)
+		return
+	}
+
+	revisions, err := stores(r).Posts.GetPostRevisions(r.Context(), int64(post.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	number, ok := parseRevisionNumber(chi.URLParam(r, "number"), 0, len(revisions))
+
+	if !ok {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	userID, _ := auth.GetUserID(r)
+	revision := revisions[number-1]
+
+	err = stores(r).Posts.UpdatePost(r.Context(), int64(post.ID), int64(userID), *revision.Title, revision.Body, nil)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	post, err = stores(r).Posts.GetPost(r.Context(), int64(post.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = events.Publish(events.PostTopic(post.ID), "post.updated", post)
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	json.NewEncoder(w).Encode(post)
+}
+
+func handleGetPostCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	comment, ok := getRevisionComment(w, r)
+
+	if !ok {
+		return
+	}
+
+	revisions, err := stores(r).Comments.GetPostCommentRevisions(r.Context(), int64(comment.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func handleGetPostCommentRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	comment, ok := getRevisionComment(w, r)
+
+	if !ok {
+		return
+	}
+
+	revisions, err := stores(r).Comments.GetPostCommentRevisions(r.Context(), int64(comment.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	diff, ok := diffRevisions(r, revisions)
+
+	if !ok {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(diff)
+}
+
+func handleRestorePostCommentRevision(w http.ResponseWriter, r *http.Request) {
+	comment, ok := getRevisionComment(w, r)
+
+	if !ok {
+		return
+	}
+
+	if comment.Deleted {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if !auth.IsUser(r, comment.Author.ID) && !auth.HasPostCapability(r, comment.PostID, auth.CommentEditAny) {
+		auth.Deny(w, r)
+		return
+	}
+
+	revisions, err := stores(r).Comments.GetPostCommentRevisions(r.Context(), int64(comment.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	number, ok := parseRevisionNumber(chi.URLParam(r, "number"), 0, len(revisions))
+
+	if !ok {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	userID, _ := auth.GetUserID(r)
+
+	err = stores(r).Comments.UpdatePostComment(r.Context(), int64(comment.ID), int64(userID), revisions[number-1].Body)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	comment, err = stores(r).Comments.GetPostComment(r.Context(), int64(comment.ID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = events.Publish(events.PostTopic(comment.PostID), "comment.updated", comment)
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	json.NewEncoder(w).Encode(comment)
+}
@@ -1,21 +1,74 @@
 package routes
 
 import (
+	"errors"
+	"log"
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
-func APIRoutes() func(r chi.Router) {
+func stores(r *http.Request) store.Store {
+	return store.FromContext(r.Context())
+}
+
+// serverError responds to an error from a store or other dependency. Database
+// queries that timed out are reported as 503 Service Unavailable so that
+// clients know to retry, and writes that conflict with existing rows as 409
+// Conflict. Anything else is logged with the request ID and reported as 500.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrTimeout):
+		w.Header().Set("Retry-After", "1")
+		problem.Write(w, r, &problem.Problem{Status: http.StatusServiceUnavailable, Code: problem.CodeTimeout})
+		return
+	case errors.Is(err, store.ErrConflict):
+		problem.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	log.Printf("[%s] %s %s: %v\n", middleware.GetReqID(r.Context()), r.Method, r.URL.Path, err)
+	problem.Error(w, r, http.StatusInternalServerError)
+}
+
+func APIRoutes(s store.Store) func(r chi.Router) {
 	return func(r chi.Router) {
+		r.Use(store.Middleware(s))
 		r.Use(auth.Verifier())
 		r.Use(auth.Authenticator())
+		r.Use(ratelimit.Limit(ratelimit.GroupAPI))
+
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			problem.Error(w, r, http.StatusNotFound)
+		})
+
+		r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+			problem.Error(w, r, http.StatusMethodNotAllowed)
+		})
 
 		r.Route("/auth", AuthRoutes())
-		r.Route("/me", MeRoutes())
-		r.Route("/users", UsersRoutes())
-		r.Route("/posts", PostsRoutes())
-		r.Route("/comments", CommentsRoutes())
-		r.Route("/reactions", ReactionsRoutes())
-		r.Route("/tags", TagsRoutes())
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RejectSuspended())
+
+			r.Route("/me", MeRoutes())
+			r.Route("/users", UsersRoutes())
+			r.Route("/posts", PostsRoutes())
+			r.Route("/comments", CommentsRoutes())
+			r.Route("/reactions", ReactionsRoutes())
+			r.Route("/tags", TagsRoutes())
+			r.Route("/notifications", NotificationsRoutes())
+			r.Route("/stream", StreamRoutes())
+			r.Route("/search", SearchRoutes())
+			r.Route("/reports", ReportsRoutes())
+			r.Route("/moderation", ModerationRoutes())
+			r.Route("/settings", SettingsRoutes())
+			r.Route("/lockouts", LockoutsRoutes())
+		})
 	}
 }
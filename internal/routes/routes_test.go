@@ -0,0 +1,142 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+	"github.com/themintchoco/cvwo/internal/router"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/store/memory"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test")
+	auth.Configure()
+
+	os.Exit(m.Run())
+}
+
+// testServer serves the API from an in-memory store, which tests can also
+// seed directly.
+type testServer struct {
+	*httptest.Server
+	store store.Store
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	// Limits are kept per user ID and address, which would otherwise carry
+	// over between tests.
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+
+	s := memory.New()
+	srv := httptest.NewServer(router.Setup(s))
+	t.Cleanup(srv.Close)
+
+	return &testServer{Server: srv, store: s}
+}
+
+// testClient keeps the cookies of one user agent.
+type testClient struct {
+	t   *testing.T
+	srv *testServer
+	c   *http.Client
+}
+
+func (s *testServer) client(t *testing.T) *testClient {
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testClient{t: t, srv: s, c: &http.Client{Jar: jar}}
+}
+
+// do sends body, if not nil, as JSON and decodes the response, which may be a
+// problem, into out, if not nil, returning the status code.
+func (c *testClient) do(method, path string, body, out any) int {
+	c.t.Helper()
+
+	var reader io.Reader
+
+	if body != nil {
+		buf, err := json.Marshal(body)
+
+		if err != nil {
+			c.t.Fatal(err)
+		}
+
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.srv.URL+path, reader)
+
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.c.Do(req)
+
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+
+		if err != nil && err != io.EOF {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// expect is do, failing the test unless the response has status.
+func (c *testClient) expect(status int, method, path string, body, out any) {
+	c.t.Helper()
+
+	if got := c.do(method, path, body, out); got != status {
+		c.t.Fatalf("%s %s: got status %d, want %d", method, path, got, status)
+	}
+}
+
+// register signs the client up as a new member, returning its user ID.
+func (c *testClient) register(username string) uint {
+	c.t.Helper()
+
+	var me struct{ ID uint }
+
+	c.expect(http.StatusOK, "POST", "/api/auth/register", map[string]string{
+		"username": username,
+		"password": "password1",
+		"email":    username + "@example.com",
+	}, &me)
+
+	return me.ID
+}
+
+// problemBody is the part of a problem+json response that tests check.
+type problemBody struct {
+	Code   string
+	Errors []struct {
+		Field string
+		Code  string
+	}
+}
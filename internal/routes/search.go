@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/search"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+// maxSearchPage bounds how deep search results can be paged, as each page is
+// found by ranking every result before it, from both posts and comments for
+// type=all.
+const maxSearchPage = 50
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+
+	if err != nil || page < 1 || page > maxSearchPage {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	q, err := search.Parse(r.URL.Query().Get("q"))
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	kind := r.URL.Query().Get("type")
+
+	if kind == "" {
+		kind = "all"
+	}
+
+	var limit, offset int64 = 10, 10 * (page - 1)
+	results := make([]api.SearchResult, 0)
+
+	switch kind {
+	case "posts":
+		results, err = stores(r).Posts.SearchPosts(r.Context(), limit, offset, q)
+	case "comments":
+		results, err = stores(r).Comments.SearchComments(r.Context(), limit, offset, q)
+	case "all":
+		var posts, comments []api.SearchResult
+
+		posts, err = stores(r).Posts.SearchPosts(r.Context(), limit+offset, 0, q)
+
+		if err != nil {
+			break
+		}
+
+		comments, err = stores(r).Comments.SearchComments(r.Context(), limit+offset, 0, q)
+
+		if err != nil {
+			break
+		}
+
+		results = append(posts, comments...)
+
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+
+		results = results[min(offset, int64(len(results))):min(offset+limit, int64(len(results)))]
+	default:
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	highlights := q.Highlights()
+
+	for i, result := range results {
+		if result.Post != nil {
+			results[i].Title = search.Highlight(result.Post.Title, highlights)
+			results[i].Snippet = search.Snippet(utils.StripTags(result.Post.Body), highlights)
+		}
+
+		if result.Comment != nil {
+			results[i].Snippet = search.Snippet(utils.StripTags(result.Comment.Body), highlights)
+		}
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+func SearchRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/", handleSearch)
+	}
+}
@@ -0,0 +1,24 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSearchPages(t *testing.T) {
+	srv := newTestServer(t)
+	seedPosts(t, srv, 12)
+	c := srv.client(t)
+
+	var results []struct{ Title string }
+
+	c.expect(http.StatusOK, "GET", "/api/search/?q=post&page=2", nil, &results)
+
+	if len(results) != 2 {
+		t.Errorf("got %d results on page 2, want 2", len(results))
+	}
+
+	for _, query := range []string{"page=0", "page=51", "type=all&page=1000000"} {
+		c.expect(http.StatusBadRequest, "GET", "/api/search/?q=post&"+query, nil, nil)
+	}
+}
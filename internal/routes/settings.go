@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+)
+
+func handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	twoFactorRoles, err := auth.GetTwoFactorRoles(r.Context())
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		auth.TwoFactorRolesSetting: twoFactorRoles,
+	})
+}
+
+// twoFactorRolesRequest sets the roles that must enable two-factor
+// authentication.
+type twoFactorRolesRequest struct {
+	Value []string `json:"value"`
+}
+
+func (req *twoFactorRolesRequest) validate(errs *fieldErrors) {
+	roles := make([]string, 0)
+
+	for _, role := range req.Value {
+		role = strings.TrimSpace(role)
+
+		if role == "" {
+			continue
+		}
+
+		if role == "member" || !auth.IsRole(role) {
+			errs.add("value", problem.FieldInvalid, "Roles must exist and not include member.")
+			return
+		}
+
+		roles = append(roles, role)
+	}
+
+	req.Value = roles
+}
+
+func handleUpdateTwoFactorRoles(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+
+	var req twoFactorRolesRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	roles := req.Value
+
+	ownRole, err := stores(r).Users.GetUserRole(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	_, enabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	// Prevent admins from locking themselves out of their own capabilities.
+	for _, role := range roles {
+		if role == ownRole && !enabled {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = stores(r).Settings.UpdateSetting(r.Context(), auth.TwoFactorRolesSetting, roles)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func SettingsRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(auth.RequireCapability(auth.SettingsManage))
+
+		r.Get("/", handleGetSettings)
+		r.Patch("/"+auth.TwoFactorRolesSetting, handleUpdateTwoFactorRoles)
+	}
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/events"
+	"github.com/themintchoco/cvwo/internal/problem"
+)
+
+var streamTopicPattern = regexp.MustCompile(`^(post|tag):\d+$`)
+
+// streamHeartbeat is how often a comment is sent to keep streams open, and
+// how often the user of a stream is checked to still be signed in and not
+// suspended.
+var streamHeartbeat = 25 * time.Second
+
+func writeStreamEvent(w http.ResponseWriter, event events.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// streamAllowed reports whether the user of a stream, if any, is still signed
+// in with the same session or token and is not suspended.
+func streamAllowed(r *http.Request) (bool, error) {
+	active, err := auth.StillAuthenticated(r)
+
+	if err != nil || !active {
+		return false, err
+	}
+
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		return true, nil
+	}
+
+	suspended, err := stores(r).Moderation.GetUserSuspended(r.Context(), int64(userID))
+
+	return !suspended, err
+}
+
+func handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		problem.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	var topics []string
+
+	for _, param := range r.URL.Query()["topics"] {
+		for _, topic := range strings.Split(param, ",") {
+			topic = strings.TrimSpace(topic)
+
+			if topic == "notifications" {
+				userID, ok := auth.GetUserID(r)
+
+				if !ok {
+					problem.Error(w, r, http.StatusUnauthorized)
+					return
+				}
+
+				topics = append(topics, events.NotificationsTopic(userID))
+				continue
+			}
+
+			if !streamTopicPattern.MatchString(topic) {
+				problem.Error(w, r, http.StatusBadRequest)
+				return
+			}
+
+			topics = append(topics, topic)
+		}
+	}
+
+	if len(topics) == 0 {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	var resumeFrom uint64
+
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest)
+			return
+		}
+
+		resumeFrom = id
+	}
+
+	if userID, ok := auth.GetUserID(r); ok {
+		suspended, err := stores(r).Moderation.GetUserSuspended(r.Context(), int64(userID))
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		if suspended {
+			problem.Error(w, r, http.StatusForbidden)
+			return
+		}
+	}
+
+	sub, missed, complete := events.Subscribe(topics, resumeFrom)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+
+	for _, event := range missed {
+		writeStreamEvent(w, event)
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			allowed, err := streamAllowed(r)
+
+			if err != nil {
+				log.Printf("[%s] %s %s: %v\n", middleware.GetReqID(r.Context()), r.Method, r.URL.Path, err)
+			}
+
+			// The stream has started, so there is no status left to send.
+			// Closing it lets the client reconnect and be authenticated again.
+			if !allowed {
+				return
+			}
+
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+func StreamRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/", handleStream)
+	}
+}
@@ -0,0 +1,92 @@
+package routes_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/routes"
+)
+
+// openStream subscribes c to its notifications, failing the test unless the
+// stream opens.
+func openStream(t *testing.T, c *testClient) *http.Response {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.srv.URL+"/api/stream/?topics=notifications", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.c.Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { res.Body.Close() })
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+
+	return res
+}
+
+// expectClosed reads the stream until the server closes it, failing the test
+// if it is not closed before the request times out.
+func expectClosed(t *testing.T, res *http.Response) {
+	t.Helper()
+
+	_, err := io.Copy(io.Discard, res.Body)
+
+	if err != nil {
+		t.Fatalf("got %v, want the stream closed", err)
+	}
+}
+
+func TestStreamClosesOnSignOut(t *testing.T) {
+	t.Cleanup(routes.SetStreamHeartbeat(10 * time.Millisecond))
+
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	alice.register("alice")
+
+	res := openStream(t, alice)
+
+	// A heartbeat arrives while the session is active.
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+
+	if err != nil || line != ": heartbeat\n" {
+		t.Fatalf("got %q, %v, want a heartbeat", line, err)
+	}
+
+	alice.expect(http.StatusNoContent, "POST", "/api/auth/logout", nil, nil)
+	expectClosed(t, res)
+}
+
+func TestStreamClosesOnSuspension(t *testing.T) {
+	t.Cleanup(routes.SetStreamHeartbeat(10 * time.Millisecond))
+
+	srv := newTestServer(t)
+	alice := srv.client(t)
+	aliceID := alice.register("alice")
+
+	res := openStream(t, alice)
+
+	_, err := srv.store.Moderation.CreateSuspension(context.Background(), int64(aliceID), 1, nil, "Spam", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectClosed(t, res)
+	alice.expect(http.StatusForbidden, "GET", "/api/stream/?topics=notifications", nil, nil)
+}
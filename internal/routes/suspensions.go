@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+var errInvalidDuration = errors.New("invalid suspension duration")
+
+// parseSuspensionEnd parses a suspension duration such as "72h" into its end
+// time. An empty duration is a permanent suspension and yields nil.
+func parseSuspensionEnd(value string) (endsAt *time.Time, err error) {
+	if value == "" {
+		return
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil || duration <= 0 {
+		return nil, errInvalidDuration
+	}
+
+	end := time.Now().Add(duration)
+	endsAt = &end
+
+	return
+}
+
+func (e *fieldErrors) checkSuspensionDuration(field, duration string) {
+	if _, err := parseSuspensionEnd(duration); err != nil {
+		e.add(field, problem.FieldInvalid, "Duration must be positive, such as 72h.")
+	}
+}
+
+func handleGetUserSuspensions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if !auth.IsUser(r, uint(userID)) && !auth.HasCapability(r, auth.UserSuspend) {
+		auth.Deny(w, r)
+		return
+	}
+
+	suspensions, err := stores(r).Moderation.GetUserSuspensions(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(suspensions)
+}
+
+type createSuspensionRequest struct {
+	Reason string `json:"reason"`
+	// Duration is how long the suspension lasts, or empty if indefinitely.
+	Duration string `json:"duration"`
+}
+
+func (req *createSuspensionRequest) validate(errs *fieldErrors) {
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	if req.Reason == "" {
+		errs.add("reason", problem.FieldRequired, "Reason is required.")
+	}
+
+	errs.checkSuspensionDuration("duration", req.Duration)
+}
+
+func handleCreateUserSuspension(w http.ResponseWriter, r *http.Request) {
+	createdBy, _ := auth.GetUserID(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req createSuspensionRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if auth.IsUser(r, uint(userID)) {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	reason := req.Reason
+	endsAt, _ := parseSuspensionEnd(req.Duration)
+
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if !auth.Outranks(r, user.Role) {
+		auth.Deny(w, r)
+		return
+	}
+
+	suspensionID, err := stores(r).Moderation.CreateSuspension(r.Context(), userID, int64(createdBy), nil, reason, endsAt)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	suspension, err := stores(r).Moderation.GetSuspension(r.Context(), suspensionID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(suspension)
+}
+
+func handleLiftUserSuspension(w http.ResponseWriter, r *http.Request) {
+	liftedBy, _ := auth.GetUserID(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	suspensionID, err := strconv.ParseInt(chi.URLParam(r, "suspensionID"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	suspension, err := stores(r).Moderation.GetSuspension(r.Context(), suspensionID)
+
+	if err == store.ErrNotFound || (err == nil && int64(suspension.UserID) != userID) {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Moderation.LiftSuspension(r.Context(), suspensionID, int64(liftedBy))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,31 +4,31 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/stephenafamo/bob"
-	"github.com/stephenafamo/bob/dialect/mysql"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
 func handleGetTag(w http.ResponseWriter, r *http.Request) {
 	tagID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	tag, err := db.GetTag(tagID)
+	tag, err := stores(r).Tags.GetTag(r.Context(), tagID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -36,54 +36,59 @@ func handleGetTag(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGetTags(w http.ResponseWriter, r *http.Request) {
-	var filter []bob.Expression
-
-	if r.URL.Query().Get("query") != "" {
-		filter = append(filter, mysql.Quote("t", "name").Like(mysql.Arg("%"+r.URL.Query().Get("query")+"%")))
-	}
-
-	tags, err := db.GetTags(5, 0, filter)
+	tags, err := stores(r).Tags.GetTags(r.Context(), store.TagQuery{Limit: 5, Name: r.URL.Query().Get("query")})
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(tags)
 }
 
+type updateTagRequest struct {
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
 func handleUpdateTag(w http.ResponseWriter, r *http.Request) {
 	tagID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	tag, err := db.GetTag(tagID)
+	tag, err := stores(r).Tags.GetTag(r.Context(), tagID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	if !auth.CheckUserID(r, 0) {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.HasTagCapability(r, tag.ID, auth.TagEdit) {
+		auth.Deny(w, r)
+		return
+	}
+
+	var req updateTagRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	tag.Color = r.FormValue("color")
-	tag.Description = r.FormValue("description")
+	tag.Color = req.Color
+	tag.Description = req.Description
 
-	err = db.UpdateTag(tagID, tag.Color, tag.Description)
+	err = stores(r).Tags.UpdateTag(r.Context(), tagID, tag.Color, tag.Description)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -91,21 +96,109 @@ func handleUpdateTag(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGetTrendingTags(w http.ResponseWriter, r *http.Request) {
-	tags, err := db.GetTags(10, 0, []bob.Expression{mysql.Quote("p", "created_at").GTE(mysql.F("DATE_SUB", mysql.F("NOW"), "INTERVAL 1 MONTH"))})
+	since := time.Now().AddDate(0, -1, 0)
+	tags, err := stores(r).Tags.GetTags(r.Context(), store.TagQuery{Limit: 10, Since: &since})
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(tags)
 }
 
+func handleGetTagModerators(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	moderators, err := stores(r).Tags.GetTagModerators(r.Context(), tagID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(moderators)
+}
+
+func handleAddTagModerator(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	_, err = stores(r).Tags.GetTag(r.Context(), tagID)
+
+	if err == nil {
+		_, err = stores(r).Users.GetUserRole(r.Context(), userID)
+	}
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Tags.CreateTagModerator(r.Context(), tagID, userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRemoveTagModerator(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Tags.DeleteTagModerator(r.Context(), tagID, userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func TagsRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/{id:\\d+}", handleGetTag)
 		r.Get("/", handleGetTags)
 		r.Patch("/{id:\\d+}", handleUpdateTag)
 		r.Get("/trending", handleGetTrendingTags)
+		r.Get("/{id:\\d+}/moderators", handleGetTagModerators)
+		r.With(auth.RequireCapability(auth.TagModeratorsManage)).Put("/{id:\\d+}/moderators/{userID:\\d+}", handleAddTagModerator)
+		r.With(auth.RequireCapability(auth.TagModeratorsManage)).Delete("/{id:\\d+}/moderators/{userID:\\d+}", handleRemoveTagModerator)
 	}
 }
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func parseAPITokenScopes(values []string) ([]string, bool) {
+	scopes := []string{auth.ScopeRead}
+
+	for _, scope := range values {
+		switch strings.TrimSpace(scope) {
+		case auth.ScopeRead:
+		case auth.ScopeWrite:
+			scopes = []string{auth.ScopeRead, auth.ScopeWrite}
+		default:
+			return nil, false
+		}
+	}
+
+	return scopes, true
+}
+
+func handleGetMyAPITokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := stores(r).Sessions.GetUserAPITokens(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+type createAPITokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Duration is how long the token is valid for, or empty if it does not
+	// expire.
+	Duration string `json:"duration"`
+}
+
+func (req *createAPITokenRequest) validate(errs *fieldErrors) {
+	req.Name = strings.TrimSpace(req.Name)
+
+	switch {
+	case req.Name == "":
+		errs.add("name", problem.FieldRequired, "Name is required.")
+	case len(req.Name) > 64:
+		errs.add("name", problem.FieldTooLong, "Name must be at most 64 characters.")
+	}
+
+	if _, ok := parseAPITokenScopes(req.Scopes); !ok {
+		errs.add("scopes", problem.FieldInvalid, "Scopes must be read or write.")
+	}
+
+	if req.Duration != "" {
+		if duration, err := time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			errs.add("duration", problem.FieldInvalid, "Duration must be positive, such as 720h.")
+		}
+	}
+}
+
+func handleCreateMyAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	var req createAPITokenRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	name := req.Name
+	scopes, _ := parseAPITokenScopes(req.Scopes)
+
+	var expiresAt *time.Time
+
+	if req.Duration != "" {
+		duration, _ := time.ParseDuration(req.Duration)
+		expires := time.Now().Add(duration)
+		expiresAt = &expires
+	}
+
+	token, tokenHash, err := auth.GenerateAPIToken()
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	tokenID, err := stores(r).Sessions.CreateAPIToken(r.Context(), int64(userID), name, tokenHash, scopes, expiresAt)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.APIToken{
+		ID:        uint(tokenID),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+		Token:     token,
+	})
+}
+
+func handleRevokeMyAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Sessions.RevokeAPIToken(r.Context(), int64(userID), tokenID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/auth"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/ratelimit"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (req *twoFactorCodeRequest) validate(errs *fieldErrors) {
+	if req.Code == "" {
+		errs.add("code", problem.FieldRequired, "Code is required.")
+	}
+}
+
+func handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, challenge, ok := auth.GetTwoFactorChallenge(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	var req twoFactorCodeRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	ip := auth.ClientIP(r)
+
+	wait, err := ratelimit.CheckTwoFactor(ip, userID, challenge)
+
+	if err == ratelimit.ErrChallengeExhausted {
+		auth.ClearTwoFactorChallenge(w)
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		tooManyRequests(w, r, wait)
+		return
+	}
+
+	valid, err := auth.VerifyTwoFactorCode(r.Context(), userID, req.Code)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if !valid {
+		err = ratelimit.TwoFactorFailed(ip, userID, challenge)
+
+		if err == ratelimit.ErrChallengeExhausted {
+			// Make the client start over with the password.
+			auth.ClearTwoFactorChallenge(w)
+		} else if err != nil {
+			serverError(w, r, err)
+			return
+		}
+
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	auth.ClearTwoFactorChallenge(w)
+
+	user, err := stores(r).Users.GetUser(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = ratelimit.TwoFactorSucceeded(userID)
+
+	if err == nil {
+		err = ratelimit.LoginSucceeded(user.Username)
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = auth.SignInUser(w, r, userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Me{ID: userID})
+}
+
+func handleStartTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	_, enabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if enabled {
+		problem.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	user, err := stores(r).Users.GetUser(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Users.UpdateUserTwoFactorSecret(r.Context(), int64(userID), &secret)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.TwoFactorEnrolment{
+		Secret: secret,
+		URI:    auth.TOTPURI(user.Username, secret),
+	})
+}
+
+func replaceRecoveryCodes(w http.ResponseWriter, r *http.Request, userID uint) {
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Users.ReplaceRecoveryCodes(r.Context(), int64(userID), hashes)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]string{"recoveryCodes": codes})
+}
+
+func handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	secret, enabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if secret == nil || enabled {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req twoFactorCodeRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	valid, err := auth.VerifyTwoFactorCode(r.Context(), userID, req.Code)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if !valid {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Users.EnableUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	replaceRecoveryCodes(w, r, userID)
+}
+
+// verifyEnabledTwoFactor checks the code submitted with a request that changes
+// an existing enrolment, writing an error response if it fails.
+func verifyEnabledTwoFactor(w http.ResponseWriter, r *http.Request) (userID uint, ok bool) {
+	userID, ok = auth.GetUserID(r)
+
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return
+	}
+
+	_, enabled, _, err := stores(r).Users.GetUserTwoFactor(r.Context(), int64(userID))
+
+	if err != nil {
+		serverError(w, r, err)
+		return 0, false
+	}
+
+	if !enabled {
+		problem.Error(w, r, http.StatusBadRequest)
+		return 0, false
+	}
+
+	var req twoFactorCodeRequest
+
+	if !decodeRequest(w, r, &req) {
+		return 0, false
+	}
+
+	valid, err := auth.VerifyTwoFactorCode(r.Context(), userID, req.Code)
+
+	if err != nil {
+		serverError(w, r, err)
+		return 0, false
+	}
+
+	if !valid {
+		problem.Error(w, r, http.StatusUnauthorized)
+		return 0, false
+	}
+
+	return
+}
+
+func handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := verifyEnabledTwoFactor(w, r)
+
+	if !ok {
+		return
+	}
+
+	replaceRecoveryCodes(w, r, userID)
+}
+
+func handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := verifyEnabledTwoFactor(w, r)
+
+	if !ok {
+		return
+	}
+
+	err := stores(r).Users.UpdateUserTwoFactorSecret(r.Context(), int64(userID), nil)
+
+	if err == nil {
+		err = stores(r).Users.ReplaceRecoveryCodes(r.Context(), int64(userID), nil)
+	}
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -13,71 +13,107 @@ import (
 	"github.com/google/uuid"
 	"github.com/h2non/bimg"
 	"github.com/themintchoco/cvwo/internal/auth"
-	"github.com/themintchoco/cvwo/internal/db"
+	"github.com/themintchoco/cvwo/internal/problem"
+	"github.com/themintchoco/cvwo/internal/store"
 )
 
 func handleGetUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(user)
 }
 
+// updateUserRequest changes the fields of a user that are not empty.
+type updateUserRequest struct {
+	Password string `json:"password"`
+	Bio      string `json:"bio"`
+}
+
+func (req *updateUserRequest) validate(errs *fieldErrors) {
+	if req.Password != "" {
+		errs.checkPassword("password", req.Password)
+	}
+}
+
 func handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	ok := auth.CheckUserID(r, uint(userID))
+	if !auth.IsUser(r, uint(userID)) && !auth.HasCapability(r, auth.UserEditAny) {
+		auth.Deny(w, r)
+		return
+	}
 
-	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	var req updateUserRequest
+
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
 	var password *string
 	var bio *string
 
-	if r.FormValue("password") != "" {
-		newPassword := r.FormValue("password")
-		password = &newPassword
+	if req.Password != "" {
+		if auth.IsAPIToken(r) {
+			problem.Error(w, r, http.StatusForbidden)
+			return
+		}
+
+		password = &req.Password
 	}
 
-	if r.FormValue("bio") != "" {
-		newBio := r.FormValue("bio")
-		bio = &newBio
+	if req.Bio != "" {
+		bio = &req.Bio
 	}
 
-	err = db.UpdateUser(userID, nil, password, nil, bio)
+	err = stores(r).Users.UpdateUser(r.Context(), userID, nil, password, nil, bio)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	if password != nil {
+		var currentSessionID *int64
+
+		if sessionID, ok := auth.GetSessionID(r); ok && auth.IsUser(r, uint(userID)) {
+			current := int64(sessionID)
+			currentSessionID = &current
+		}
+
+		err = stores(r).Sessions.RevokeUserSessions(r.Context(), userID, currentSessionID)
+
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+	}
+
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -88,33 +124,31 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	ok := auth.CheckUserID(r, uint(userID))
-
-	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, uint(userID)) && !auth.HasCapability(r, auth.UserEditAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	maxSize, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -122,14 +156,14 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	err = r.ParseMultipartForm(maxSize)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
 	f, _, err := r.FormFile("file")
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -139,14 +173,14 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	_, err = io.Copy(&buf, f)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	ftype := http.DetectContentType(buf.Bytes())
 
 	if ftype != "image/jpeg" && ftype != "image/png" {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -154,7 +188,7 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	thumb, err := image.Thumbnail(256)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -165,7 +199,7 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	fout, err := os.Create(filepath)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -174,7 +208,7 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 	_, err = fout.Write(thumb)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -182,16 +216,16 @@ func handleUpdateUserAvatar(w http.ResponseWriter, r *http.Request) {
 		err = os.Remove(fmt.Sprintf("%s/%s", os.Getenv("UPLOADS_DIR"), (*user.Avatar)[9:]))
 
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			serverError(w, r, err)
 			return
 		}
 	}
 
 	user.Avatar = &avatar
-	err = db.UpdateUserAvatar(int64(userID), user.Avatar)
+	err = stores(r).Users.UpdateUserAvatar(r.Context(), int64(userID), user.Avatar)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -202,46 +236,44 @@ func handleDeleteUserAvatar(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	ok := auth.CheckUserID(r, uint(userID))
-
-	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, uint(userID)) && !auth.HasCapability(r, auth.UserEditAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if user.Avatar == nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
 	err = os.Remove(fmt.Sprintf("%s/%s", os.Getenv("UPLOADS_DIR"), (*user.Avatar)[9:]))
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	user.Avatar = nil
-	err = db.UpdateUserAvatar(int64(userID), nil)
+	err = stores(r).Users.UpdateUserAvatar(r.Context(), int64(userID), nil)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -252,42 +284,104 @@ func handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
 		return
 	}
 
-	ok := auth.CheckUserID(r, uint(userID))
-
-	if !ok {
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	if !auth.IsUser(r, uint(userID)) && !auth.HasCapability(r, auth.UserDeleteAny) {
+		auth.Deny(w, r)
 		return
 	}
 
-	user, err := db.GetUser(userID)
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
 
-	if err == db.ErrNotFound {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if user.Deleted {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = stores(r).Users.DeleteUser(r.Context(), userID)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	err = stores(r).Sessions.RevokeUserSessions(r.Context(), userID, nil)
+
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if auth.IsUser(r, uint(userID)) {
+		auth.SignOutUser(w, r)
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+type updateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (req *updateUserRoleRequest) validate(errs *fieldErrors) {
+	if !auth.IsRole(req.Role) {
+		errs.add("role", problem.FieldInvalid, "Role does not exist.")
+	}
+}
+
+func handleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var req updateUserRoleRequest
+
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	role := req.Role
+
+	if auth.IsUser(r, uint(userID)) {
+		problem.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user, err := stores(r).Users.GetUser(r.Context(), userID)
+
+	if err == store.ErrNotFound {
+		problem.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		serverError(w, r, err)
 		return
 	}
 
-	err = db.DeleteUser(userID)
+	err = stores(r).Users.UpdateUser(r.Context(), userID, nil, nil, &role, nil)
 
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	auth.SignOutUser(w)
+	user.Role = role
 
 	json.NewEncoder(w).Encode(user)
 }
@@ -299,5 +393,9 @@ func UsersRoutes() func(r chi.Router) {
 		r.Post("/{id:\\d+}/avatar", handleUpdateUserAvatar)
 		r.Delete("/{id:\\d+}/avatar", handleDeleteUserAvatar)
 		r.Delete("/{id:\\d+}", handleDeleteUser)
+		r.With(auth.RequireCapability(auth.UserRoleAssign)).Post("/{id:\\d+}/role", handleUpdateUserRole)
+		r.Get("/{id:\\d+}/suspensions", handleGetUserSuspensions)
+		r.With(auth.RequireCapability(auth.UserSuspend)).Post("/{id:\\d+}/suspensions", handleCreateUserSuspension)
+		r.With(auth.RequireCapability(auth.UserSuspend)).Delete("/{id:\\d+}/suspensions/{suspensionID:\\d+}", handleLiftUserSuspension)
 	}
 }
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/themintchoco/cvwo/internal/problem"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	minPasswordLength = 8
+	maxTagLength      = 32
+	maxPostTags       = 3
+)
+
+var (
+	usernameChars = regexp.MustCompile("^[a-zA-Z0-9_]*$")
+	tagPattern    = regexp.MustCompile("^[a-z-]+$")
+)
+
+// fieldErrors collects the fields of a request that failed validation, so that
+// they can be reported together.
+type fieldErrors []problem.FieldError
+
+func (e *fieldErrors) add(field, code, message string) {
+	*e = append(*e, problem.FieldError{Field: field, Code: code, Message: message})
+}
+
+func (e *fieldErrors) checkUsername(field, username string) {
+	switch {
+	case username == "":
+		e.add(field, problem.FieldRequired, "Username is required.")
+	case len(username) < minUsernameLength:
+		e.add(field, problem.FieldTooShort, "Username must be at least 3 characters.")
+	case len(username) > maxUsernameLength:
+		e.add(field, problem.FieldTooLong, "Username must be at most 32 characters.")
+	case !usernameChars.MatchString(username):
+		e.add(field, problem.FieldInvalid, "Username may only contain letters, digits and underscores.")
+	}
+}
+
+func (e *fieldErrors) checkPassword(field, password string) {
+	if len(password) < minPasswordLength {
+		e.add(field, problem.FieldTooShort, "Password must be at least 8 characters.")
+	}
+}
+
+// checkEmail returns email normalised by parseEmail.
+func (e *fieldErrors) checkEmail(field, email string) string {
+	email, ok := parseEmail(email)
+
+	if !ok {
+		e.add(field, problem.FieldInvalid, "Email address is invalid.")
+	}
+
+	return email
+}
+
+// checkTags returns the distinct tag names in values, ignoring blank ones.
+func (e *fieldErrors) checkTags(field string, values []string) []string {
+	tags := make([]string, 0)
+
+	for _, tag := range values {
+		tag = strings.TrimSpace(tag)
+
+		switch {
+		case tag == "" || slices.Contains(tags, tag):
+			continue
+		case len(tag) > maxTagLength || !tagPattern.MatchString(tag):
+			e.add(field, problem.FieldInvalid, "Tags may only contain lowercase letters and hyphens, and be at most 32 characters.")
+			return tags
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > maxPostTags {
+		e.add(field, problem.FieldTooLong, "A post can have at most 3 tags.")
+	}
+
+	return tags
+}
+
+// write responds with the errors, if there are any, and reports whether it
+// did.
+func (e fieldErrors) write(w http.ResponseWriter, r *http.Request) bool {
+	if len(e) == 0 {
+		return false
+	}
+
+	problem.Write(w, r, problem.Invalid(e...))
+	return true
+}
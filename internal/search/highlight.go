@@ -0,0 +1,103 @@
+package search
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const snippetRadius = 80
+
+func highlightPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, 0, len(terms))
+
+	for _, term := range terms {
+		words := strings.Fields(term)
+
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+
+		quoted = append(quoted, strings.Join(words, `\W+`))
+	}
+
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)`)
+}
+
+func mark(text string, pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return html.EscapeString(text)
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+// Highlight escapes text for HTML and wraps every occurrence of the terms in
+// <mark> tags.
+func Highlight(text string, terms []string) string {
+	return mark(text, highlightPattern(terms))
+}
+
+// Snippet returns an escaped excerpt of text centred on the first matching
+// term, with matches wrapped in <mark> tags.
+func Snippet(text string, terms []string) string {
+	pattern := highlightPattern(terms)
+	start, end := 0, min(len(text), 2*snippetRadius)
+
+	if pattern != nil {
+		if loc := pattern.FindStringIndex(text); loc != nil {
+			start = max(0, loc[0]-snippetRadius)
+			end = min(len(text), loc[1]+snippetRadius)
+		}
+	}
+
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	if start > 0 {
+		if i := strings.IndexByte(text[start:end], ' '); i >= 0 {
+			start += i + 1
+		}
+	}
+
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i >= 0 {
+			end = start + i
+		}
+	}
+
+	snippet := mark(text[start:end], pattern)
+
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+
+	if end < len(text) {
+		snippet += "…"
+	}
+
+	return snippet
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmptyQuery   = errors.New("search query is empty")
+	ErrInvalidDate  = errors.New("invalid date, expected YYYY-MM-DD")
+	tokenPattern    = regexp.MustCompile(`-?"[^"]*"?|\S+`)
+	nonWordPattern  = regexp.MustCompile(`[^\p{L}\p{N}_']+`)
+	filterDateForms = []string{"2006-01-02", time.RFC3339}
+)
+
+type Query struct {
+	Terms    []string
+	Phrases  []string
+	Excluded []string
+	Tag      string
+	User     string
+	Before   *time.Time
+	After    *time.Time
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(nonWordPattern.ReplaceAllString(text, " ")), " ")
+}
+
+func parseDate(value string) (*time.Time, error) {
+	for _, layout := range filterDateForms {
+		t, err := time.Parse(layout, value)
+
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, ErrInvalidDate
+}
+
+func Parse(raw string) (q Query, err error) {
+	for _, token := range tokenPattern.FindAllString(raw, -1) {
+		excluded := strings.HasPrefix(token, "-") && len(token) > 1
+		body := token
+
+		if excluded {
+			body = token[1:]
+		}
+
+		if strings.HasPrefix(body, `"`) {
+			phrase := normalize(strings.Trim(body, `"`))
+
+			if phrase == "" {
+				continue
+			}
+
+			if excluded {
+				q.Excluded = append(q.Excluded, `"`+phrase+`"`)
+			} else {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+
+			continue
+		}
+
+		if key, value, ok := strings.Cut(body, ":"); ok && !excluded && value != "" {
+			switch strings.ToLower(key) {
+			case "tag":
+				q.Tag = strings.ToLower(value)
+				continue
+			case "user":
+				q.User = value
+				continue
+			case "before":
+				q.Before, err = parseDate(value)
+
+				if err != nil {
+					return
+				}
+
+				continue
+			case "after":
+				q.After, err = parseDate(value)
+
+				if err != nil {
+					return
+				}
+
+				continue
+			}
+		}
+
+		for _, word := range strings.Fields(normalize(body)) {
+			if excluded {
+				q.Excluded = append(q.Excluded, word)
+			} else {
+				q.Terms = append(q.Terms, word)
+			}
+		}
+	}
+
+	if len(q.Terms) == 0 && len(q.Phrases) == 0 && q.Tag == "" && q.User == "" && q.Before == nil && q.After == nil {
+		err = ErrEmptyQuery
+	}
+
+	return
+}
+
+func (q Query) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// Boolean renders the text portion of the query in MySQL's boolean full-text
+// syntax, where every term and phrase is required and exclusions are negated.
+func (q Query) Boolean() string {
+	var parts []string
+
+	for _, term := range q.Terms {
+		parts = append(parts, "+"+term+"*")
+	}
+
+	for _, phrase := range q.Phrases {
+		parts = append(parts, `+"`+phrase+`"`)
+	}
+
+	for _, excluded := range q.Excluded {
+		parts = append(parts, "-"+excluded)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// TSQuery renders the text portion of the query in PostgreSQL's tsquery
+// syntax, with the same meaning as Boolean.
+func (q Query) TSQuery() string {
+	var parts []string
+
+	for _, term := range q.Terms {
+		parts = append(parts, lexeme(term)+":*")
+	}
+
+	for _, phrase := range q.Phrases {
+		parts = append(parts, tsPhrase(phrase))
+	}
+
+	for _, excluded := range q.Excluded {
+		if strings.HasPrefix(excluded, `"`) {
+			parts = append(parts, "!"+tsPhrase(strings.Trim(excluded, `"`)))
+		} else {
+			parts = append(parts, "!"+lexeme(excluded))
+		}
+	}
+
+	return strings.Join(parts, " & ")
+}
+
+func lexeme(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", "''") + "'"
+}
+
+func tsPhrase(phrase string) string {
+	words := strings.Fields(phrase)
+
+	for i, word := range words {
+		words[i] = lexeme(word)
+	}
+
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
+
+// Exclusions renders only the excluded terms, for filtering queries that have
+// no positive text to match against.
+func (q Query) Exclusions() string {
+	return strings.Join(q.Excluded, " ")
+}
+
+func (q Query) Highlights() []string {
+	return append(append([]string{}, q.Phrases...), q.Terms...)
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last item of a page, so that the next page can start right
+// after it however many items have been added since. It holds the value the
+// list is sorted by, a time, a count or a score depending on Sort, and the ID
+// of the item to break ties.
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Time  time.Time `json:"t"`
+	Count uint      `json:"n"`
+	Score float64   `json:"f,omitempty"`
+	ID    uint      `json:"i"`
+}
+
+// PostCursor returns the cursor of a post in a list sorted by sort.
+func PostCursor(sort string, post api.Post) Cursor {
+	cursor := Cursor{Sort: PostSort(sort), ID: post.ID}
+
+	switch cursor.Sort {
+	case PostSortPopular, PostSortTop:
+		cursor.Count = post.ReactionCount
+	case PostSortReplies:
+		cursor.Count = post.CommentCount
+	case PostSortHot:
+		cursor.Score = post.HotScore
+	default:
+		cursor.Time = post.CreatedAt
+	}
+
+	return cursor
+}
+
+// CommentCursor returns the cursor of a comment in a list of comments, which
+// are sorted newest first.
+func CommentCursor(comment api.Comment) Cursor {
+	return Cursor{Sort: PostSortNew, Time: comment.CreatedAt, ID: comment.ID}
+}
+
+// ParseCursor decodes a cursor encoded by String.
+func ParseCursor(value string) (cursor Cursor, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil || json.Unmarshal(raw, &cursor) != nil || cursor.Sort == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return
+}
+
+// String encodes the cursor as an opaque token that can be used in a URL.
+func (c Cursor) String() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
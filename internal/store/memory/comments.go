@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/search"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+func (s *state) commentView(c comment) api.Comment {
+	v := api.Comment{
+		Body:          c.body,
+		Author:        s.author(c.userID),
+		CreatedAt:     c.createdAt,
+		UpdatedAt:     c.updatedAt,
+		Edited:        c.editedAt != nil,
+		EditedAt:      c.editedAt,
+		RevisionCount: uint(len(c.revisions) + 1),
+	}
+	v.ID = uint(c.id)
+	v.PostID = uint(c.postID)
+	v.Depth = c.depth
+	v.Deleted = c.deletedAt != nil
+
+	if c.parentID != nil {
+		v.ParentID = ptr(uint(*c.parentID))
+	}
+
+	return v
+}
+
+func (s *state) hasReplies(commentID int64) bool {
+	for _, c := range s.comments {
+		if c.parentID != nil && *c.parentID == commentID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *memoryStore) CreatePostComment(ctx context.Context, userID, postID int64, parentID *int64, depth uint, body string) (commentID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+	commentID = s.nextID("comments")
+	s.comments[commentID] = comment{id: commentID, userID: userID, postID: postID, parentID: parentID, depth: depth, body: body, createdAt: t, updatedAt: t}
+
+	return
+}
+
+func (m *memoryStore) GetPostComments(ctx context.Context, q store.CommentQuery) (comments []api.Comment, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	var matches []comment
+
+	for _, c := range s.comments {
+		if q.Threaded {
+			if c.deletedAt != nil && !s.hasReplies(c.id) {
+				continue
+			}
+
+			if (q.ParentID == nil) != (c.parentID == nil) || q.ParentID != nil && *q.ParentID != *c.parentID {
+				continue
+			}
+		} else if c.deletedAt != nil {
+			continue
+		}
+
+		if q.PostID != nil && c.postID != *q.PostID {
+			continue
+		}
+
+		if q.Author != "" && !sameText(s.users[c.userID].username, q.Author) {
+			continue
+		}
+
+		matches = append(matches, c)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].createdAt.Equal(matches[j].createdAt) {
+			return matches[i].createdAt.After(matches[j].createdAt)
+		}
+
+		return matches[i].id < matches[j].id
+	})
+
+	offset := q.Offset
+
+	if q.After != nil {
+		if q.After.Sort != store.PostSortNew {
+			err = store.ErrInvalidCursor
+			return
+		}
+
+		offset = int64(sort.Search(len(matches), func(i int) bool {
+			t := matches[i].createdAt
+			return t.Before(q.After.Time) || t.Equal(q.After.Time) && matches[i].id > int64(q.After.ID)
+		}))
+	}
+
+	comments = make([]api.Comment, 0)
+
+	for _, c := range page(matches, q.Limit, offset) {
+		comments = append(comments, s.commentView(c))
+	}
+
+	return
+}
+
+func (m *memoryStore) GetPostComment(ctx context.Context, commentID int64) (c api.Comment, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	stored, ok := s.comments[commentID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return s.commentView(stored), nil
+}
+
+func (m *memoryStore) GetPostCommentReplies(ctx context.Context, parentIDs []int64) (comments []api.Comment, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	var matches []comment
+
+	for _, c := range s.comments {
+		if c.parentID != nil && slices.Contains(parentIDs, *c.parentID) {
+			matches = append(matches, c)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].createdAt.Equal(matches[j].createdAt) {
+			return matches[i].createdAt.Before(matches[j].createdAt)
+		}
+
+		return matches[i].id < matches[j].id
+	})
+
+	comments = make([]api.Comment, 0, len(matches))
+
+	for _, c := range matches {
+		comments = append(comments, s.commentView(c))
+	}
+
+	return
+}
+
+func (m *memoryStore) UpdatePostComment(ctx context.Context, commentID, editorID int64, body string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	c, ok := s.comments[commentID]
+
+	if !ok || c.deletedAt != nil || c.body == body {
+		return
+	}
+
+	previous := revision{body: c.body, editorID: c.userID, createdAt: c.createdAt}
+
+	if c.editedAt != nil {
+		previous.editorID, previous.createdAt = *c.editedBy, *c.editedAt
+	}
+
+	t := now()
+	c.revisions = append(slices.Clip(c.revisions), previous)
+	c.body = body
+	c.editedAt, c.editedBy, c.updatedAt = &t, &editorID, t
+	s.comments[commentID] = c
+
+	return
+}
+
+func (m *memoryStore) GetPostCommentRevisions(ctx context.Context, commentID int64) (revisions []api.Revision, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	c, ok := s.comments[commentID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	current := revision{body: c.body, editorID: c.userID, createdAt: c.createdAt}
+
+	if c.editedAt != nil {
+		current.editorID, current.createdAt = *c.editedBy, *c.editedAt
+	}
+
+	return s.revisions(c.revisions, current), nil
+}
+
+func (m *memoryStore) DeletePostComment(ctx context.Context, commentID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if c, ok := s.comments[commentID]; ok && c.deletedAt == nil {
+		c.deletedAt = ptr(now())
+		s.comments[commentID] = c
+	}
+
+	return
+}
+
+func (m *memoryStore) SearchComments(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	results = make([]api.SearchResult, 0)
+
+	for _, c := range s.comments {
+		p := s.posts[c.postID]
+
+		if c.deletedAt != nil || p.deletedAt != nil || !s.matchFilters(q, p, c.userID, c.createdAt) {
+			continue
+		}
+
+		if score, ok := matchText(utils.StripTags(c.body), q); ok {
+			v := s.commentView(c)
+			results = append(results, api.SearchResult{Type: "comment", Score: score, Comment: &v})
+		}
+	}
+
+	sortResults(results)
+
+	return page(results, limit, offset), nil
+}
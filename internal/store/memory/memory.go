@@ -0,0 +1,316 @@
+// Package memory implements the stores in process memory, so that the API can
+// be exercised without a database. Data is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+// ErrDuplicate is returned where the database would reject a row for
+// violating a unique key, as store.ErrConflict like the db package.
+var ErrDuplicate = store.ErrConflict
+
+type user struct {
+	id              int64
+	username        string
+	email           *string
+	emailVerifiedAt *time.Time
+	password        string
+	role            string
+	bio             *string
+	avatar          *string
+	prefs           map[string]any
+	createdAt       time.Time
+	deletedAt       *time.Time
+	totpSecret      *string
+	totpEnabledAt   *time.Time
+	totpLastStep    *int64
+}
+
+type revision struct {
+	title     *string
+	body      string
+	editorID  int64
+	createdAt time.Time
+}
+
+type post struct {
+	id        int64
+	userID    int64
+	title     string
+	body      string
+	tagIDs    []int64
+	revisions []revision
+	hotScore  float64
+	createdAt time.Time
+	updatedAt time.Time
+	editedAt  *time.Time
+	editedBy  *int64
+	deletedAt *time.Time
+}
+
+type comment struct {
+	id        int64
+	userID    int64
+	postID    int64
+	parentID  *int64
+	depth     uint
+	body      string
+	revisions []revision
+	createdAt time.Time
+	updatedAt time.Time
+	editedAt  *time.Time
+	editedBy  *int64
+	deletedAt *time.Time
+}
+
+type reaction struct {
+	id   int64
+	name string
+	kind string
+}
+
+// reactionKey identifies the reaction of a user to a post or comment.
+type reactionKey struct {
+	userID   int64
+	targetID int64
+}
+
+type moderatorKey struct {
+	tagID  int64
+	userID int64
+}
+
+type session struct {
+	id                int64
+	userID            int64
+	tokenHash         string
+	previousTokenHash *string
+	userAgent         string
+	ip                string
+	createdAt         time.Time
+	lastSeenAt        time.Time
+	expiresAt         time.Time
+	rotatedAt         *time.Time
+	revokedAt         *time.Time
+}
+
+type userToken struct {
+	userID    int64
+	purpose   string
+	tokenHash string
+	email     string
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+type apiToken struct {
+	api.APIToken
+	userID    int64
+	tokenHash string
+	revokedAt *time.Time
+}
+
+type recoveryCode struct {
+	userID   int64
+	codeHash string
+	usedAt   *time.Time
+}
+
+type identity struct {
+	api.Identity
+	userID  int64
+	subject string
+}
+
+type notification struct {
+	id        int64
+	userID    int64
+	actorID   int64
+	kind      string
+	postID    int64
+	commentID *int64
+	actors    []int64
+	readAt    *time.Time
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// state holds every table. Records are stored by value and slice or map
+// fields are replaced rather than modified in place, so that a shallow copy
+// of each table is enough to snapshot it for a transaction.
+type state struct {
+	lastID map[string]int64
+
+	users            map[int64]user
+	posts            map[int64]post
+	comments         map[int64]comment
+	tags             map[int64]api.Tag
+	tagModerators    map[moderatorKey]bool
+	reactions        []reaction
+	postReactions    map[reactionKey]int64
+	commentReactions map[reactionKey]int64
+	sessions         map[int64]session
+	userTokens       []userToken
+	apiTokens        map[int64]apiToken
+	recoveryCodes    []recoveryCode
+	identities       map[int64]identity
+	notifications    map[int64]notification
+	reports          map[int64]api.Report
+	warnings         map[int64]api.Warning
+	suspensions      map[int64]api.Suspension
+	settings         map[string][]byte
+}
+
+func (s *state) clone() *state {
+	return &state{
+		lastID:           maps.Clone(s.lastID),
+		users:            maps.Clone(s.users),
+		posts:            maps.Clone(s.posts),
+		comments:         maps.Clone(s.comments),
+		tags:             maps.Clone(s.tags),
+		tagModerators:    maps.Clone(s.tagModerators),
+		reactions:        slices.Clone(s.reactions),
+		postReactions:    maps.Clone(s.postReactions),
+		commentReactions: maps.Clone(s.commentReactions),
+		sessions:         maps.Clone(s.sessions),
+		userTokens:       slices.Clone(s.userTokens),
+		apiTokens:        maps.Clone(s.apiTokens),
+		recoveryCodes:    slices.Clone(s.recoveryCodes),
+		identities:       maps.Clone(s.identities),
+		notifications:    maps.Clone(s.notifications),
+		reports:          maps.Clone(s.reports),
+		warnings:         maps.Clone(s.warnings),
+		suspensions:      maps.Clone(s.suspensions),
+		settings:         maps.Clone(s.settings),
+	}
+}
+
+// nextID returns the next auto-increment value for table.
+func (s *state) nextID(table string) int64 {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+type memoryStore struct {
+	mu sync.Mutex
+	s  *state
+}
+
+type txContextKey struct{}
+
+// New returns stores holding the same seed data as a freshly migrated
+// database: the reactions, the admin user with password admin123, and the
+// default settings.
+func New() store.Store {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	m := &memoryStore{s: &state{
+		lastID: map[string]int64{"users": 1},
+		users: map[int64]user{
+			1: {
+				id:        1,
+				username:  "admin",
+				password:  "$2a$10$DlIl8WyWB8OKxEAzAdMq4eWKy9PLshJE0pdDhBItlRdqZvtdKgwyO",
+				role:      "admin",
+				prefs:     map[string]any{},
+				createdAt: createdAt,
+			},
+		},
+		posts:            map[int64]post{},
+		comments:         map[int64]comment{},
+		tags:             map[int64]api.Tag{},
+		tagModerators:    map[moderatorKey]bool{},
+		postReactions:    map[reactionKey]int64{},
+		commentReactions: map[reactionKey]int64{},
+		sessions:         map[int64]session{},
+		apiTokens:        map[int64]apiToken{},
+		identities:       map[int64]identity{},
+		notifications:    map[int64]notification{},
+		reports:          map[int64]api.Report{},
+		warnings:         map[int64]api.Warning{},
+		suspensions:      map[int64]api.Suspension{},
+		settings:         map[string][]byte{"twoFactorRoles": []byte("[]")},
+	}}
+
+	for i, name := range []string{"Laugh", "Love", "Wow", "Think", "Sus", "Cry", "Angry"} {
+		m.s.reactions = append(m.s.reactions, reaction{id: int64(i + 1), name: name, kind: "post"})
+	}
+
+	m.s.reactions = append(m.s.reactions,
+		reaction{id: 8, name: "Upvote", kind: "comment"},
+		reaction{id: 9, name: "Downvote", kind: "comment"})
+
+	return store.Store{
+		Users:         m,
+		Posts:         m,
+		Comments:      m,
+		Tags:          m,
+		Reactions:     m,
+		Sessions:      m,
+		Notifications: m,
+		Moderation:    m,
+		Settings:      m,
+		WithTx:        m.WithTx,
+	}
+}
+
+// lock acquires the store for a single call, unless ctx belongs to a
+// transaction that already holds it.
+func (m *memoryStore) lock(ctx context.Context) (*state, func()) {
+	if ctx.Value(txContextKey{}) == m {
+		return m.s, func() {}
+	}
+
+	m.mu.Lock()
+	return m.s, m.mu.Unlock
+}
+
+// WithTx holds the store for the duration of fn, so transactions are
+// serialized, and restores a snapshot taken beforehand if fn fails.
+func (m *memoryStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if ctx.Value(txContextKey{}) == m {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.s.clone()
+	err = fn(context.WithValue(ctx, txContextKey{}, m))
+
+	if err != nil {
+		m.s = snapshot
+	}
+
+	return
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// page returns the items in [offset, offset+limit).
+func page[T any](items []T, limit, offset int64) []T {
+	start := min(max(offset, 0), int64(len(items)))
+	end := min(start+max(limit, 0), int64(len(items)))
+
+	return append(make([]T, 0, end-start), items[start:end]...)
+}
+
+// sameText compares the way the database collation does, ignoring case.
+func sameText(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
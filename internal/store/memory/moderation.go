@@ -0,0 +1,304 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func optionalID(id *int64) *uint {
+	if id == nil {
+		return nil
+	}
+
+	return ptr(uint(*id))
+}
+
+func (m *memoryStore) CreateReport(ctx context.Context, reporterID int64, targetType string, targetID int64, reason, note string) (reportID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+	reportID = s.nextID("reports")
+	s.reports[reportID] = api.Report{
+		ID:         uint(reportID),
+		ReporterID: uint(reporterID),
+		TargetType: targetType,
+		TargetID:   uint(targetID),
+		Reason:     reason,
+		Note:       note,
+		Status:     "open",
+		CreatedAt:  t,
+		UpdatedAt:  t,
+	}
+
+	return
+}
+
+func (m *memoryStore) GetReports(ctx context.Context, q store.ReportQuery) (reports []api.Report, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	reports = make([]api.Report, 0)
+
+	for _, report := range s.reports {
+		if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, report.Status) {
+			continue
+		}
+
+		if q.TargetType != "" && report.TargetType != q.TargetType {
+			continue
+		}
+
+		if q.TargetID != nil && int64(report.TargetID) != *q.TargetID {
+			continue
+		}
+
+		if q.Reason != "" && report.Reason != q.Reason {
+			continue
+		}
+
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].CreatedAt.Equal(reports[j].CreatedAt) {
+			return reports[i].CreatedAt.After(reports[j].CreatedAt)
+		}
+
+		return reports[i].ID > reports[j].ID
+	})
+
+	return page(reports, q.Limit, q.Offset), nil
+}
+
+func (m *memoryStore) GetReport(ctx context.Context, reportID int64) (report api.Report, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	report, ok := s.reports[reportID]
+
+	if !ok {
+		err = store.ErrNotFound
+	}
+
+	return
+}
+
+func (m *memoryStore) ClaimReport(ctx context.Context, reportID, assigneeID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if report, ok := s.reports[reportID]; ok && report.Status != "resolved" {
+		report.Status = "claimed"
+		report.AssigneeID = ptr(uint(assigneeID))
+		report.UpdatedAt = now()
+		s.reports[reportID] = report
+	}
+
+	return
+}
+
+func (m *memoryStore) ResolveReport(ctx context.Context, reportID, resolvedBy int64, resolution, note string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	report, ok := s.reports[reportID]
+
+	if !ok || report.Status == "resolved" {
+		return store.ErrNotFound
+	}
+
+	t := now()
+	report.Status = "resolved"
+	report.Resolution = &resolution
+	report.ResolutionNote = &note
+	report.ResolvedBy = ptr(uint(resolvedBy))
+	report.ResolvedAt = &t
+	report.UpdatedAt = t
+	s.reports[reportID] = report
+
+	return
+}
+
+func (m *memoryStore) CreateWarning(ctx context.Context, userID, createdBy int64, reportID *int64, reason string) (warningID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	warningID = s.nextID("warnings")
+	s.warnings[warningID] = api.Warning{
+		ID:        uint(warningID),
+		UserID:    uint(userID),
+		ReportID:  optionalID(reportID),
+		Reason:    reason,
+		CreatedBy: uint(createdBy),
+		CreatedAt: now(),
+	}
+
+	return
+}
+
+func (m *memoryStore) GetUserWarnings(ctx context.Context, userID int64) (warnings []api.Warning, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	warnings = make([]api.Warning, 0)
+
+	for _, warning := range s.warnings {
+		if int64(warning.UserID) == userID {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].ID > warnings[j].ID
+	})
+
+	return
+}
+
+func (m *memoryStore) CreateSuspension(ctx context.Context, userID, createdBy int64, reportID *int64, reason string, endsAt *time.Time) (suspensionID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	suspensionID = s.nextID("suspensions")
+	s.suspensions[suspensionID] = api.Suspension{
+		ID:        uint(suspensionID),
+		UserID:    uint(userID),
+		ReportID:  optionalID(reportID),
+		Reason:    reason,
+		EndsAt:    endsAt,
+		CreatedBy: uint(createdBy),
+		CreatedAt: now(),
+	}
+
+	return
+}
+
+// withActive sets whether a suspension is in effect.
+func withActive(suspension api.Suspension) api.Suspension {
+	suspension.Active = suspension.LiftedAt == nil && (suspension.EndsAt == nil || suspension.EndsAt.After(now()))
+	return suspension
+}
+
+// activeSuspensions returns the active suspensions of a user, the one that
+// ends last first.
+func (s *state) activeSuspensions(userID int64) (suspensions []api.Suspension) {
+	for _, suspension := range s.suspensions {
+		if suspension = withActive(suspension); int64(suspension.UserID) == userID && suspension.Active {
+			suspensions = append(suspensions, suspension)
+		}
+	}
+
+	sort.Slice(suspensions, func(i, j int) bool {
+		a, b := suspensions[i].EndsAt, suspensions[j].EndsAt
+
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+
+		return a.After(*b)
+	})
+
+	return
+}
+
+func (m *memoryStore) GetUserSuspended(ctx context.Context, userID int64) (suspended bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return len(s.activeSuspensions(userID)) > 0, nil
+}
+
+func (m *memoryStore) GetUserSuspensions(ctx context.Context, userID int64) (suspensions []api.Suspension, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	suspensions = make([]api.Suspension, 0)
+
+	for _, suspension := range s.suspensions {
+		if int64(suspension.UserID) == userID {
+			suspensions = append(suspensions, withActive(suspension))
+		}
+	}
+
+	sort.Slice(suspensions, func(i, j int) bool {
+		return suspensions[i].ID > suspensions[j].ID
+	})
+
+	return
+}
+
+func (m *memoryStore) GetUserActiveSuspension(ctx context.Context, userID int64) (suspension api.Suspension, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	suspensions := s.activeSuspensions(userID)
+
+	if len(suspensions) == 0 {
+		err = store.ErrNotFound
+		return
+	}
+
+	return suspensions[0], nil
+}
+
+func (m *memoryStore) GetSuspension(ctx context.Context, suspensionID int64) (suspension api.Suspension, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	suspension, ok := s.suspensions[suspensionID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return withActive(suspension), nil
+}
+
+func (m *memoryStore) LiftSuspension(ctx context.Context, suspensionID, liftedBy int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if suspension, ok := s.suspensions[suspensionID]; ok && suspension.LiftedAt == nil {
+		suspension.LiftedAt = ptr(now())
+		suspension.LiftedBy = ptr(uint(liftedBy))
+		s.suspensions[suspensionID] = suspension
+	}
+
+	return
+}
+
+func (m *memoryStore) GetSetting(ctx context.Context, key string, value any) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	raw, ok := s.settings[key]
+
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	return json.Unmarshal(raw, value)
+}
+
+func (m *memoryStore) UpdateSetting(ctx context.Context, key string, value any) (err error) {
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return
+	}
+
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	s.settings[key] = raw
+
+	return
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func (s *state) addNotificationActor(notificationID, actorID int64) {
+	n, ok := s.notifications[notificationID]
+
+	if !ok {
+		return
+	}
+
+	if !slices.Contains(n.actors, actorID) {
+		n.actors = append(slices.Clip(n.actors), actorID)
+	}
+
+	n.actorID = actorID
+	n.updatedAt = now()
+	s.notifications[notificationID] = n
+}
+
+func (m *memoryStore) CreateNotifications(ctx context.Context, userIDs []int64, actorID int64, kind string, postID int64, commentID *int64, coalesce bool) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, userID := range userIDs {
+		var notificationID int64
+
+		if coalesce {
+			notificationID = s.unreadNotificationID(userID, kind, postID, commentID)
+		}
+
+		if notificationID == 0 {
+			t := now()
+			notificationID = s.nextID("notifications")
+			s.notifications[notificationID] = notification{id: notificationID, userID: userID, actorID: actorID, kind: kind, postID: postID, commentID: commentID, createdAt: t, updatedAt: t}
+		}
+
+		s.addNotificationActor(notificationID, actorID)
+	}
+
+	return
+}
+
+// unreadNotificationID returns the latest unread notification of a user of
+// kind about a post and comment, or 0 if there is none.
+func (s *state) unreadNotificationID(userID int64, kind string, postID int64, commentID *int64) (notificationID int64) {
+	for _, n := range s.notifications {
+		if n.userID != userID || n.kind != kind || n.postID != postID || n.readAt != nil {
+			continue
+		}
+
+		if (n.commentID == nil) != (commentID == nil) || commentID != nil && *n.commentID != *commentID {
+			continue
+		}
+
+		notificationID = max(notificationID, n.id)
+	}
+
+	return
+}
+
+func (m *memoryStore) GetNotifications(ctx context.Context, userID, limit, offset int64, unreadOnly bool) (notifications []api.Notification, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	var matches []notification
+
+	for _, n := range s.notifications {
+		if n.userID == userID && (!unreadOnly || n.readAt == nil) {
+			matches = append(matches, n)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].updatedAt.Equal(matches[j].updatedAt) {
+			return matches[i].updatedAt.After(matches[j].updatedAt)
+		}
+
+		return matches[i].id > matches[j].id
+	})
+
+	notifications = make([]api.Notification, 0)
+
+	for _, n := range page(matches, limit, offset) {
+		v := api.Notification{
+			ID:         uint(n.id),
+			Type:       n.kind,
+			PostID:     uint(n.postID),
+			Actor:      s.author(n.actorID),
+			ActorCount: uint(len(n.actors)),
+			Read:       n.readAt != nil,
+			CreatedAt:  n.createdAt,
+			UpdatedAt:  n.updatedAt,
+		}
+
+		if n.commentID != nil {
+			v.CommentID = ptr(uint(*n.commentID))
+		}
+
+		notifications = append(notifications, v)
+	}
+
+	return
+}
+
+func (m *memoryStore) GetNotificationOwner(ctx context.Context, notificationID int64) (userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	n, ok := s.notifications[notificationID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return n.userID, nil
+}
+
+func (m *memoryStore) GetUnreadNotificationCount(ctx context.Context, userID int64) (count uint, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, n := range s.notifications {
+		if n.userID == userID && n.readAt == nil {
+			count++
+		}
+	}
+
+	return
+}
+
+func (m *memoryStore) GetUnreadNotificationCounts(ctx context.Context, userIDs []int64) (counts map[int64]uint, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	counts = make(map[int64]uint, len(userIDs))
+
+	for _, n := range s.notifications {
+		if n.readAt == nil && slices.Contains(userIDs, n.userID) {
+			counts[n.userID]++
+		}
+	}
+
+	return
+}
+
+func (m *memoryStore) MarkNotificationRead(ctx context.Context, notificationID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if n, ok := s.notifications[notificationID]; ok && n.readAt == nil {
+		n.readAt = ptr(now())
+		s.notifications[notificationID] = n
+	}
+
+	return
+}
+
+func (m *memoryStore) MarkAllNotificationsRead(ctx context.Context, userID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	for id, n := range s.notifications {
+		if n.userID == userID && n.readAt == nil {
+			n.readAt = &t
+			s.notifications[id] = n
+		}
+	}
+
+	return
+}
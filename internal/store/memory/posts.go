@@ -0,0 +1,489 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/search"
+	"github.com/themintchoco/cvwo/internal/store"
+	"github.com/themintchoco/cvwo/internal/utils"
+)
+
+func (s *state) commentCount(postID int64) (count uint) {
+	for _, c := range s.comments {
+		if c.postID == postID && c.deletedAt == nil {
+			count++
+		}
+	}
+
+	return
+}
+
+func (s *state) reactionCount(postID int64) (count int) {
+	for key := range s.postReactions {
+		if key.targetID == postID {
+			count++
+		}
+	}
+
+	return
+}
+
+func (s *state) postView(p post) api.Post {
+	tags := make([]string, len(p.tagIDs))
+
+	for i, tagID := range p.tagIDs {
+		tags[i] = strconv.FormatInt(tagID, 10)
+	}
+
+	v := api.Post{
+		Title:         p.title,
+		Body:          p.body,
+		Author:        s.author(p.userID),
+		CommentCount:  s.commentCount(p.id),
+		ReactionCount: uint(s.reactionCount(p.id)),
+		HotScore:      p.hotScore,
+		Tags:          api.Tags(strings.Join(tags, ",")),
+		CreatedAt:     p.createdAt,
+		UpdatedAt:     p.updatedAt,
+		Edited:        p.editedAt != nil,
+		EditedAt:      p.editedAt,
+		RevisionCount: uint(len(p.revisions) + 1),
+	}
+	v.ID = uint(p.id)
+	v.Deleted = p.deletedAt != nil
+
+	return v
+}
+
+func (s *state) postHasTag(p post, name string) bool {
+	for _, tagID := range p.tagIDs {
+		if sameText(s.tags[tagID].Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *memoryStore) CreatePost(ctx context.Context, userID int64, title, body string) (postID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+	postID = s.nextID("posts")
+	s.posts[postID] = post{id: postID, userID: userID, title: title, body: body, createdAt: t, updatedAt: t}
+
+	return
+}
+
+func (m *memoryStore) GetPosts(ctx context.Context, q store.PostQuery) (posts []api.Post, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	var matches []post
+
+	for _, p := range s.posts {
+		if p.deletedAt != nil {
+			continue
+		}
+
+		if q.Author != "" && !sameText(s.users[p.userID].username, q.Author) {
+			continue
+		}
+
+		if q.TagID != nil && !slices.Contains(p.tagIDs, *q.TagID) {
+			continue
+		}
+
+		if q.Search != nil {
+			if _, ok := s.matchPost(p, *q.Search); !ok {
+				continue
+			}
+		}
+
+		if q.Since != nil && p.createdAt.Before(*q.Since) {
+			continue
+		}
+
+		matches = append(matches, p)
+	}
+
+	sortBy := store.PostSort(q.Sort)
+
+	if q.After != nil && q.After.Sort != sortBy {
+		err = store.ErrInvalidCursor
+		return
+	}
+
+	// Posts are ranked by score, which only hot sorts use, and then by key.
+	type ranking struct {
+		score float64
+		key   int64
+	}
+
+	rank := func(p post) ranking {
+		switch sortBy {
+		case store.PostSortPopular, store.PostSortTop:
+			return ranking{key: int64(s.reactionCount(p.id))}
+		case store.PostSortReplies:
+			return ranking{key: int64(s.commentCount(p.id))}
+		case store.PostSortHot:
+			return ranking{score: p.hotScore}
+		}
+
+		return ranking{key: p.createdAt.UnixNano()}
+	}
+
+	before := func(a, b ranking) bool {
+		return a.score > b.score || a.score == b.score && a.key > b.key
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if a, b := rank(matches[i]), rank(matches[j]); a != b {
+			return before(a, b)
+		}
+
+		return matches[i].id < matches[j].id
+	})
+
+	offset := q.Offset
+
+	if q.After != nil {
+		after := ranking{key: int64(q.After.Count)}
+
+		switch sortBy {
+		case store.PostSortNew:
+			after.key = q.After.Time.UnixNano()
+		case store.PostSortHot:
+			after = ranking{score: q.After.Score}
+		}
+
+		offset = int64(sort.Search(len(matches), func(i int) bool {
+			r := rank(matches[i])
+			return before(after, r) || r == after && matches[i].id > int64(q.After.ID)
+		}))
+	}
+
+	posts = make([]api.Post, 0)
+
+	for _, p := range page(matches, q.Limit, offset) {
+		posts = append(posts, s.postView(p))
+	}
+
+	return
+}
+
+func (m *memoryStore) GetPost(ctx context.Context, postID int64) (p api.Post, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	stored, ok := s.posts[postID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return s.postView(stored), nil
+}
+
+func (m *memoryStore) UpdateHotScores(ctx context.Context, gravity float64, since time.Time) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for id, p := range s.posts {
+		if p.deletedAt != nil {
+			continue
+		}
+
+		p.hotScore = 0
+
+		if !p.createdAt.Before(since) {
+			points := float64(s.reactionCount(id)) + float64(s.commentCount(id))
+			p.hotScore = points / math.Pow(now().Sub(p.createdAt).Hours()+2, gravity)
+		}
+
+		s.posts[id] = p
+	}
+
+	return
+}
+
+func (m *memoryStore) UpdatePost(ctx context.Context, postID, editorID int64, title, body string, tags []string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	p, ok := s.posts[postID]
+
+	if ok && p.deletedAt == nil && (p.title != title || p.body != body) {
+		previous := revision{title: ptr(p.title), body: p.body, editorID: p.userID, createdAt: p.createdAt}
+
+		if p.editedAt != nil {
+			previous.editorID, previous.createdAt = *p.editedBy, *p.editedAt
+		}
+
+		t := now()
+		p.revisions = append(slices.Clip(p.revisions), previous)
+		p.title, p.body = title, body
+		p.editedAt, p.editedBy, p.updatedAt = &t, &editorID, t
+		s.posts[postID] = p
+	}
+
+	if tags == nil {
+		return
+	}
+
+	s.setPostTags(postID, tags)
+
+	return
+}
+
+func (s *state) tagByName(name string) (tag api.Tag, ok bool) {
+	for _, tag := range s.tags {
+		if sameText(tag.Name, name) {
+			return tag, true
+		}
+	}
+
+	return
+}
+
+func (s *state) setPostTags(postID int64, tags []string) {
+	p, ok := s.posts[postID]
+
+	if !ok {
+		return
+	}
+
+	tagIDs := make([]int64, 0, len(tags))
+
+	for _, name := range tags {
+		tag, ok := s.tagByName(name)
+
+		if !ok {
+			tag = api.Tag{ID: uint(s.nextID("tags")), Name: name, Color: "gray"}
+			s.tags[int64(tag.ID)] = tag
+		}
+
+		if !slices.Contains(tagIDs, int64(tag.ID)) {
+			tagIDs = append(tagIDs, int64(tag.ID))
+		}
+	}
+
+	slices.Sort(tagIDs)
+	p.tagIDs = tagIDs
+	s.posts[postID] = p
+}
+
+func (m *memoryStore) SetPostTags(ctx context.Context, postID int64, tags []string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	s.setPostTags(postID, tags)
+
+	return
+}
+
+// revisions lists the versions kept of a post or comment followed by the
+// current one.
+func (s *state) revisions(kept []revision, current revision) []api.Revision {
+	revisions := make([]api.Revision, 0, len(kept)+1)
+
+	for i, r := range append(slices.Clip(kept), current) {
+		revisions = append(revisions, api.Revision{
+			Number:    uint(i + 1),
+			Title:     r.title,
+			Body:      r.body,
+			Editor:    s.author(r.editorID),
+			CreatedAt: r.createdAt,
+			Current:   i == len(kept),
+		})
+	}
+
+	return revisions
+}
+
+func (m *memoryStore) GetPostRevisions(ctx context.Context, postID int64) (revisions []api.Revision, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	p, ok := s.posts[postID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	current := revision{title: ptr(p.title), body: p.body, editorID: p.userID, createdAt: p.createdAt}
+
+	if p.editedAt != nil {
+		current.editorID, current.createdAt = *p.editedBy, *p.editedAt
+	}
+
+	return s.revisions(p.revisions, current), nil
+}
+
+func (m *memoryStore) DeletePost(ctx context.Context, postID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if p, ok := s.posts[postID]; ok && p.deletedAt == nil {
+		p.deletedAt = ptr(now())
+		s.posts[postID] = p
+	}
+
+	return
+}
+
+func (m *memoryStore) GetPostParticipants(ctx context.Context, postID int64) (userIDs []int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	userIDs = make([]int64, 0)
+
+	for _, c := range s.comments {
+		if c.postID == postID && c.deletedAt == nil && !slices.Contains(userIDs, c.userID) {
+			userIDs = append(userIDs, c.userID)
+		}
+	}
+
+	slices.Sort(userIDs)
+
+	return
+}
+
+// words splits text into lowercase words the way the full-text index does.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r == '_' || r == '\'' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || r > 127)
+	})
+}
+
+// matchText approximates a boolean full-text match of q against text: every
+// term must prefix a word, every phrase must appear, and no exclusion may. The
+// score counts the matching words and phrases.
+func matchText(text string, q search.Query) (score float64, ok bool) {
+	textWords := words(text)
+	joined := " " + strings.Join(textWords, " ") + " "
+
+	for _, excluded := range q.Excluded {
+		phrase := strings.Join(words(strings.Trim(excluded, `"`)), " ")
+
+		if phrase != "" && strings.Contains(joined, " "+phrase+" ") {
+			return 0, false
+		}
+	}
+
+	for _, term := range q.Terms {
+		term = strings.ToLower(term)
+		found := false
+
+		for _, word := range textWords {
+			if strings.HasPrefix(word, term) {
+				score++
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, false
+		}
+	}
+
+	for _, phrase := range q.Phrases {
+		phrase = strings.Join(words(phrase), " ")
+		count := strings.Count(joined, " "+phrase+" ")
+
+		if count == 0 {
+			return 0, false
+		}
+
+		score += float64(count)
+	}
+
+	return score, true
+}
+
+// matchFilters applies the filters of q other than its text.
+func (s *state) matchFilters(q search.Query, p post, userID int64, createdAt time.Time) bool {
+	if q.Tag != "" && !s.postHasTag(p, q.Tag) {
+		return false
+	}
+
+	if q.User != "" && !sameText(s.users[userID].username, q.User) {
+		return false
+	}
+
+	if q.Before != nil && !createdAt.Before(*q.Before) {
+		return false
+	}
+
+	if q.After != nil && createdAt.Before(*q.After) {
+		return false
+	}
+
+	return true
+}
+
+func (s *state) matchPost(p post, q search.Query) (score float64, ok bool) {
+	if !s.matchFilters(q, p, p.userID, p.createdAt) {
+		return 0, false
+	}
+
+	return matchText(p.title+" "+utils.StripTags(p.body), q)
+}
+
+func (m *memoryStore) SearchPosts(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	results = make([]api.SearchResult, 0)
+
+	for _, p := range s.posts {
+		if p.deletedAt != nil {
+			continue
+		}
+
+		if score, ok := s.matchPost(p, q); ok {
+			v := s.postView(p)
+			results = append(results, api.SearchResult{Type: "post", Score: score, Post: &v})
+		}
+	}
+
+	sortResults(results)
+
+	return page(results, limit, offset), nil
+}
+
+// sortResults orders search results by score, then newest first.
+func sortResults(results []api.SearchResult) {
+	key := func(r api.SearchResult) (time.Time, uint) {
+		if r.Post != nil {
+			return r.Post.CreatedAt, r.Post.ID
+		}
+
+		return r.Comment.CreatedAt, r.Comment.ID
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		a, aID := key(results[i])
+		b, bID := key(results[j])
+
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+
+		return aID > bID
+	})
+}
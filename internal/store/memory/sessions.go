@@ -0,0 +1,274 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func (s session) active(t time.Time) bool {
+	return s.revokedAt == nil && s.expiresAt.After(t)
+}
+
+func (m *memoryStore) CreateSession(ctx context.Context, userID int64, tokenHash, userAgent, ip string, expiresAt time.Time) (sessionID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, other := range s.sessions {
+		if other.tokenHash == tokenHash {
+			err = ErrDuplicate
+			return
+		}
+	}
+
+	t := now()
+	sessionID = s.nextID("sessions")
+	s.sessions[sessionID] = session{id: sessionID, userID: userID, tokenHash: tokenHash, userAgent: userAgent, ip: ip, createdAt: t, lastSeenAt: t, expiresAt: expiresAt}
+
+	return
+}
+
+func (m *memoryStore) RotateSession(ctx context.Context, tokenHash, newTokenHash, userAgent, ip string, expiresAt time.Time, grace time.Duration) (sessionID, userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	for id, session := range s.sessions {
+		if session.tokenHash != tokenHash || !session.active(t) {
+			continue
+		}
+
+		session.previousTokenHash = ptr(session.tokenHash)
+		session.tokenHash = newTokenHash
+		session.userAgent, session.ip = userAgent, ip
+		session.rotatedAt, session.lastSeenAt = &t, t
+		session.expiresAt = expiresAt
+		s.sessions[id] = session
+
+		return session.id, session.userID, nil
+	}
+
+	for id, session := range s.sessions {
+		if session.previousTokenHash == nil || *session.previousTokenHash != tokenHash || !session.active(t) {
+			continue
+		}
+
+		if session.rotatedAt != nil && t.Sub(*session.rotatedAt) < grace {
+			return session.id, session.userID, store.ErrSessionReused
+		}
+
+		// The old token has likely been stolen.
+		session.revokedAt = &t
+		s.sessions[id] = session
+
+		return session.id, session.userID, store.ErrNotFound
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) GetSessionActive(ctx context.Context, sessionID, userID int64) (active bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	session, ok := s.sessions[sessionID]
+
+	return ok && session.userID == userID && session.active(now()), nil
+}
+
+func (m *memoryStore) GetUserSessions(ctx context.Context, userID int64) (sessions []api.Session, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+	sessions = make([]api.Session, 0)
+
+	for _, session := range s.sessions {
+		if session.userID == userID && session.active(t) {
+			sessions = append(sessions, api.Session{
+				ID:         uint(session.id),
+				UserAgent:  session.userAgent,
+				IP:         session.ip,
+				CreatedAt:  session.createdAt,
+				LastSeenAt: session.lastSeenAt,
+				ExpiresAt:  session.expiresAt,
+			})
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return
+}
+
+func (m *memoryStore) RevokeSession(ctx context.Context, userID, sessionID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if session, ok := s.sessions[sessionID]; ok && session.userID == userID && session.revokedAt == nil {
+		session.revokedAt = ptr(now())
+		s.sessions[sessionID] = session
+	}
+
+	return
+}
+
+func (m *memoryStore) RevokeUserSessions(ctx context.Context, userID int64, exceptSessionID *int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	for id, session := range s.sessions {
+		if session.userID != userID || session.revokedAt != nil || exceptSessionID != nil && id == *exceptSessionID {
+			continue
+		}
+
+		session.revokedAt = &t
+		s.sessions[id] = session
+	}
+
+	return
+}
+
+func (m *memoryStore) CreateUserToken(ctx context.Context, userID int64, purpose, tokenHash, email string, expiresAt time.Time) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+	tokens := slices.Clone(s.userTokens)
+
+	for i, token := range tokens {
+		if token.tokenHash == tokenHash {
+			return ErrDuplicate
+		}
+
+		if token.userID == userID && token.purpose == purpose && token.usedAt == nil {
+			tokens[i].usedAt = &t
+		}
+	}
+
+	s.userTokens = append(tokens, userToken{userID: userID, purpose: purpose, tokenHash: tokenHash, email: email, expiresAt: expiresAt})
+
+	return
+}
+
+func (m *memoryStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (userID int64, email string, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	for i, token := range s.userTokens {
+		if token.tokenHash == tokenHash && token.purpose == purpose && token.usedAt == nil && token.expiresAt.After(t) {
+			s.userTokens = slices.Clone(s.userTokens)
+			s.userTokens[i].usedAt = &t
+
+			return token.userID, token.email, nil
+		}
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) CreateAPIToken(ctx context.Context, userID int64, name, tokenHash string, scopes []string, expiresAt *time.Time) (tokenID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, token := range s.apiTokens {
+		if token.tokenHash == tokenHash {
+			err = ErrDuplicate
+			return
+		}
+	}
+
+	tokenID = s.nextID("api_tokens")
+	s.apiTokens[tokenID] = apiToken{
+		APIToken:  api.APIToken{ID: uint(tokenID), Name: name, Scopes: slices.Clone(scopes), CreatedAt: now(), ExpiresAt: expiresAt},
+		userID:    userID,
+		tokenHash: tokenHash,
+	}
+
+	return
+}
+
+func (m *memoryStore) GetAPITokenUser(ctx context.Context, tokenHash string) (tokenID, userID int64, scopes []string, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	for _, token := range s.apiTokens {
+		if token.tokenHash != tokenHash || token.revokedAt != nil || token.ExpiresAt != nil && !token.ExpiresAt.After(t) || s.users[token.userID].deletedAt != nil {
+			continue
+		}
+
+		return int64(token.ID), token.userID, slices.Clone(token.Scopes), nil
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) UpdateAPITokenLastUsed(ctx context.Context, tokenID int64, ip string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	t := now()
+
+	if token, ok := s.apiTokens[tokenID]; ok && (token.LastUsedAt == nil || token.LastUsedAt.Before(t.Add(-time.Minute))) {
+		token.LastUsedAt, token.LastUsedIP = &t, &ip
+		s.apiTokens[tokenID] = token
+	}
+
+	return
+}
+
+func (m *memoryStore) GetUserAPITokens(ctx context.Context, userID int64) (tokens []api.APIToken, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	tokens = make([]api.APIToken, 0)
+
+	for _, token := range s.apiTokens {
+		if token.userID == userID && token.revokedAt == nil {
+			t := token.APIToken
+			t.Scopes = slices.Clone(t.Scopes)
+			tokens = append(tokens, t)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return
+}
+
+func (m *memoryStore) RevokeAPIToken(ctx context.Context, userID, tokenID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	token, ok := s.apiTokens[tokenID]
+
+	if !ok || token.userID != userID || token.revokedAt != nil {
+		return store.ErrNotFound
+	}
+
+	token.revokedAt = ptr(now())
+	s.apiTokens[tokenID] = token
+
+	return
+}
@@ -0,0 +1,289 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+)
+
+func (m *memoryStore) CreateTag(ctx context.Context, name, color, description string) (tagID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if _, ok := s.tagByName(name); ok {
+		err = ErrDuplicate
+		return
+	}
+
+	tagID = s.nextID("tags")
+	s.tags[tagID] = api.Tag{ID: uint(tagID), Name: name, Color: color, Description: description}
+
+	return
+}
+
+func (m *memoryStore) GetTags(ctx context.Context, q store.TagQuery) (tags []api.Tag, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	counts := make(map[uint]int)
+
+	for _, p := range s.posts {
+		if p.deletedAt != nil || q.Since != nil && p.createdAt.Before(*q.Since) {
+			continue
+		}
+
+		for _, tagID := range p.tagIDs {
+			counts[uint(tagID)]++
+		}
+	}
+
+	tags = make([]api.Tag, 0)
+
+	for _, tag := range s.tags {
+		if q.Name != "" && !strings.Contains(strings.ToLower(tag.Name), strings.ToLower(q.Name)) {
+			continue
+		}
+
+		if q.Since != nil && counts[tag.ID] == 0 {
+			continue
+		}
+
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i].ID] != counts[tags[j].ID] {
+			return counts[tags[i].ID] > counts[tags[j].ID]
+		}
+
+		return tags[i].ID < tags[j].ID
+	})
+
+	return page(tags, q.Limit, q.Offset), nil
+}
+
+func (m *memoryStore) GetTag(ctx context.Context, tagID int64) (tag api.Tag, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	tag, ok := s.tags[tagID]
+
+	if !ok {
+		err = store.ErrNotFound
+	}
+
+	return
+}
+
+func (m *memoryStore) UpdateTag(ctx context.Context, tagID int64, color, description string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if tag, ok := s.tags[tagID]; ok {
+		tag.Color, tag.Description = color, description
+		s.tags[tagID] = tag
+	}
+
+	return
+}
+
+func (m *memoryStore) CreatePostTag(ctx context.Context, postID int64, name string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	tag, ok := s.tagByName(name)
+	p, exists := s.posts[postID]
+
+	if !ok || !exists {
+		return
+	}
+
+	if slices.Contains(p.tagIDs, int64(tag.ID)) {
+		return ErrDuplicate
+	}
+
+	p.tagIDs = append(slices.Clip(p.tagIDs), int64(tag.ID))
+	slices.Sort(p.tagIDs)
+	s.posts[postID] = p
+
+	return
+}
+
+func (m *memoryStore) CreateTagModerator(ctx context.Context, tagID, userID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	s.tagModerators[moderatorKey{tagID, userID}] = true
+
+	return
+}
+
+func (m *memoryStore) DeleteTagModerator(ctx context.Context, tagID, userID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	delete(s.tagModerators, moderatorKey{tagID, userID})
+
+	return
+}
+
+func (m *memoryStore) GetTagModerators(ctx context.Context, tagID int64) (users []api.User, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	users = make([]api.User, 0)
+
+	for key := range s.tagModerators {
+		if key.tagID == tagID {
+			users = append(users, s.author(key.userID))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+	})
+
+	return
+}
+
+func (m *memoryStore) GetTagModerator(ctx context.Context, tagID, userID int64) (moderator bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.tagModerators[moderatorKey{tagID, userID}], nil
+}
+
+func (m *memoryStore) GetPostTagModerator(ctx context.Context, postID, userID int64) (moderator bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, tagID := range s.posts[postID].tagIDs {
+		if s.tagModerators[moderatorKey{tagID, userID}] {
+			return true, nil
+		}
+	}
+
+	return
+}
+
+func (s *state) reactionByName(name, kind string) (r reaction, ok bool) {
+	for _, r := range s.reactions {
+		if sameText(r.name, name) && r.kind == kind {
+			return r, true
+		}
+	}
+
+	return
+}
+
+// countReactions tallies the reactions to a post or comment by kind.
+func (s *state) countReactions(reactions map[reactionKey]int64, targetID int64) []api.Reaction {
+	counts := make(map[int64]uint)
+
+	for key, reactionID := range reactions {
+		if key.targetID == targetID {
+			counts[reactionID]++
+		}
+	}
+
+	tally := make([]api.Reaction, 0, len(counts))
+
+	for _, r := range s.reactions {
+		if counts[r.id] > 0 {
+			tally = append(tally, api.Reaction{ID: uint(r.id), Name: r.name, Count: counts[r.id]})
+		}
+	}
+
+	return tally
+}
+
+func (s *state) userReaction(reactions map[reactionKey]int64, userID, targetID int64) (reaction api.Reaction, err error) {
+	reactionID, ok := reactions[reactionKey{userID, targetID}]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	for _, r := range s.reactions {
+		if r.id == reactionID {
+			return api.Reaction{ID: uint(r.id), Name: r.name, Count: 1}, nil
+		}
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) CreatePostReaction(ctx context.Context, userID, postID int64, name string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if r, ok := s.reactionByName(name, "post"); ok {
+		s.postReactions[reactionKey{userID, postID}] = r.id
+	}
+
+	return
+}
+
+func (m *memoryStore) GetPostReactions(ctx context.Context, postID int64) (reactions []api.Reaction, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.countReactions(s.postReactions, postID), nil
+}
+
+func (m *memoryStore) GetPostReaction(ctx context.Context, userID, postID int64) (reaction api.Reaction, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.userReaction(s.postReactions, userID, postID)
+}
+
+func (m *memoryStore) DeletePostReaction(ctx context.Context, userID, postID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	delete(s.postReactions, reactionKey{userID, postID})
+
+	return
+}
+
+func (m *memoryStore) CreateCommentReaction(ctx context.Context, userID, commentID int64, name string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if r, ok := s.reactionByName(name, "comment"); ok {
+		s.commentReactions[reactionKey{userID, commentID}] = r.id
+	}
+
+	return
+}
+
+func (m *memoryStore) GetCommentReactions(ctx context.Context, commentID int64) (reactions []api.Reaction, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.countReactions(s.commentReactions, commentID), nil
+}
+
+func (m *memoryStore) GetCommentReaction(ctx context.Context, userID, commentID int64) (reaction api.Reaction, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.userReaction(s.commentReactions, userID, commentID)
+}
+
+func (m *memoryStore) DeleteCommentReaction(ctx context.Context, userID, commentID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	delete(s.commentReactions, reactionKey{userID, commentID})
+
+	return
+}
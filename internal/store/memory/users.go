@@ -0,0 +1,574 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// author returns a user as shown alongside their content.
+func (s *state) author(userID int64) api.User {
+	u := s.users[userID]
+
+	a := api.User{
+		ID:        uint(u.id),
+		Username:  u.username,
+		Role:      u.role,
+		Bio:       u.bio,
+		Avatar:    u.avatar,
+		CreatedAt: u.createdAt.Format(time.RFC3339Nano),
+	}
+	a.Deleted = u.deletedAt != nil
+
+	return a
+}
+
+func (s *state) userByUsername(username string) (u user, ok bool) {
+	for _, u := range s.users {
+		if sameText(u.username, username) {
+			return u, true
+		}
+	}
+
+	return
+}
+
+func (s *state) insertUser(u user) (userID int64, err error) {
+	for _, other := range s.users {
+		if sameText(other.username, u.username) || (u.email != nil && other.email != nil && sameText(*other.email, *u.email)) {
+			return 0, ErrDuplicate
+		}
+	}
+
+	u.id = s.nextID("users")
+	u.prefs = map[string]any{}
+	u.createdAt = now()
+	s.users[u.id] = u
+
+	return u.id, nil
+}
+
+func (m *memoryStore) CreateUser(ctx context.Context, username, email, password, role string) (userID int64, err error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return
+	}
+
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	return s.insertUser(user{username: username, email: &email, password: string(hashed), role: role})
+}
+
+func (m *memoryStore) CreateExternalUser(ctx context.Context, username string, email *string) (userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u := user{username: username, email: email, role: "member"}
+
+	// Only addresses the identity provider has verified are passed in.
+	if email != nil {
+		u.emailVerifiedAt = ptr(now())
+	}
+
+	return s.insertUser(u)
+}
+
+func (m *memoryStore) GetUser(ctx context.Context, userID int64) (u api.User, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	var posts, comments uint
+
+	for _, p := range s.posts {
+		if p.userID == userID && p.deletedAt == nil {
+			posts++
+		}
+	}
+
+	for _, c := range s.comments {
+		if c.userID == userID && c.deletedAt == nil {
+			comments++
+		}
+	}
+
+	u = s.author(userID)
+	u.PostCount = &posts
+	u.CommentCount = &comments
+
+	return
+}
+
+func (m *memoryStore) UpdateUser(ctx context.Context, userID int64, username, password, role, bio *string) (err error) {
+	var hashed []byte
+
+	if password != nil {
+		hashed, err = bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+
+		if err != nil {
+			return
+		}
+	}
+
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok {
+		return
+	}
+
+	if username != nil {
+		if other, ok := s.userByUsername(*username); ok && other.id != userID {
+			return ErrDuplicate
+		}
+
+		u.username = *username
+	}
+
+	if password != nil {
+		u.password = string(hashed)
+	}
+
+	if role != nil {
+		u.role = *role
+	}
+
+	if bio != nil {
+		u.bio = bio
+	}
+
+	s.users[userID] = u
+
+	return
+}
+
+// updateUser applies fn to a user, if they exist.
+func (m *memoryStore) updateUser(ctx context.Context, userID int64, fn func(u *user) bool) (updated bool) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok || !fn(&u) {
+		return false
+	}
+
+	s.users[userID] = u
+
+	return true
+}
+
+func (m *memoryStore) UpdateUserAvatar(ctx context.Context, userID int64, avatar *string) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		u.avatar = avatar
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) DeleteUser(ctx context.Context, userID int64) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		u.deletedAt = ptr(now())
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) AuthenticateUser(ctx context.Context, username, password string) (userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	u, ok := s.userByUsername(username)
+	unlock()
+
+	// Accounts provisioned through an identity provider have no password.
+	if !ok || u.deletedAt != nil || u.password == "" {
+		err = store.ErrPasswordMismatch
+		return
+	}
+
+	userID = u.id
+	err = bcrypt.CompareHashAndPassword([]byte(u.password), []byte(password))
+
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		err = store.ErrPasswordMismatch
+	}
+
+	if err != nil {
+		return
+	}
+
+	suspended, err := m.GetUserSuspended(ctx, userID)
+
+	if err == nil && suspended {
+		err = store.ErrUserSuspended
+	}
+
+	return
+}
+
+func (m *memoryStore) GetUsernameAvailability(ctx context.Context, username string) (available bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	_, taken := s.userByUsername(username)
+
+	return !taken, nil
+}
+
+func (m *memoryStore) GetUserPreferences(ctx context.Context, userID int64) (preferences any, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	// Round trip through JSON so that values read back as they would from the
+	// database.
+	raw, err := json.Marshal(u.prefs)
+
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(raw, &preferences)
+
+	return
+}
+
+func (m *memoryStore) GetUsersPreferences(ctx context.Context, userIDs []int64) (preferences map[int64]any, err error) {
+	preferences = make(map[int64]any, len(userIDs))
+
+	for _, userID := range userIDs {
+		prefs, err := m.GetUserPreferences(ctx, userID)
+
+		if err == store.ErrNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		preferences[userID] = prefs
+	}
+
+	return
+}
+
+func (m *memoryStore) UpdateUserPreferences(ctx context.Context, userID int64, key string, value any) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		u.prefs = maps.Clone(u.prefs)
+		u.prefs[key] = value
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) GetUserRole(ctx context.Context, userID int64) (role string, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok || u.deletedAt != nil {
+		err = store.ErrNotFound
+		return
+	}
+
+	return u.role, nil
+}
+
+func (m *memoryStore) GetUserCreatedAt(ctx context.Context, userID int64) (createdAt time.Time, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok || u.deletedAt != nil {
+		err = store.ErrNotFound
+		return
+	}
+
+	return u.createdAt, nil
+}
+
+func (m *memoryStore) GetUserHasPassword(ctx context.Context, userID int64) (hasPassword bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return u.password != "", nil
+}
+
+func (m *memoryStore) GetUserEmail(ctx context.Context, userID int64) (email *string, verified bool, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return u.email, u.emailVerifiedAt != nil, nil
+}
+
+func (m *memoryStore) GetUserIDByEmail(ctx context.Context, email string) (userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, u := range s.users {
+		if u.email != nil && sameText(*u.email, email) && u.deletedAt == nil {
+			return u.id, nil
+		}
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) UpdateUserEmail(ctx context.Context, userID int64, email string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, other := range s.users {
+		if other.id != userID && other.email != nil && sameText(*other.email, email) {
+			return ErrDuplicate
+		}
+	}
+
+	if u, ok := s.users[userID]; ok {
+		u.email = &email
+		u.emailVerifiedAt = nil
+		s.users[userID] = u
+	}
+
+	return
+}
+
+func (m *memoryStore) VerifyUserEmail(ctx context.Context, userID int64, email string) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		if u.email == nil || *u.email != email || u.emailVerifiedAt != nil {
+			return false
+		}
+
+		u.emailVerifiedAt = ptr(now())
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) GetUserTwoFactor(ctx context.Context, userID int64) (secret *string, enabled bool, lastStep *int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	u, ok := s.users[userID]
+
+	if !ok {
+		err = store.ErrNotFound
+		return
+	}
+
+	return u.totpSecret, u.totpEnabledAt != nil, u.totpLastStep, nil
+}
+
+func (m *memoryStore) UpdateUserTwoFactorSecret(ctx context.Context, userID int64, secret *string) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		u.totpSecret = secret
+		u.totpEnabledAt = nil
+		u.totpLastStep = nil
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) EnableUserTwoFactor(ctx context.Context, userID int64) (err error) {
+	m.updateUser(ctx, userID, func(u *user) bool {
+		if u.totpSecret == nil {
+			return false
+		}
+
+		u.totpEnabledAt = ptr(now())
+		return true
+	})
+
+	return
+}
+
+func (m *memoryStore) UseUserTwoFactorStep(ctx context.Context, userID, step int64) (err error) {
+	updated := m.updateUser(ctx, userID, func(u *user) bool {
+		if u.totpLastStep != nil && *u.totpLastStep >= step {
+			return false
+		}
+
+		u.totpLastStep = &step
+		return true
+	})
+
+	if !updated {
+		err = store.ErrNotFound
+	}
+
+	return
+}
+
+func (m *memoryStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	codes := make([]recoveryCode, 0, len(s.recoveryCodes)+len(codeHashes))
+
+	for _, code := range s.recoveryCodes {
+		if code.userID != userID {
+			codes = append(codes, code)
+		}
+	}
+
+	for _, codeHash := range codeHashes {
+		codes = append(codes, recoveryCode{userID: userID, codeHash: codeHash})
+	}
+
+	s.recoveryCodes = codes
+
+	return
+}
+
+func (m *memoryStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for i, code := range s.recoveryCodes {
+		if code.userID == userID && code.codeHash == codeHash && code.usedAt == nil {
+			s.recoveryCodes = append([]recoveryCode{}, s.recoveryCodes...)
+			s.recoveryCodes[i].usedAt = ptr(now())
+			return
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+func (m *memoryStore) GetRecoveryCodeCount(ctx context.Context, userID int64) (count int, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.userID == userID && code.usedAt == nil {
+			count++
+		}
+	}
+
+	return
+}
+
+func (m *memoryStore) GetIdentityUserID(ctx context.Context, issuer, subject string) (userID int64, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, i := range s.identities {
+		if i.Issuer == issuer && i.subject == subject && s.users[i.userID].deletedAt == nil {
+			return i.userID, nil
+		}
+	}
+
+	err = store.ErrNotFound
+
+	return
+}
+
+func (m *memoryStore) CreateIdentity(ctx context.Context, userID int64, issuer, subject string, email *string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for _, i := range s.identities {
+		if i.Issuer == issuer && i.subject == subject {
+			return ErrDuplicate
+		}
+	}
+
+	t := now()
+	id := s.nextID("user_identities")
+
+	s.identities[id] = identity{
+		Identity: api.Identity{ID: uint(id), Issuer: issuer, Email: email, CreatedAt: t, LastLoginAt: &t},
+		userID:   userID,
+		subject:  subject,
+	}
+
+	return
+}
+
+func (m *memoryStore) UpdateIdentityLogin(ctx context.Context, issuer, subject string, email *string) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	for id, i := range s.identities {
+		if i.Issuer == issuer && i.subject == subject {
+			i.Email = email
+			i.LastLoginAt = ptr(now())
+			s.identities[id] = i
+		}
+	}
+
+	return
+}
+
+func (m *memoryStore) GetUserIdentities(ctx context.Context, userID int64) (identities []api.Identity, err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	identities = make([]api.Identity, 0)
+
+	for _, i := range s.identities {
+		if i.userID == userID {
+			identities = append(identities, i.Identity)
+		}
+	}
+
+	sort.Slice(identities, func(a, b int) bool {
+		return identities[a].ID < identities[b].ID
+	})
+
+	return
+}
+
+func (m *memoryStore) DeleteIdentity(ctx context.Context, userID, identityID int64) (err error) {
+	s, unlock := m.lock(ctx)
+	defer unlock()
+
+	if i, ok := s.identities[identityID]; ok && i.userID == userID {
+		delete(s.identities, identityID)
+	}
+
+	return
+}
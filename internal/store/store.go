@@ -0,0 +1,333 @@
+// Package store defines the data access interfaces used by the API. The MySQL
+// implementation lives in package db, and package memory provides one that
+// needs no database, for tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/themintchoco/cvwo/internal/api"
+	"github.com/themintchoco/cvwo/internal/search"
+)
+
+var (
+	ErrPasswordMismatch = errors.New("password does not match")
+	ErrNotFound         = errors.New("not found")
+	ErrUserSuspended    = errors.New("user is suspended")
+	ErrSessionReused    = errors.New("refresh token was already used")
+	ErrTimeout          = errors.New("query timed out")
+	ErrConflict         = errors.New("conflicts with an existing entry")
+)
+
+// Store groups the stores of a backend.
+type Store struct {
+	Users         UserStore
+	Posts         PostStore
+	Comments      CommentStore
+	Tags          TagStore
+	Reactions     ReactionStore
+	Sessions      SessionStore
+	Notifications NotificationStore
+	Moderation    ModerationStore
+	Settings      SettingStore
+
+	// WithTx runs fn in a transaction, which is committed if fn succeeds and
+	// rolled back otherwise. Calls made with the context passed to fn are part
+	// of the transaction, and nested calls join the outer one.
+	WithTx func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type contextKey struct{}
+
+func WithStore(ctx context.Context, s Store) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the store that Middleware attached to a request.
+func FromContext(ctx context.Context) Store {
+	s, _ := ctx.Value(contextKey{}).(Store)
+	return s
+}
+
+// Middleware makes s available to handlers through FromContext.
+func Middleware(s Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithStore(r.Context(), s)))
+		})
+	}
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, username, email, password, role string) (userID int64, err error)
+	// CreateExternalUser creates a user without a password, who signs in
+	// through a linked identity provider.
+	CreateExternalUser(ctx context.Context, username string, email *string) (userID int64, err error)
+	GetUser(ctx context.Context, userID int64) (user api.User, err error)
+	UpdateUser(ctx context.Context, userID int64, username, password, role, bio *string) (err error)
+	UpdateUserAvatar(ctx context.Context, userID int64, avatar *string) (err error)
+	DeleteUser(ctx context.Context, userID int64) (err error)
+	AuthenticateUser(ctx context.Context, username, password string) (userID int64, err error)
+	GetUsernameAvailability(ctx context.Context, username string) (available bool, err error)
+	GetUserPreferences(ctx context.Context, userID int64) (preferences any, err error)
+	// GetUsersPreferences returns the preferences of each of userIDs that
+	// exists.
+	GetUsersPreferences(ctx context.Context, userIDs []int64) (preferences map[int64]any, err error)
+	UpdateUserPreferences(ctx context.Context, userID int64, key string, value any) (err error)
+	GetUserRole(ctx context.Context, userID int64) (role string, err error)
+	GetUserCreatedAt(ctx context.Context, userID int64) (createdAt time.Time, err error)
+	GetUserHasPassword(ctx context.Context, userID int64) (hasPassword bool, err error)
+
+	GetUserEmail(ctx context.Context, userID int64) (email *string, verified bool, err error)
+	GetUserIDByEmail(ctx context.Context, email string) (userID int64, err error)
+	UpdateUserEmail(ctx context.Context, userID int64, email string) (err error)
+	// VerifyUserEmail marks the email of a user as verified, provided it has
+	// not been changed since the verification was requested.
+	VerifyUserEmail(ctx context.Context, userID int64, email string) (err error)
+
+	// GetUserTwoFactor returns the TOTP secret of a user, which is set once
+	// enrolment starts, and whether enrolment has been confirmed.
+	GetUserTwoFactor(ctx context.Context, userID int64) (secret *string, enabled bool, lastStep *int64, err error)
+	UpdateUserTwoFactorSecret(ctx context.Context, userID int64, secret *string) (err error)
+	EnableUserTwoFactor(ctx context.Context, userID int64) (err error)
+	// UseUserTwoFactorStep records step as the latest TOTP time step used by a
+	// user. It returns ErrNotFound if step is not newer than the last one.
+	UseUserTwoFactorStep(ctx context.Context, userID, step int64) (err error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) (err error)
+	// UseRecoveryCode marks an unused recovery code as used, returning
+	// ErrNotFound if the user has no such code.
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (err error)
+	GetRecoveryCodeCount(ctx context.Context, userID int64) (count int, err error)
+
+	GetIdentityUserID(ctx context.Context, issuer, subject string) (userID int64, err error)
+	CreateIdentity(ctx context.Context, userID int64, issuer, subject string, email *string) (err error)
+	UpdateIdentityLogin(ctx context.Context, issuer, subject string, email *string) (err error)
+	GetUserIdentities(ctx context.Context, userID int64) (identities []api.Identity, err error)
+	DeleteIdentity(ctx context.Context, userID, identityID int64) (err error)
+}
+
+const (
+	PostSortNew     = "new"
+	PostSortPopular = "popular"
+	PostSortReplies = "replies"
+	// PostSortHot ranks posts by their hot score, which decays with age.
+	PostSortHot = "hot"
+	// PostSortTop ranks posts by reactions, like PostSortPopular, and is
+	// usually combined with Since to rank the posts of a period.
+	PostSortTop = "top"
+)
+
+// PostQuery selects a page of posts that have not been deleted.
+type PostQuery struct {
+	Limit  int64
+	Offset int64
+	// Author is the username of the author, if not empty.
+	Author string
+	TagID  *int64
+	Search *search.Query
+	// Since only includes posts created since, if not nil.
+	Since *time.Time
+	// Sort is one of the PostSort constants, defaulting to PostSortNew.
+	Sort string
+	// After continues the list from a cursor taken with PostCursor, instead
+	// of skipping Offset posts. Its sort must match Sort, or ErrInvalidCursor
+	// is returned.
+	After *Cursor
+}
+
+// PostSort returns sort if it is one of the PostSort constants, and
+// PostSortNew otherwise.
+func PostSort(sort string) string {
+	switch sort {
+	case PostSortPopular, PostSortReplies, PostSortHot, PostSortTop:
+		return sort
+	}
+
+	return PostSortNew
+}
+
+type PostStore interface {
+	CreatePost(ctx context.Context, userID int64, title, body string) (postID int64, err error)
+	GetPosts(ctx context.Context, q PostQuery) (posts []api.Post, err error)
+	GetPost(ctx context.Context, postID int64) (post api.Post, err error)
+	// UpdateHotScores recomputes the hot score of the posts created since, as
+	// (reactions + comments) / (age in hours + 2) ^ gravity. Older posts are
+	// scored 0.
+	UpdateHotScores(ctx context.Context, gravity float64, since time.Time) (err error)
+	// UpdatePost replaces the title and body of a post, keeping the previous
+	// version as a revision if either has changed. If tags is not nil, the
+	// post's tags are replaced with them.
+	UpdatePost(ctx context.Context, postID, editorID int64, title, body string, tags []string) (err error)
+	// SetPostTags replaces the tags of a post, creating any that do not exist
+	// yet.
+	SetPostTags(ctx context.Context, postID int64, tags []string) (err error)
+	// GetPostRevisions returns every version of a post, oldest first, ending
+	// with the current one.
+	GetPostRevisions(ctx context.Context, postID int64) (revisions []api.Revision, err error)
+	DeletePost(ctx context.Context, postID int64) (err error)
+	// GetPostParticipants returns the users who have commented on a post.
+	GetPostParticipants(ctx context.Context, postID int64) (userIDs []int64, err error)
+	SearchPosts(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error)
+}
+
+// CommentQuery selects a page of comments, newest first.
+type CommentQuery struct {
+	Limit  int64
+	Offset int64
+	PostID *int64
+	// Author is the username of the author, if not empty.
+	Author string
+	// Threaded selects the comments at the top of a thread: replies to
+	// ParentID, or top-level comments if it is nil. Deleted comments that have
+	// replies are included so that their replies can be shown under them.
+	Threaded bool
+	ParentID *int64
+	// After continues the list from a cursor taken with CommentCursor,
+	// instead of skipping Offset comments.
+	After *Cursor
+}
+
+type CommentStore interface {
+	CreatePostComment(ctx context.Context, userID, postID int64, parentID *int64, depth uint, body string) (commentID int64, err error)
+	GetPostComments(ctx context.Context, q CommentQuery) (comments []api.Comment, err error)
+	GetPostComment(ctx context.Context, commentID int64) (comment api.Comment, err error)
+	// GetPostCommentReplies returns the replies to any of parentIDs, oldest
+	// first.
+	GetPostCommentReplies(ctx context.Context, parentIDs []int64) (comments []api.Comment, err error)
+	// UpdatePostComment replaces the body of a comment, keeping the previous
+	// version as a revision. Nothing is recorded if it has not changed.
+	UpdatePostComment(ctx context.Context, commentID, editorID int64, body string) (err error)
+	// GetPostCommentRevisions returns every version of a comment, oldest
+	// first, ending with the current one.
+	GetPostCommentRevisions(ctx context.Context, commentID int64) (revisions []api.Revision, err error)
+	DeletePostComment(ctx context.Context, commentID int64) (err error)
+	SearchComments(ctx context.Context, limit, offset int64, q search.Query) (results []api.SearchResult, err error)
+}
+
+// TagQuery selects a page of tags, most used first.
+type TagQuery struct {
+	Limit  int64
+	Offset int64
+	// Name matches tags whose name contains it, if not empty.
+	Name string
+	// Since only counts, and only includes tags with, posts created since.
+	Since *time.Time
+}
+
+type TagStore interface {
+	CreateTag(ctx context.Context, name, color, description string) (tagID int64, err error)
+	GetTags(ctx context.Context, q TagQuery) (tags []api.Tag, err error)
+	GetTag(ctx context.Context, tagID int64) (tag api.Tag, err error)
+	UpdateTag(ctx context.Context, tagID int64, color, description string) (err error)
+	CreatePostTag(ctx context.Context, postID int64, tag string) (err error)
+	CreateTagModerator(ctx context.Context, tagID, userID int64) (err error)
+	DeleteTagModerator(ctx context.Context, tagID, userID int64) (err error)
+	GetTagModerators(ctx context.Context, tagID int64) (users []api.User, err error)
+	GetTagModerator(ctx context.Context, tagID, userID int64) (moderator bool, err error)
+	// GetPostTagModerator reports whether a user moderates any of the tags of
+	// a post.
+	GetPostTagModerator(ctx context.Context, postID, userID int64) (moderator bool, err error)
+}
+
+type ReactionStore interface {
+	// CreatePostReaction sets the reaction of a user to a post, replacing any
+	// earlier one.
+	CreatePostReaction(ctx context.Context, userID, postID int64, reaction string) (err error)
+	GetPostReactions(ctx context.Context, postID int64) (reactions []api.Reaction, err error)
+	GetPostReaction(ctx context.Context, userID, postID int64) (reaction api.Reaction, err error)
+	DeletePostReaction(ctx context.Context, userID, postID int64) (err error)
+	// CreateCommentReaction sets the reaction of a user to a comment,
+	// replacing any earlier one.
+	CreateCommentReaction(ctx context.Context, userID, commentID int64, reaction string) (err error)
+	GetCommentReactions(ctx context.Context, commentID int64) (reactions []api.Reaction, err error)
+	GetCommentReaction(ctx context.Context, userID, commentID int64) (reaction api.Reaction, err error)
+	DeleteCommentReaction(ctx context.Context, userID, commentID int64) (err error)
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, userID int64, tokenHash, userAgent, ip string, expiresAt time.Time) (sessionID int64, err error)
+	// RotateSession replaces the refresh token of an active session. If
+	// tokenHash belongs to a token that was already rotated, the session is
+	// returned with ErrSessionReused when it was rotated within grace, and is
+	// otherwise revoked.
+	RotateSession(ctx context.Context, tokenHash, newTokenHash, userAgent, ip string, expiresAt time.Time, grace time.Duration) (sessionID, userID int64, err error)
+	GetSessionActive(ctx context.Context, sessionID, userID int64) (active bool, err error)
+	GetUserSessions(ctx context.Context, userID int64) (sessions []api.Session, err error)
+	RevokeSession(ctx context.Context, userID, sessionID int64) (err error)
+	// RevokeUserSessions revokes every active session of a user, except for
+	// exceptSessionID if it is not nil.
+	RevokeUserSessions(ctx context.Context, userID int64, exceptSessionID *int64) (err error)
+
+	// CreateUserToken stores a single-use token, invalidating any unused
+	// tokens issued earlier to the user for the same purpose.
+	CreateUserToken(ctx context.Context, userID int64, purpose, tokenHash, email string, expiresAt time.Time) (err error)
+	// ConsumeUserToken marks an unexpired token as used and returns the user
+	// and email it was issued for, or ErrNotFound.
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (userID int64, email string, err error)
+
+	CreateAPIToken(ctx context.Context, userID int64, name, tokenHash string, scopes []string, expiresAt *time.Time) (tokenID int64, err error)
+	// GetAPITokenUser returns the owner and scopes of an active token that has
+	// not expired, or ErrNotFound.
+	GetAPITokenUser(ctx context.Context, tokenHash string) (tokenID, userID int64, scopes []string, err error)
+	// UpdateAPITokenLastUsed records token usage, at most once a minute per
+	// token.
+	UpdateAPITokenLastUsed(ctx context.Context, tokenID int64, ip string) (err error)
+	GetUserAPITokens(ctx context.Context, userID int64) (tokens []api.APIToken, err error)
+	RevokeAPIToken(ctx context.Context, userID, tokenID int64) (err error)
+}
+
+type NotificationStore interface {
+	// CreateNotifications notifies each of userIDs of something done by
+	// actorID, in one batch. If coalesce is set, users with an unread
+	// notification of the same kind about the same post and comment have the
+	// actor added to it, making it the most recent one, instead.
+	CreateNotifications(ctx context.Context, userIDs []int64, actorID int64, kind string, postID int64, commentID *int64, coalesce bool) (err error)
+	GetNotifications(ctx context.Context, userID, limit, offset int64, unreadOnly bool) (notifications []api.Notification, err error)
+	GetNotificationOwner(ctx context.Context, notificationID int64) (userID int64, err error)
+	GetUnreadNotificationCount(ctx context.Context, userID int64) (count uint, err error)
+	// GetUnreadNotificationCounts returns the number of unread notifications
+	// of each of userIDs that has any.
+	GetUnreadNotificationCounts(ctx context.Context, userIDs []int64) (counts map[int64]uint, err error)
+	MarkNotificationRead(ctx context.Context, notificationID int64) (err error)
+	MarkAllNotificationsRead(ctx context.Context, userID int64) (err error)
+}
+
+// ReportQuery selects a page of reports, newest first.
+type ReportQuery struct {
+	Limit  int64
+	Offset int64
+	// Statuses matches reports with any of the statuses, if not empty.
+	Statuses   []string
+	TargetType string
+	TargetID   *int64
+	Reason     string
+}
+
+type ModerationStore interface {
+	CreateReport(ctx context.Context, reporterID int64, targetType string, targetID int64, reason, note string) (reportID int64, err error)
+	GetReports(ctx context.Context, q ReportQuery) (reports []api.Report, err error)
+	GetReport(ctx context.Context, reportID int64) (report api.Report, err error)
+	ClaimReport(ctx context.Context, reportID, assigneeID int64) (err error)
+	ResolveReport(ctx context.Context, reportID, resolvedBy int64, resolution, note string) (err error)
+
+	CreateWarning(ctx context.Context, userID, createdBy int64, reportID *int64, reason string) (warningID int64, err error)
+	GetUserWarnings(ctx context.Context, userID int64) (warnings []api.Warning, err error)
+
+	CreateSuspension(ctx context.Context, userID, createdBy int64, reportID *int64, reason string, endsAt *time.Time) (suspensionID int64, err error)
+	GetUserSuspended(ctx context.Context, userID int64) (suspended bool, err error)
+	GetUserSuspensions(ctx context.Context, userID int64) (suspensions []api.Suspension, err error)
+	// GetUserActiveSuspension returns the active suspension that ends last,
+	// with permanent suspensions taking precedence over timed ones.
+	GetUserActiveSuspension(ctx context.Context, userID int64) (suspension api.Suspension, err error)
+	GetSuspension(ctx context.Context, suspensionID int64) (suspension api.Suspension, err error)
+	LiftSuspension(ctx context.Context, suspensionID, liftedBy int64) (err error)
+}
+
+type SettingStore interface {
+	// GetSetting decodes the JSON value of a setting into value.
+	GetSetting(ctx context.Context, key string, value any) (err error)
+	UpdateSetting(ctx context.Context, key string, value any) (err error)
+}
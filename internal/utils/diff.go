@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/themintchoco/cvwo/internal/api"
+)
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// Beyond this many inserted and deleted words, texts are considered entirely
+// rewritten rather than spending quadratic time and memory on a minimal diff.
+const maxDiffEdits = 2000
+
+var diffTokenPattern = regexp.MustCompile(`\s+|[\p{L}\p{N}_]+|[^\s\p{L}\p{N}_]`)
+
+// Diff compares two texts word by word, returning the operations that turn a
+// into b with adjacent operations of the same kind merged.
+func Diff(a, b string) []api.DiffOp {
+	x := diffTokenPattern.FindAllString(a, -1)
+	y := diffTokenPattern.FindAllString(b, -1)
+
+	prefix := 0
+
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]api.DiffOp, 0)
+	ops = appendDiffOps(ops, DiffEqual, x[:prefix])
+	ops = append(ops, diffTokens(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	ops = appendDiffOps(ops, DiffEqual, x[len(x)-suffix:])
+
+	return mergeDiffOps(ops)
+}
+
+func appendDiffOps(ops []api.DiffOp, op string, tokens []string) []api.DiffOp {
+	for _, token := range tokens {
+		ops = append(ops, api.DiffOp{Op: op, Text: token})
+	}
+
+	return ops
+}
+
+// diffTokens finds a shortest edit script between x and y with Myers'
+// algorithm, keeping only the part of each frontier that can be reached.
+func diffTokens(x, y []string) []api.DiffOp {
+	n, m := len(x), len(y)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := make([][]int, 0)
+
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return appendDiffOps(appendDiffOps(nil, DiffDelete, x), DiffInsert, y)
+		}
+
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+
+		for k := -d; k <= d; k += 2 {
+			var i int
+
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+
+			j := i - k
+
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+
+			v[offset+k] = i
+
+			if i >= n && j >= m {
+				return backtrackDiff(x, y, trace)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackDiff(x, y []string, trace [][]int) []api.DiffOp {
+	ops := make([]api.DiffOp, 0)
+	i, j := len(x), len(y)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		frontier := trace[d]
+		at := func(k int) int { return frontier[k+d] }
+
+		k := i - j
+		prevK := k - 1
+
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+
+		prevI := at(prevK)
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			ops = append(ops, api.DiffOp{Op: DiffEqual, Text: x[i-1]})
+			i--
+			j--
+		}
+
+		if i == prevI {
+			ops = append(ops, api.DiffOp{Op: DiffInsert, Text: y[j-1]})
+		} else {
+			ops = append(ops, api.DiffOp{Op: DiffDelete, Text: x[i-1]})
+		}
+
+		i, j = prevI, prevJ
+	}
+
+	for i > 0 {
+		ops = append(ops, api.DiffOp{Op: DiffEqual, Text: x[i-1]})
+		i--
+	}
+
+	slices.Reverse(ops)
+
+	return ops
+}
+
+func mergeDiffOps(ops []api.DiffOp) []api.DiffOp {
+	merged := make([]api.DiffOp, 0, len(ops))
+
+	for _, op := range ops {
+		if len(merged) > 0 && merged[len(merged)-1].Op == op.Op {
+			merged[len(merged)-1].Text += op.Text
+			continue
+		}
+
+		merged = append(merged, op)
+	}
+
+	return merged
+}
@@ -1,11 +1,19 @@
 package utils
 
 import (
+	"html"
+	"strings"
+
 	"github.com/microcosm-cc/bluemonday"
 )
 
 var policy *bluemonday.Policy = bluemonday.UGCPolicy()
+var stripPolicy *bluemonday.Policy = bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
 
 func Sanitize(dirty string) string {
 	return policy.Sanitize(dirty)
 }
+
+func StripTags(dirty string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(stripPolicy.Sanitize(dirty))), " ")
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// GetAppURL returns the public URL of the site from APP_URL, without a
+// trailing slash.
+func GetAppURL() string {
+	appURL := os.Getenv("APP_URL")
+
+	if appURL == "" {
+		return "http://localhost:3000"
+	}
+
+	return strings.TrimSuffix(appURL, "/")
+}
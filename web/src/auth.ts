@@ -1,3 +1,5 @@
+import { queryOptions } from '@tanstack/react-query'
+
 import { queryClient, router } from './router'
 import type { UserInfo } from './types/UserInfo'
 
@@ -6,6 +8,10 @@ type AuthCredentials = {
   password: string
 }
 
+type RegisterCredentials = AuthCredentials & {
+  email: string
+}
+
 export const login = async (creds: AuthCredentials) => {
   const res = await fetch('/api/auth/login', {
     method: 'POST',
@@ -20,6 +26,37 @@ export const login = async (creds: AuthCredentials) => {
     return false
   }
 
+  if (res.status === 429) {
+    return 'tooManyAttempts'
+  }
+
+  if (!res.ok) {
+    throw new Error()
+  }
+
+  const json = await res.json()
+
+  if (json.twoFactorRequired) {
+    return 'twoFactor'
+  }
+
+  queryClient.setQueryData(['me'], json)
+  return true
+}
+
+export const loginTwoFactor = async (code: string) => {
+  const res = await fetch('/api/auth/login/twoFactor', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/x-www-form-urlencoded',
+    },
+    body: new URLSearchParams({ code }),
+  })
+
+  if (res.status === 401) {
+    return false
+  }
+
   if (!res.ok) {
     throw new Error()
   }
@@ -28,7 +65,7 @@ export const login = async (creds: AuthCredentials) => {
   return true
 }
 
-export const register = async (creds: AuthCredentials) => {
+export const register = async (creds: RegisterCredentials) => {
   const res = await fetch('/api/auth/register', {
     method: 'POST',
     headers: {
@@ -37,6 +74,48 @@ export const register = async (creds: AuthCredentials) => {
     body: new URLSearchParams(creds),
   })
 
+  if (res.status === 409) {
+    return false
+  }
+
+  if (!res.ok) {
+    throw new Error()
+  }
+
+  queryClient.setQueryData(['me'], await res.json())
+  return true
+}
+
+export const oidcOpts = () => queryOptions({
+  queryKey: ['oidc'],
+  queryFn: async () => {
+    const res = await fetch('/api/auth/oidc')
+
+    if (!res.ok) {
+      throw new Error()
+    }
+
+    return res.json() as Promise<{ enabled: boolean, name: string }>
+  },
+})
+
+export const oidcLoginUrl = (redirect: string) => {
+  return `/api/auth/oidc/login?${new URLSearchParams({ redirect })}`
+}
+
+export const completeOidcSignup = async (username: string) => {
+  const res = await fetch('/api/auth/oidc/signup', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/x-www-form-urlencoded',
+    },
+    body: new URLSearchParams({ username }),
+  })
+
+  if (res.status === 409) {
+    return false
+  }
+
   if (!res.ok) {
     throw new Error()
   }
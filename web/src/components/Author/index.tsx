@@ -1,7 +1,7 @@
 import { Link, useNavigate } from '@tanstack/react-router'
 
 import useRelativeTime from '@nkzw/use-relative-time'
-import { Badge, Box, Button, Group, Popover, Stack, Text, UnstyledButton } from '@mantine/core'
+import { Badge, Box, Button, Group, Popover, Stack, Text, Tooltip, UnstyledButton } from '@mantine/core'
 import { useDisclosure, useTimeout } from '@mantine/hooks'
 import { PencilSimple } from '@phosphor-icons/react'
 
@@ -50,8 +50,12 @@ export const Author = ({ source, children } : AuthorProps) => {
                     <Group align="center" gap="xs">
                       <Text size="xs">{ sourceCreationTime }</Text>
                       {
-                        source.updatedAt !== source.createdAt && (
-                          <ColoredIcon icon={PencilSimple} color="dimmed" size={14} weight="fill" />
+                        source.edited && (
+                          <Tooltip label={`Edited (${source.revisionCount} revisions)`}>
+                            <Box component="span" display="flex">
+                              <ColoredIcon icon={PencilSimple} color="dimmed" size={14} weight="fill" />
+                            </Box>
+                          </Tooltip>
                         )
                       }
                     </Group>
@@ -2,6 +2,7 @@ import { infiniteQueryOptions, queryOptions, useInfiniteQuery, useMutation, useQ
 
 import { ClientError } from '../error'
 import type { CommentInfo, FullCommentInfo } from '@/types/CommentInfo'
+import type { Page } from '@/types/Page'
 import type { CommentsQueryOptions } from '@/types/QueryOptions'
 
 export const useComments = (options?: CommentsQueryOptions) => {
@@ -91,12 +92,12 @@ export const useDeleteComment = () => {
 
 export const commentsOpts = ({ filter: { postId, author } = {}, sort = 'latest' } : CommentsQueryOptions = {}) => infiniteQueryOptions({
   queryKey: ['comments', { postId, author, sort }],
-  initialPageParam: 1,
-  getNextPageParam: (lastPage, _, lastPageParam) =>
-    lastPage.length === 0 ? undefined : lastPageParam + 1,
-  queryFn: async ({ pageParam } : { pageParam: number }) => {
-    const search = new URLSearchParams({ page: pageParam.toString(), sort })
+  initialPageParam: undefined as string | undefined,
+  getNextPageParam: (lastPage) => lastPage.nextCursor ?? undefined,
+  queryFn: async ({ pageParam }) => {
+    const search = new URLSearchParams({ sort })
 
+    if (pageParam) search.set('cursor', pageParam)
     if (postId) search.set('post', postId.toString())
     if (author) search.set('user', author)
 
@@ -106,8 +107,9 @@ export const commentsOpts = ({ filter: { postId, author } = {}, sort = 'latest'
       throw new Error()
     }
 
-    return res.json() as Promise<FullCommentInfo[]>
+    return res.json() as Promise<Page<FullCommentInfo>>
   },
+  select: (data) => ({ ...data, pages: data.pages.map((page) => page.items) }),
   enabled: !!postId || !!author,
 })
 
@@ -2,6 +2,7 @@ import { infiniteQueryOptions, queryOptions, useInfiniteQuery, useMutation, useQ
 
 import { ClientError } from '../error'
 import type { FullPostInfo, PostInfo } from '@/types/PostInfo'
+import type { Page } from '@/types/Page'
 import type { PostsQueryOptions } from '@/types/QueryOptions'
 
 export const usePosts = (options?: PostsQueryOptions) => {
@@ -42,13 +43,13 @@ export const useUpdatePost = () => {
   const queryClient = useQueryClient()
 
   return useMutation({
-    mutationFn: async (vars: { postId: number, body: string }) => {
-      const res = await fetch(`/api/posts/${vars.postId}`, {
+    mutationFn: async ({ postId, ...fields }: { postId: number, title?: string, body?: string, tags?: string }) => {
+      const res = await fetch(`/api/posts/${postId}`, {
         method: 'PATCH',
         headers: {
           'Content-Type': 'application/x-www-form-urlencoded',
         },
-        body: new URLSearchParams({ body: vars.body }),
+        body: new URLSearchParams(Object.entries(fields).filter(([, value]) => value !== undefined) as [string, string][]),
       })
 
       if (!res.ok) {
@@ -85,12 +86,12 @@ export const useDeletePost = () => {
 
 export const postsOpts = ({ filter: { author, tag, query } = {}, sort = 'latest' } : PostsQueryOptions = {}) => infiniteQueryOptions({
   queryKey: ['posts', { author, tag, sort, query }],
-  initialPageParam: 1,
-  getNextPageParam: (lastPage, _, lastPageParam) =>
-    lastPage.length === 0 ? undefined : lastPageParam + 1,
-  queryFn: async ({ pageParam } : { pageParam: number }) => {
-    const search = new URLSearchParams({ page: pageParam.toString(), sort })
+  initialPageParam: undefined as string | undefined,
+  getNextPageParam: (lastPage) => lastPage.nextCursor ?? undefined,
+  queryFn: async ({ pageParam }) => {
+    const search = new URLSearchParams({ sort })
 
+    if (pageParam) search.set('cursor', pageParam)
     if (author) search.set('user', author)
     if (tag) search.set('tag', tag)
     if (query) search.set('query', query)
@@ -101,8 +102,9 @@ export const postsOpts = ({ filter: { author, tag, query } = {}, sort = 'latest'
       throw new Error()
     }
 
-    return res.json() as Promise<FullPostInfo[]>
+    return res.json() as Promise<Page<FullPostInfo>>
   },
+  select: (data) => ({ ...data, pages: data.pages.map((page) => page.items) }),
 })
 
 export const postOpts = (postId?: number) => queryOptions({
@@ -1,7 +1,7 @@
 import { FileRoute } from '@tanstack/react-router'
 
 import { Box, Button, Flex, Grid, Group, Loader, Menu, Stack, Text } from '@mantine/core'
-import { CaretDown, ChatsTeardrop, Check, ClockCountdown, Fire, XCircle } from '@phosphor-icons/react'
+import { CaretDown, ChatsTeardrop, Check, ClockCountdown, Fire, TrendUp, XCircle } from '@phosphor-icons/react'
 
 import { Navbar, Post, Sidebar } from '@/components'
 import { usePosts } from '@/hooks/posts'
@@ -11,6 +11,7 @@ import { usePreference } from '@/hooks/usePreference'
 const Index = () => {
   const sortOptions = {
     latest: ClockCountdown,
+    hot: TrendUp,
     popular: Fire,
     replies: ChatsTeardrop,
   }
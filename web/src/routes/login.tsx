@@ -2,29 +2,45 @@ import { useState, type FormEventHandler } from 'react'
 
 import { FileRoute, Link, redirect } from '@tanstack/react-router'
 import { useForm } from '@tanstack/react-form'
+import { useQuery } from '@tanstack/react-query'
 
-import { Alert, Anchor, Button, Center, Paper, PasswordInput, Stack, Text, TextInput } from '@mantine/core'
+import { Alert, Anchor, Button, Center, Divider, Paper, PasswordInput, Stack, Text, TextInput } from '@mantine/core'
 import { WarningCircle } from '@phosphor-icons/react'
 
 import { meOpts } from '@/hooks/me'
-import { login } from '../auth'
+import { login, loginTwoFactor, oidcLoginUrl, oidcOpts } from '../auth'
 import { router } from '../router'
 
 const Login = () => {
   const search = Route.useSearch()
 
   const [alert, setAlert] = useState('')
+  const [twoFactor, setTwoFactor] = useState(search.twoFactor ?? false)
+  const { data: oidc } = useQuery(oidcOpts())
 
   const form = useForm({
     defaultValues: {
       username: '',
       password: '',
+      code: '',
     },
 
     async onSubmit({ value }) {
-      const success = await login(value)
+      if (twoFactor) {
+        const success = await loginTwoFactor(value.code)
 
-      if (success) router.history.push(search.redirect ?? '/')
+        if (success) router.history.push(search.redirect ?? '/')
+        else setAlert('Incorrect authentication code')
+        return
+      }
+
+      const success = await login({ username: value.username, password: value.password })
+
+      if (success === 'twoFactor') {
+        setAlert('')
+        setTwoFactor(true)
+      } else if (success === 'tooManyAttempts') setAlert('Too many sign in attempts. Please try again later')
+      else if (success) router.history.push(search.redirect ?? '/')
       else setAlert('Incorrect username or password')
     },
   })
@@ -51,6 +67,31 @@ const Login = () => {
 
         <form.Provider>
           <form onSubmit={handleSubmit}>
+            {
+              twoFactor && (
+                <form.Field name="code">
+                  {
+                    (field) => (
+                      <TextInput
+                        variant="filled"
+                        size="md"
+                        label="Authentication code"
+                        description="Enter the code from your authenticator app, or a recovery code"
+                        required
+                        my="md"
+                        name={field.name}
+                        value={field.state.value}
+                        onBlur={field.handleBlur}
+                        onChange={(e) => field.handleChange(e.target.value)}
+                        autoComplete="one-time-code"
+                        autoFocus
+                      />
+                    )
+                  }
+                </form.Field>
+              )
+            }
+
             <form.Field name="username">
               {
                 (field) => (
@@ -65,6 +106,7 @@ const Login = () => {
                     onBlur={field.handleBlur}
                     onChange={(e) => field.handleChange(e.target.value)}
                     error={field.state.meta.errors?.[0]}
+                    disabled={twoFactor}
                     autoFocus
                   />
                 )
@@ -85,6 +127,7 @@ const Login = () => {
                     onBlur={field.handleBlur}
                     onChange={(e) => field.handleChange(e.target.value)}
                     error={field.state.meta.errors?.[0]}
+                    disabled={twoFactor}
                   />
                 )
               }
@@ -111,6 +154,24 @@ const Login = () => {
           </form>
         </form.Provider>
 
+        {
+          oidc?.enabled && (
+            <>
+              <Divider label="or" my="md" />
+              <Button
+                variant="outline"
+                size="lg"
+                radius="lg"
+                fullWidth
+                component="a"
+                href={oidcLoginUrl(search.redirect ?? '/')}
+                mb="xl">
+                Sign in with {oidc.name}
+              </Button>
+            </>
+          )
+        }
+
         <Stack align="center" gap={0}>
           <Text>Don&rsquo;t have an account? <Anchor component={Link} to="/register" search={search}>Sign up</Anchor></Text>
         </Stack>
@@ -121,8 +182,8 @@ const Login = () => {
 
 export const Route = new FileRoute('/login').createRoute({
   component: Login,
-  validateSearch: (search: Record<string, string>) => {
-    return { redirect: search.redirect ?? '/' }
+  validateSearch: (search: Record<string, string>): { redirect: string, twoFactor?: boolean } => {
+    return { redirect: search.redirect ?? '/', twoFactor: search.twoFactor === 'true' || undefined }
   },
   beforeLoad: async ({ context: { queryClient } }) => {
     const me = await queryClient.fetchQuery(meOpts())
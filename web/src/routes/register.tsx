@@ -7,7 +7,7 @@ import { Alert, Anchor, Button, Center, Paper, PasswordInput, Stack, Text, TextI
 import { WarningCircle } from '@phosphor-icons/react'
 
 import { meOpts } from '@/hooks/me'
-import { register } from '../auth'
+import { completeOidcSignup, register } from '../auth'
 import { router } from '../router'
 
 const Register = () => {
@@ -18,14 +18,23 @@ const Register = () => {
   const form = useForm({
     defaultValues: {
       username: '',
+      email: '',
       password: '',
     },
 
     async onSubmit({ value }) {
+      if (search.oidc) {
+        const success = await completeOidcSignup(value.username)
+
+        if (success) router.history.push(search.redirect ?? '/')
+        else setAlert('Username is already taken')
+        return
+      }
+
       const success = await register(value)
 
       if (success) router.history.push(search.redirect ?? '/')
-      else setAlert('An error occured. Please try again later. ')
+      else setAlert('An account with this email already exists. ')
     },
   })
 
@@ -103,32 +112,66 @@ const Register = () => {
               }
             </form.Field>
 
-            <form.Field
-              name="password"
-              validators={{
-                onChange(field) {
-                  if (field.value.length < 8) {
-                    return 'Password must be at least 8 characters long'
-                  }
-                },
-              }}>
-              {
-                (field) => (
-                  <PasswordInput
-                    variant="filled"
-                    size="md"
-                    label="Password"
-                    required
-                    my="md"
-                    name={field.name}
-                    value={field.state.value}
-                    onBlur={field.handleBlur}
-                    onChange={(e) => field.handleChange(e.target.value)}
-                    error={field.state.meta.errors[0]}
-                  />
-                )
-              }
-            </form.Field>
+            {
+              !search.oidc && (
+                <>
+                  <form.Field
+                    name="email"
+                    validators={{
+                      onChange(field) {
+                        if (!/^[^\s@]+@[^\s@]+$/.test(field.value)) {
+                          return 'Enter a valid email address'
+                        }
+                      },
+                    }}>
+                    {
+                      (field) => (
+                        <TextInput
+                          variant="filled"
+                          size="md"
+                          label="Email"
+                          type="email"
+                          required
+                          my="md"
+                          name={field.name}
+                          value={field.state.value}
+                          onBlur={field.handleBlur}
+                          onChange={(e) => field.handleChange(e.target.value)}
+                          error={field.state.meta.errors[0]}
+                        />
+                      )
+                    }
+                  </form.Field>
+
+                  <form.Field
+                    name="password"
+                    validators={{
+                      onChange(field) {
+                        if (field.value.length < 8) {
+                          return 'Password must be at least 8 characters long'
+                        }
+                      },
+                    }}>
+                    {
+                      (field) => (
+                        <PasswordInput
+                          variant="filled"
+                          size="md"
+                          label="Password"
+                          required
+                          my="md"
+                          name={field.name}
+                          value={field.state.value}
+                          onBlur={field.handleBlur}
+                          onChange={(e) => field.handleChange(e.target.value)}
+                          error={field.state.meta.errors[0]}
+                        />
+                      )
+                    }
+                  </form.Field>
+                </>
+              )
+            }
 
             <form.Subscribe
               selector={(state) => [state.canSubmit, state.isSubmitting]}>
@@ -152,7 +195,7 @@ const Register = () => {
         </form.Provider>
 
         <Stack align="center" gap={0}>
-          <Text>Already have an account? <Anchor component={Link} to="/login" search={search}>Sign in</Anchor></Text>
+          <Text>Already have an account? <Anchor component={Link} to="/login" search={{ redirect: search.redirect }}>Sign in</Anchor></Text>
         </Stack>
       </Paper>
     </Center>
@@ -161,8 +204,8 @@ const Register = () => {
 
 export const Route = new FileRoute('/register').createRoute({
   component: Register,
-  validateSearch: (search: Record<string, string>) => {
-    return { redirect: search.redirect ?? '/' }
+  validateSearch: (search: Record<string, string>): { redirect: string, oidc?: boolean } => {
+    return { redirect: search.redirect ?? '/', oidc: search.oidc === 'true' || undefined }
   },
   beforeLoad: async ({ context: { queryClient } }) => {
     const me = await queryClient.fetchQuery(meOpts())
@@ -4,11 +4,17 @@ import { DeletedInfo } from './DeletedInfo'
 export type FullCommentInfo = {
   id: number
   postId: number
+  parentId: number | null
+  depth: number
+  replies?: CommentInfo[]
   body: string
   author: UserInfo
   createdAt: string
   updatedAt: string
+  edited: boolean
+  editedAt: string | null
+  revisionCount: number
   deleted: false
 }
 
-export type CommentInfo = FullCommentInfo | DeletedInfo<FullCommentInfo, 'id' | 'postId'>
+export type CommentInfo = FullCommentInfo | DeletedInfo<FullCommentInfo, 'id' | 'postId' | 'parentId' | 'depth' | 'replies'>
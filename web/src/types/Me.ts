@@ -1,8 +1,13 @@
 export type Me = {
   id: number
+  email?: string
+  emailVerified: boolean
+  twoFactorEnabled: boolean
+  twoFactorEnrolmentRequired?: boolean
+  capabilities?: string[]
   prefs: {
     prefersDarkMode: boolean
     prefersReducedMotion: boolean
-    preferredSort: 'latest' | 'popular' | 'replies'
+    preferredSort: 'latest' | 'hot' | 'popular' | 'replies'
   }
 }
@@ -0,0 +1,5 @@
+export type Page<T> = {
+  items: T[]
+  nextCursor: string | null
+  hasMore: boolean
+}
@@ -7,9 +7,14 @@ export type FullPostInfo = {
   body: string
   author: UserInfo
   commentCount: number
+  reactionCount: number
+  hotScore: number
   tags: number[]
   createdAt: string
   updatedAt: string
+  edited: boolean
+  editedAt: string | null
+  revisionCount: number
   deleted: false
 }
 
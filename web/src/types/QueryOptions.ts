@@ -5,7 +5,7 @@ export type PostsQueryOptions = {
     query?: string
   }
 
-  sort?: 'latest' | 'popular' | 'replies'
+  sort?: 'latest' | 'hot' | 'popular' | 'replies'
 }
 
 export type CommentsQueryOptions = {